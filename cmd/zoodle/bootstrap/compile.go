@@ -1,58 +1,67 @@
 package bootstrap
 
 import (
-	"errors"
+	"log/slog"
 	"sudonters/libzootr/magicbean"
 	"sudonters/libzootr/mido"
 	"sudonters/libzootr/mido/optimizer"
+	"sudonters/libzootr/ruleparser"
 	"sudonters/libzootr/table"
 	"sudonters/libzootr/table/ocm"
 
 	"github.com/etc-sudonters/substrate/slipup"
 )
 
-func parseall(entities *ocm.Entities, codegen *mido.CodeGen) error {
+func parseall(entities *ocm.Entities, codegen *mido.CodeGen) (ruleparser.Diagnostics, error) {
 	rows, err := entities.Query(
 		table.Load[magicbean.RuleSource],
+		table.Load[magicbean.Name],
 		table.Exists[magicbean.Connection],
 		table.NotExists[magicbean.RuleParsed],
 	)
 
 	if err != nil {
-		return slipup.Describe(err, "failed to find rules to parse")
+		return nil, slipup.Describe(err, "failed to find rules to parse")
 	}
 
-	var parseErr error
+	var diagnostics ruleparser.Diagnostics
 
 	for row, tup := range rows.All {
 		entity, _ := entities.Proxy(row)
 		source := tup.Values[0].(magicbean.RuleSource)
 
-		parsed, err := codegen.Parse(string(source))
+		parsed, spans, err := codegen.ParseWithSpans(string(source))
 		if err != nil {
-			parseErr = errors.Join(parseErr, slipup.Describef(err, "failed to parse %q", source))
+			origin := ruleorigin(tup.Values[1], source)
+			diagnostics = append(diagnostics, codegen.Diagnose(origin, nil, err)...)
+			continue
 		}
 
-		entity.Attach(magicbean.RuleParsed{Node: parsed})
+		entity.Attach(magicbean.RuleParsed{Node: parsed, Spans: spans})
 	}
 
-	return parseErr
+	return diagnostics, nil
 }
 
-func optimizeall(entities *ocm.Entities, codegen *mido.CodeGen) error {
+func optimizeall(entities *ocm.Entities, codegen *mido.CodeGen) (ruleparser.Diagnostics, error) {
 	rows, err := entities.Query(
 		table.Load[magicbean.RuleParsed],
 		table.Load[magicbean.Connection],
 		table.NotExists[magicbean.RuleOptimized],
+		table.Optional[magicbean.Name],
+		table.Optional[magicbean.RuleSource],
 	)
 
 	if err != nil {
-		return slipup.Describe(err, "failed to find rules to optimize")
+		return nil, slipup.Describe(err, "failed to find rules to optimize")
 	}
 	if rows.Len() == 0 {
-		return nil
+		return nil, nil
 	}
 
+	var diagnostics ruleparser.Diagnostics
+	var capped int
+
 	for ent, tup := range rows.All {
 		entity, _ := entities.Proxy(ent)
 		parsed := tup.Values[0].(magicbean.RuleParsed)
@@ -62,34 +71,93 @@ func optimizeall(entities *ocm.Entities, codegen *mido.CodeGen) error {
 		parent, parentErr := fromEntity.Values(table.ColumnIdFor[magicbean.Name])
 		slipup.PanicOnError(parentErr)
 		optimizer.SetCurrentLocation(codegen.Context, string(parent.Values[0].(magicbean.Name)))
-		optimized, optimizeErr := codegen.Optimize(parsed.Node)
-		slipup.PanicOnError(optimizeErr)
-		entity.Attach(magicbean.RuleOptimized{Node: optimized})
+		origin := ruleorigin(tup.Values[2], tup.Values[3])
+		if checkErr := codegen.PreAnalyze(parsed.Node); checkErr != nil {
+			diagnostics = append(diagnostics, codegen.Diagnose(origin, parsed.Spans, checkErr)...)
+			continue
+		}
+		optimized, optimizeErr := codegen.OptimizeRule(origin.Name, parsed.Node, parsed.Spans)
+		if optimizeErr == nil {
+			optimizeErr = codegen.PostAnalyze(optimized.Node)
+		}
+		if optimizeErr != nil {
+			diagnostics = append(diagnostics, codegen.Diagnose(origin, parsed.Spans, optimizeErr)...)
+			continue
+		}
+		entity.Attach(magicbean.RuleOptimized{Node: optimized.Node, Spans: parsed.Spans})
+		entity.Attach(magicbean.RuleOptimizePasses{Passes: optimized.Passes, Converged: optimized.Converged})
+		if !optimized.Converged {
+			capped++
+			slog.Warn("rule did not converge", "rule", origin.Name, "passes", optimized.Passes)
+		}
 	}
 
-	return nil
+	if capped > 0 {
+		slog.Warn("rules hit the optimization pass cap", "count", capped)
+	}
+	return diagnostics, nil
 }
 
-func compileall(entities *ocm.Entities, codegen *mido.CodeGen) error {
+// pass counts of every optimized rule keyed by rule name
+func OptimizePasses(entities *ocm.Entities) (map[string]magicbean.RuleOptimizePasses, error) {
+	rows, err := entities.Query(table.Load[magicbean.RuleOptimizePasses], table.Load[magicbean.Name])
+	if err != nil {
+		return nil, slipup.Describe(err, "failed to find optimized rules")
+	}
+
+	passes := make(map[string]magicbean.RuleOptimizePasses, rows.Len())
+	for _, tup := range rows.All {
+		passes[string(tup.Values[1].(magicbean.Name))] = tup.Values[0].(magicbean.RuleOptimizePasses)
+	}
+	return passes, nil
+}
+
+func compileall(entities *ocm.Entities, codegen *mido.CodeGen) (ruleparser.Diagnostics, error) {
 	rows, err := entities.Query(
 		table.Load[magicbean.RuleOptimized],
 		table.Exists[magicbean.Connection],
 		table.NotExists[magicbean.RuleCompiled],
+		table.Optional[magicbean.Name],
+		table.Optional[magicbean.RuleSource],
 	)
 
 	if err != nil {
-		return slipup.Describe(err, "failed to find rules to compile")
+		return nil, slipup.Describe(err, "failed to find rules to compile")
 	}
 
+	for _, tup := range rows.All {
+		codegen.Share(tup.Values[0].(magicbean.RuleOptimized).Node)
+	}
+
+	var diagnostics ruleparser.Diagnostics
 	for ent, tup := range rows.All {
 		entity, _ := entities.Proxy(ent)
 		compiling := tup.Values[0].(magicbean.RuleOptimized)
 		bytecode, err := codegen.Compile(compiling.Node)
 		if err != nil {
-			return slipup.Describe(err, "failed while compiling rule")
+			diagnostics = append(diagnostics, codegen.Diagnose(ruleorigin(tup.Values[1], tup.Values[2]), compiling.Spans, err)...)
+			continue
 		}
 		slipup.PanicOnError(entity.Attach(magicbean.RuleCompiled(bytecode)))
 	}
 
-	return nil
+	sharing := codegen.Sharing()
+	slog.Info("shared common subexpressions",
+		"subroutines", sharing.Subroutines, "calls", sharing.Calls,
+		"bytes", sharing.Bytes, "inlined", sharing.InlinedBytes,
+		"ratio", sharing.Ratio(),
+	)
+	return diagnostics, nil
+}
+
+// name and source columns may be missing for generated rules
+func ruleorigin(name, source table.Value) ruleparser.Origin {
+	var origin ruleparser.Origin
+	if name, isName := name.(magicbean.Name); isName {
+		origin.Name = string(name)
+	}
+	if source, isSource := source.(magicbean.RuleSource); isSource {
+		origin.Source = string(source)
+	}
+	return origin
 }
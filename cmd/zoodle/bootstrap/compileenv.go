@@ -3,9 +3,7 @@ package bootstrap
 import (
 	"fmt"
 	"github.com/etc-sudonters/substrate/slipup"
-	"regexp"
 	"slices"
-	"strings"
 	"sudonters/libzootr/internal/settings"
 	"sudonters/libzootr/magicbean"
 	"sudonters/libzootr/magicbean/tracking"
@@ -14,6 +12,7 @@ import (
 	"sudonters/libzootr/mido/objects"
 	"sudonters/libzootr/mido/optimizer"
 	"sudonters/libzootr/mido/symbols"
+	"sudonters/libzootr/mido/typecheck"
 	"sudonters/libzootr/table"
 	"sudonters/libzootr/table/ocm"
 )
@@ -131,7 +130,7 @@ func aliassymbols(entities *ocm.Entities, syms *symbols.Table) error {
 		case symbols.FUNCTION, symbols.BUILT_IN_FUNCTION, symbols.COMPILER_FUNCTION, symbols.SCRIPTED_FUNC:
 			continue
 		case symbols.TOKEN:
-			alias := escape(name)
+			alias := symbols.Escape(name)
 			syms.Alias(original, alias)
 			proxy, _ := entities.Proxy(id)
 			slipup.PanicOnError(proxy.Attach(magicbean.AliasingName(alias)))
@@ -144,15 +143,18 @@ func aliassymbols(entities *ocm.Entities, syms *symbols.Table) error {
 	return nil
 }
 
-func installCompilerFunctions(these *settings.Zootr) mido.ConfigureCompiler {
+func installCompilerFunctions(these *settings.Zootr, skills settings.SkillCatalog) mido.ConfigureCompiler {
 
 	return func(env *mido.CompileEnv) {
 		hasNotesForSong := env.Symbols.Declare("has_notes_for_song", symbols.BUILT_IN_FUNCTION)
 		needsHeartForDamageMult := env.Symbols.Declare("needs_hearts_for_damage_multipler", symbols.BUILT_IN_FUNCTION)
-		checkTod := env.Symbols.Declare("check_tod", symbols.BUILT_IN_FUNCTION)
+		checkTod := env.Symbols.Declare("check_tod_access", symbols.BUILT_IN_FUNCTION)
 		isGlitchEnabled := func(args []ast.Node, _ ast.Rewriting) (ast.Node, error) {
 			switch arg := args[0].(type) {
 			case ast.String:
+				if !skills.Knows(settings.SkillGlitch, string(arg)) {
+					return nil, fmt.Errorf("unknown glitch %q", string(arg))
+				}
 				return ast.Boolean(these.Skills.Glitches[string(arg)]), nil
 			default:
 				return nil, fmt.Errorf("is_glitch_enabled expects string as first argument got %#v", arg)
@@ -162,6 +164,9 @@ func installCompilerFunctions(these *settings.Zootr) mido.ConfigureCompiler {
 		isTrickEnabled := func(args []ast.Node, _ ast.Rewriting) (ast.Node, error) {
 			switch arg := args[0].(type) {
 			case ast.String:
+				if !skills.Knows(settings.SkillTrick, string(arg)) {
+					return nil, fmt.Errorf("unknown trick %q", string(arg))
+				}
 				return ast.Boolean(these.Skills.Tricks[string(arg)]), nil
 			default:
 				return nil, fmt.Errorf("is_trick_enabled expects string as first argument got %#v", arg)
@@ -222,6 +227,20 @@ func installCompilerFunctions(these *settings.Zootr) mido.ConfigureCompiler {
 			return ast.Boolean(false), nil
 		}
 
+		// dungeon_shortcuts and skipped_trials are lowered to their own functions
+		settingContains := func(args []ast.Node, _ ast.Rewriting) (ast.Node, error) {
+			name, isStr := args[0].(ast.String)
+			if !isStr {
+				return nil, fmt.Errorf("setting_contains expects string as first argument got %#v", args[0])
+			}
+			member, isStr := args[1].(ast.String)
+			if !isStr {
+				return nil, fmt.Errorf("setting_contains expects string as second argument got %#v", args[1])
+			}
+			contains, err := these.Contains(string(name), string(member))
+			return ast.Boolean(contains), err
+		}
+
 		needsTodChecks := func(tod string) optimizer.CompilerFunction {
 			return func(args []ast.Node, _ ast.Rewriting) (ast.Node, error) {
 				if !these.Entrances.AffectedTodChecks() {
@@ -257,14 +276,33 @@ func installCompilerFunctions(these *settings.Zootr) mido.ConfigureCompiler {
 				"is_trial_skipped":       isTrialSkipped,
 				"has_soul":               ConstCompileFunc(true),
 				"can_live_dmg":           canLiveDmg,
+				"setting_contains":       settingContains,
 			}
 		})(env)
+		env.Symbols.SetParams(env.Symbols.LookUpByName("can_live_dmg"), []string{"hearts", "fairy", "nayrus"})
+
+		token, number, str, boolean := objects.ArgToken, objects.ArgNumber, objects.ArgString, objects.ArgBool
+		for name, sig := range map[string]typecheck.Signature{
+			"region_has_shortcuts":   typecheck.Fixed(str),
+			"is_glitch_enabled":      typecheck.Fixed(str),
+			"is_trick_enabled":       typecheck.Fixed(str),
+			"had_night_start":        typecheck.Fixed(),
+			"has_all_notes_for_song": typecheck.Fixed(token),
+			"at_dampe_time":          typecheck.Fixed(),
+			"at_day":                 typecheck.Fixed(),
+			"at_night":               typecheck.Fixed(),
+			"is_trial_skipped":       typecheck.Fixed(str),
+			"can_live_dmg":           {Params: []objects.ArgKind{number, boolean, boolean}, Required: 1},
+			"setting_contains":       typecheck.Fixed(str, objects.ArgAny),
+		} {
+			env.Analysis.DeclareSignature(env.Symbols.LookUpByName(name), sig)
+		}
 	}
 }
 
 func installConnectionGenerator(entities *ocm.Entities) mido.ConfigureCompiler {
 	return func(env *mido.CompileEnv) {
-		env.Optimize.AddOptimizer(func(ce *mido.CompileEnv) ast.Rewriter {
+		env.Optimize.AddOptimizer("ConnectionGeneration", func(ce *mido.CompileEnv) ast.Rewriter {
 			var conngen ConnectionGenerator
 			var err error
 			conngen.Nodes, err = tracking.NewNodes(entities)
@@ -280,13 +318,6 @@ func installConnectionGenerator(entities *ocm.Entities) mido.ConfigureCompiler {
 	}
 }
 
-var escaping = regexp.MustCompile(`['()[\]-]`)
-
-func escape(name string) string {
-	name = escaping.ReplaceAllLiteralString(name, "")
-	return strings.ReplaceAll(name, " ", "_")
-}
-
 type ConnectionGenerator struct {
 	Nodes   tracking.Nodes
 	Tokens  tracking.Tokens
@@ -303,6 +334,14 @@ func (this ConnectionGenerator) AddConnectionTo(region string, rule ast.Node) (*
 		return symbol, nil
 	}
 
+	symbol, edge := this.connect(region, suffix)
+	edge.Proxy.Attach(magicbean.RuleParsed{Node: rule})
+	return symbol, nil
+}
+
+// creates the event token, its placement and the edge to it from region
+func (this ConnectionGenerator) connect(region, suffix string) (*symbols.Sym, tracking.Transit) {
+	tokenName := magicbean.NameF("Token%s", suffix)
 	token, tokenErr := this.Tokens.Named(tokenName)
 	slipup.PanicOnError(tokenErr)
 	placement := this.Nodes.Placement(magicbean.NameF("Place%s", suffix))
@@ -313,11 +352,10 @@ func (this ConnectionGenerator) AddConnectionTo(region string, rule ast.Node) (*
 
 	node := this.Nodes.Region(magicbean.Name(region))
 	edge := node.Has(placement)
-	edge.Proxy.Attach(magicbean.RuleParsed{Node: rule})
 
 	symbol := this.Symbols.Declare(string(tokenName), symbols.TOKEN)
 	this.Objects.AssociateSymbol(symbol, ptr)
-	return symbol, nil
+	return symbol, edge
 }
 
 func ConstCompileFunc(b bool) optimizer.CompilerFunction {
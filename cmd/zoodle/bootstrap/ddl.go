@@ -35,10 +35,12 @@ func staticddl() []table.DDL {
 		sizedhash[magicbean.RuleParsed](4000),
 		sizedhash[magicbean.RuleOptimized](4000),
 		sizedhash[magicbean.RuleCompiled](4000),
+		sizedhash[magicbean.RuleOptimizePasses](4000),
 		sizedhash[magicbean.EdgeKind](4000),
 		sizedhash[magicbean.Connection](4000),
 		sizedhash[magicbean.RuleSource](4000),
 		sizedhash[magicbean.DefaultPlacement](2200),
+		sizedhash[magicbean.LocationName](4000),
 
 		columns.HashMapColumn[magicbean.CollectablePriority],
 		columns.HashMapColumn[magicbean.HeldAt],
@@ -84,5 +86,6 @@ func staticddl() []table.DDL {
 		columns.BitColumnOf[magicbean.Stone],
 		columns.BitColumnOf[magicbean.Bottle],
 		columns.BitColumnOf[magicbean.WorldGraphRoot],
+		columns.BitColumnOf[magicbean.Excluded],
 	}
 }
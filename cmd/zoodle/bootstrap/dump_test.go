@@ -0,0 +1,176 @@
+package bootstrap
+
+import (
+	"context"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sudonters/libzootr/internal/settings"
+	"sudonters/libzootr/magicbean"
+	"sudonters/libzootr/magicbean/tracking"
+	"sudonters/libzootr/mido"
+	"sudonters/libzootr/mido/compiler"
+	"sudonters/libzootr/mido/objects"
+	"sudonters/libzootr/table"
+	"sudonters/libzootr/table/ocm"
+	"testing"
+
+	"github.com/etc-sudonters/substrate/files"
+)
+
+// directories holding ootr's dumped logic and data, the same ones zoodle's
+// -l and -d flags name. Tests needing the dump skip when either is unset
+const (
+	logicDirEnv = "ZOODLE_LOGIC_DIR"
+	dataDirEnv  = "ZOODLE_DATA_DIR"
+)
+
+func dumpPaths(t *testing.T) LoadPaths {
+	t.Helper()
+	logicDir, dataDir := os.Getenv(logicDirEnv), os.Getenv(dataDirEnv)
+	if logicDir == "" || dataDir == "" {
+		t.Skipf("%s and %s name no logic dump", logicDirEnv, dataDirEnv)
+	}
+
+	return LoadPaths{
+		Tokens:     filepath.Join(dataDir, "items.json"),
+		Placements: filepath.Join(dataDir, "locations.json"),
+		Scripts:    filepath.Join(logicDir, "..", "helpers.json"),
+		Relations:  logicDir,
+		Skills:     filepath.Join(dataDir, "skills.json"),
+	}
+}
+
+// imports the dump and configures a compiler for it, nothing is parsed yet
+func importDump(t *testing.T, these *settings.Zootr, options ...mido.ConfigureCompiler) (*ocm.Entities, mido.CompileEnv) {
+	t.Helper()
+	paths := dumpPaths(t)
+	ctx := context.Background()
+	_, entities := Phase1_InitializeStorage(nil)
+	set, err := tracking.NewTrackingSet(entities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Phase2_ImportFromFiles(ctx, files.OsFS, entities, &set, paths); err != nil {
+		t.Fatal(err)
+	}
+	skills, err := LoadSkillCatalog(ctx, files.OsFS, paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entities, Phase3_ConfigureCompiler(entities, these, skills, options...)
+}
+
+// imports and compiles the dump with default settings
+func compileDump(t *testing.T, options ...mido.ConfigureCompiler) (*ocm.Entities, mido.CompileEnv, mido.CodeGen) {
+	t.Helper()
+	these := settings.Default()
+	entities, env := importDump(t, &these, options...)
+	codegen := mido.Compiler(&env)
+	if err := Phase4_Compile(entities, &codegen); err != nil {
+		t.Fatal(err)
+	}
+	return entities, env, codegen
+}
+
+// every rule upstream logic uses must parse and lower
+func TestLowersEveryDumpedRule(t *testing.T) {
+	these := settings.Default()
+	entities, env := importDump(t, &these)
+	codegen := mido.Compiler(&env)
+
+	diagnostics, err := parseall(entities, &codegen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diagnostics.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Simplify has to shrink real logic, not just the cases in its own tests
+func TestSimplifyShrinksDumpedBytecode(t *testing.T) {
+	_, _, simplified := compileDump(t)
+	_, _, unsimplified := compileDump(t, mido.WithoutOptimizer("Simplify"))
+	with, without := simplified.Sharing(), unsimplified.Sharing()
+	t.Logf("Simplify: %d -> %d bytes, %d -> %d bytes before sharing",
+		without.Bytes, with.Bytes, without.InlinedBytes, with.InlinedBytes)
+	if with.InlinedBytes >= without.InlinedBytes {
+		t.Errorf("expected Simplify to shrink %d bytes, found %d", without.InlinedBytes, with.InlinedBytes)
+	}
+}
+
+// both evaluators must agree on every compiled rule as either age while the
+// inventory grows
+func TestClosuresAgreeWithVMOnDumpedRules(t *testing.T) {
+	entities, env, codegen := compileDump(t)
+	these := settings.Default()
+	objs := objects.TableFrom(env.Objects)
+
+	rows, err := entities.Query(table.Load[magicbean.RuleCompiled], table.Load[magicbean.Name])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	var rules []compiler.Bytecode
+	for _, tup := range rows.All {
+		rules = append(rules, compiler.Bytecode(tup.Values[0].(magicbean.RuleCompiled)))
+		names = append(names, string(tup.Values[1].(magicbean.Name)))
+	}
+	tokens, err := entities.Matching(table.Exists[magicbean.Token])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inventory := magicbean.NewInventory()
+	pockets := magicbean.NewPockets(&inventory, entities)
+	evaluators := func(adult bool) (*mido.VM, *mido.Closures) {
+		funcs := magicbean.BuiltIns{}
+		magicbean.CreateBuiltInHasFuncs(&funcs, &pockets, &these)
+		funcs.CheckTodAccess = magicbean.ConstBool(true)
+		funcs.IsAdult = magicbean.ConstBool(adult)
+		funcs.IsChild = magicbean.ConstBool(!adult)
+		funcs.IsStartingAge = magicbean.ConstBool(!adult)
+		vm := &mido.VM{
+			Objects: &objs, Funcs: funcs.Table(), ChkQty: funcs.Has,
+			Subroutines: codegen.Subroutines(), State: inventory.Version,
+		}
+		closures := &mido.Closures{
+			Objects: &objs, Funcs: funcs.Table(),
+			Has: func(token objects.Addr32, qty float64) bool {
+				return pockets.Has(ocm.Entity(token), qty)
+			},
+			Subroutines: codegen.Subroutines(), State: inventory.Version,
+		}
+		return vm, closures
+	}
+	adultVM, adultClosures := evaluators(true)
+	childVM, childClosures := evaluators(false)
+
+	rng := rand.New(rand.NewPCG(0x5EED, 0x1EAF))
+	var failures int
+	for round := range 6 {
+		for _, pair := range []struct {
+			vm       *mido.VM
+			closures *mido.Closures
+		}{{adultVM, adultClosures}, {childVM, childClosures}} {
+			for i, rule := range rules {
+				expected, vmErr := pair.vm.Execute(rule)
+				answer, closureErr := pair.closures.Execute(rule)
+				if expected != answer || (vmErr == nil) != (closureErr == nil) {
+					failures++
+					t.Errorf("round %d %s: vm answered %s %v, closures answered %s %v",
+						round, names[i], expected, vmErr, answer, closureErr)
+				}
+				if failures > 10 {
+					t.FailNow()
+				}
+			}
+		}
+		for token := range tokens {
+			if rng.IntN(3) == 0 {
+				inventory.CollectOne(token)
+			}
+		}
+	}
+}
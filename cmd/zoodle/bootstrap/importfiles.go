@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sudonters/libzootr/importers"
+	"sudonters/libzootr/internal/settings"
 	"sudonters/libzootr/magicbean"
 	"sudonters/libzootr/magicbean/tracking"
 	"sudonters/libzootr/mido/optimizer"
@@ -27,6 +28,9 @@ type DirPath = string
 type LoadPaths struct {
 	Tokens, Placements, Scripts FilePath
 	Relations                   DirPath
+	// every trick and glitch name is validated against it, see
+	// LoadSkillCatalog
+	Skills FilePath
 }
 
 func (this LoadPaths) readscripts(ctx context.Context, fs fs.FS) iter.Seq2[importers.DumpedScript, error] {
@@ -93,6 +97,27 @@ func (this LoadPaths) readplacements(ctx context.Context, fs fs.FS) iter.Seq2[im
 	}
 }
 
+func (this LoadPaths) readskills(ctx context.Context, fs fs.FS) iter.Seq2[importers.DumpedSkill, error] {
+	return func(yield func(importers.DumpedSkill, error) bool) {
+		fh, fhErr := fs.Open(string(this.Skills))
+		defer func() {
+			if fh != nil {
+				fh.Close()
+			}
+		}()
+		if fhErr != nil {
+			yield(importers.DumpedSkill{}, fhErr)
+			return
+		}
+
+		for skill, err := range importers.DumpSkills.ImportFrom(ctx, fh) {
+			if !yield(skill, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
 func (this LoadPaths) readrelationsdir(ctx context.Context, fsys fs.FS, store func(importers.DumpedRelation) error) error {
 	return filepath.WalkDir(string(this.Relations), func(path string, entry fs.DirEntry, err error) error {
 		if err != nil {
@@ -272,64 +297,118 @@ func storePlacements(ctx context.Context, fs fs.FS, nodes tracking.Nodes, tokens
 
 func storeRelations(ctx context.Context, fs fs.FS, nodes tracking.Nodes, tokens tracking.Tokens, paths LoadPaths) error {
 	return paths.readrelationsdir(ctx, fs, func(relation importers.DumpedRelation) error {
-		region := nodes.Region(name(relation.RegionName))
+		return storeRelation(nodes, tokens, relation)
+	})
+}
 
-		for exit, rule := range relation.Exits {
-			transit := region.ConnectsTo(nodes.Region(name(exit)))
-			transit.Proxy.Attach(magicbean.RuleSource(rule), magicbean.EdgeTransit)
-		}
+func storeRelation(nodes tracking.Nodes, tokens tracking.Tokens, relation importers.DumpedRelation) error {
+	region := nodes.Region(name(relation.RegionName))
 
-		for location, rule := range relation.Relations {
-			placename := namef("%s %s", relation.RegionName, location)
-			placement := nodes.Placement(placename)
-			edge := region.Has(placement)
-			edge.Attach(magicbean.RuleSource(rule))
-		}
+	for exit, rule := range relation.Exits {
+		transit := region.ConnectsTo(nodes.Region(name(exit)))
+		transit.Proxy.Attach(magicbean.RuleSource(rule), magicbean.EdgeTransit)
+	}
 
-		for event, rule := range relation.Events {
-			token, err := tokens.Named(name(event))
-			slipup.PanicOnError(err)
-			slipup.PanicOnError(token.Attach(magicbean.Event{}))
-			placement := nodes.Placement(namef("%s %s", relation.RegionName, event))
-			placement.Fixed(token)
-			edge := region.Has(placement)
-			edge.Attach(magicbean.RuleSource(rule))
-		}
+	for location, rule := range relation.Relations {
+		placement := nodes.Placement(namef("%s %s", relation.RegionName, location))
+		placement.Attach(magicbean.LocationName(location))
+		edge := region.Has(placement)
+		edge.Attach(magicbean.RuleSource(rule))
+	}
 
-		var attachments ocm.Components
+	for event, rule := range relation.Events {
+		token, err := tokens.Named(name(event))
+		slipup.PanicOnError(err)
+		slipup.PanicOnError(token.Attach(magicbean.Event{}))
+		placement := nodes.Placement(namef("%s %s", relation.RegionName, event))
+		placement.Fixed(token)
+		edge := region.Has(placement)
+		edge.Attach(magicbean.RuleSource(rule))
+	}
 
-		if relation.RegionName == "Root" {
-			attachments.Add(magicbean.WorldGraphRoot{})
-		}
+	var attachments ocm.Components
 
-		if relation.Hint != "" {
-			attachments.Add(magicbean.HintRegion(relation.Hint))
-		}
+	if relation.RegionName == "Root" {
+		attachments.Add(magicbean.WorldGraphRoot{})
+	}
 
-		if relation.AltHint != "" {
-			attachments.Add(magicbean.AltHintRegion(relation.AltHint))
-		}
+	if relation.Hint != "" {
+		attachments.Add(magicbean.HintRegion(relation.Hint))
+	}
 
-		if relation.Dungeon != "" {
-			attachments.Add(magicbean.DungeonName(relation.Dungeon))
-		}
+	if relation.AltHint != "" {
+		attachments.Add(magicbean.AltHintRegion(relation.AltHint))
+	}
+
+	if relation.Dungeon != "" {
+		attachments.Add(magicbean.DungeonName(relation.Dungeon))
+	}
 
-		if relation.IsBossRoom {
-			attachments.Add(magicbean.IsBossRoom{})
+	if relation.IsBossRoom {
+		attachments.Add(magicbean.IsBossRoom{})
+	}
+
+	if relation.Savewarp != "" {
+		attachments.Add(magicbean.Savewarp(relation.Savewarp))
+	}
+
+	if relation.Scene != "" {
+		attachments.Add(magicbean.Scene(relation.Scene))
+	}
+
+	if relation.TimePasses {
+		attachments.Add(magicbean.TimePassess{})
+	}
+
+	return region.AttachFrom(attachments)
+}
+
+// reads the skills file dump-zootr.py writes. A missing file is an error,
+// callers that want to accept every name must skip loading the catalog. Some
+// ootr versions have no glitch table, a kind with nothing cataloged is only
+// warned about
+func LoadSkillCatalog(ctx context.Context, fs fs.FS, paths LoadPaths) (settings.SkillCatalog, error) {
+	var catalog settings.SkillCatalog
+	if paths.Skills == "" {
+		return catalog, slipup.Createf("no skills file to validate trick and glitch names against")
+	}
+
+	for dumped, decodeErr := range paths.readskills(ctx, fs) {
+		if decodeErr != nil {
+			return catalog, decodeErr
 		}
 
-		if relation.Savewarp != "" {
-			attachments.Add(magicbean.Savewarp(relation.Savewarp))
+		skill := settings.Skill{
+			Kind:    settings.SkillTrick,
+			Display: dumped.Display,
+			Tooltip: dumped.Tooltip,
+			Tags:    dumped.Tags,
 		}
 
-		if relation.Scene != "" {
-			attachments.Add(magicbean.Scene(relation.Scene))
+		prefix := "logic_"
+		if dumped.Glitch {
+			skill.Kind, prefix = settings.SkillGlitch, "glitch_"
 		}
+		skill.Name = strings.TrimPrefix(dumped.Name, prefix)
+		catalog.Add(skill)
+	}
 
-		if relation.TimePasses {
-			attachments.Add(magicbean.TimePassess{})
+	for _, kind := range []settings.SkillKind{settings.SkillTrick, settings.SkillGlitch} {
+		if !catalog.Cataloged(kind) {
+			slog.WarnContext(ctx, "no skills cataloged, names are not validated", "kind", kind.String(), "path", paths.Skills)
 		}
+	}
+	return catalog, nil
+}
 
-		return region.AttachFrom(attachments)
-	})
+// names of every token in the tokens file
+func LoadTokenNames(ctx context.Context, fs fs.FS, paths LoadPaths) ([]string, error) {
+	var names []string
+	for item, err := range paths.readtokens(ctx, fs) {
+		if err != nil {
+			return names, err
+		}
+		names = append(names, item.Name)
+	}
+	return names, nil
 }
@@ -0,0 +1,160 @@
+package bootstrap
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sudonters/libzootr/internal/settings"
+	"sudonters/libzootr/magicbean"
+	"sudonters/libzootr/magicbean/tracking"
+	"sudonters/libzootr/mido"
+	"sudonters/libzootr/mido/compiler"
+	"sudonters/libzootr/mido/module"
+	"sudonters/libzootr/mido/objects"
+	"sudonters/libzootr/table"
+	"sudonters/libzootr/table/ocm"
+
+	"github.com/etc-sudonters/substrate/slipup"
+)
+
+// hashes the name and content of every file rules are compiled from so a
+// module compiled from other data is detected as stale
+func DataHash(fsys fs.FS, paths LoadPaths) (uint64, error) {
+	hasher := fnv.New64a()
+	write := func(path string) error {
+		fh, err := fsys.Open(path)
+		if err != nil {
+			return err
+		}
+		defer fh.Close()
+		fmt.Fprintf(hasher, "%s\x00", filepath.Base(path))
+		_, err = io.Copy(hasher, fh)
+		return err
+	}
+
+	for _, path := range []FilePath{paths.Tokens, paths.Placements, paths.Scripts, paths.Skills} {
+		if path == "" {
+			continue
+		}
+		if err := write(path); err != nil {
+			return 0, slipup.Describef(err, "failed to hash %q", path)
+		}
+	}
+
+	// WalkDir visits in lexical order
+	err := filepath.WalkDir(string(paths.Relations), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		return write(path)
+	})
+	if err != nil {
+		return 0, slipup.Describe(err, "failed to hash logic directory")
+	}
+	return hasher.Sum64(), nil
+}
+
+// every compiled rule keyed by its connection's name
+func CompiledModule(entities *ocm.Entities, objs objects.Table, subs []compiler.Bytecode, fingerprint settings.Fingerprint, data uint64) (module.Module, error) {
+	rows, err := entities.Query(table.Load[magicbean.RuleCompiled], table.Load[magicbean.Name])
+	if err != nil {
+		return module.Module{}, slipup.Describe(err, "failed to find compiled rules")
+	}
+
+	compiled := module.Module{
+		Settings:    fingerprint,
+		Data:        data,
+		Objects:     objs,
+		Subroutines: subs,
+		Rules:       make(map[string]compiler.Bytecode, rows.Len()),
+	}
+	for _, tup := range rows.All {
+		name := string(tup.Values[1].(magicbean.Name))
+		compiled.Rules[name] = compiler.Bytecode(tup.Values[0].(magicbean.RuleCompiled))
+	}
+	return compiled, nil
+}
+
+// attaches every rule in a module to its connection instead of compiling
+// them. Entity ids are not stable between imports so every named pointer is
+// relinked to the symbol of the same name and connections the compiler
+// generated are created again from their names. Relinked bytecode is
+// verified before anything is attached.
+func LoadModule(entities *ocm.Entities, env *mido.CompileEnv, loaded *module.Module) error {
+	rows, err := entities.Query(table.Load[magicbean.Name], table.Exists[magicbean.Connection])
+	if err != nil {
+		return slipup.Describe(err, "failed to find connections")
+	}
+	connections := make(map[string]ocm.Entity, rows.Len())
+	for ent, tup := range rows.All {
+		connections[string(tup.Values[0].(magicbean.Name))] = ent
+	}
+
+	var conngen ConnectionGenerator
+	conngen.Symbols, conngen.Objects = env.Symbols, env.Objects
+	if conngen.Nodes, err = tracking.NewNodes(entities); err != nil {
+		return err
+	}
+	if conngen.Tokens, err = tracking.NewTokens(entities); err != nil {
+		return err
+	}
+
+	for name := range loaded.Rules {
+		if _, exists := connections[name]; exists {
+			continue
+		}
+		region, suffix, generated := generatedconnection(name)
+		if !generated {
+			return fmt.Errorf("%w: no connection named %q", module.ErrStale, name)
+		}
+		_, edge := conngen.connect(region, suffix)
+		connections[name] = edge.Entity()
+	}
+
+	if err := relink(env, loaded); err != nil {
+		return err
+	}
+	if err := loaded.Verify(env.Objects.FunctionTable()); err != nil {
+		return err
+	}
+
+	for name, bytecode := range loaded.Rules {
+		entity, _ := entities.Proxy(connections[name])
+		if err := entity.Attach(magicbean.RuleCompiled(bytecode)); err != nil {
+			return slipup.Describef(err, "failed to attach %q", name)
+		}
+	}
+	return nil
+}
+
+// "region -> Place#region#hash" is a connection AddConnectionTo created
+func generatedconnection(name string) (region, suffix string, generated bool) {
+	region, place, split := strings.Cut(name, " -> Place")
+	if !split || !strings.HasPrefix(place, "#"+region+"#") {
+		return "", "", false
+	}
+	return region, place, true
+}
+
+func relink(env *mido.CompileEnv, loaded *module.Module) error {
+	current := objects.TableFrom(env.Objects)
+	values := loaded.Objects.Values()
+	for index, name := range loaded.Names() {
+		symbol := env.Symbols.LookUpByName(name)
+		if symbol == nil {
+			return fmt.Errorf("%w: no symbol named %q", module.ErrStale, name)
+		}
+		ptr, exists := env.Objects.LookUpPtr(symbol)
+		if !exists {
+			return fmt.Errorf("%w: %q is not a pointer", module.ErrStale, name)
+		}
+		values[index] = current.AtIndex(ptr)
+	}
+	return nil
+}
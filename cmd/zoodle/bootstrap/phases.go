@@ -12,6 +12,7 @@ import (
 	"sudonters/libzootr/mido/ast"
 	"sudonters/libzootr/mido/objects"
 	"sudonters/libzootr/mido/optimizer"
+	"sudonters/libzootr/ruleparser"
 	"sudonters/libzootr/table"
 	"sudonters/libzootr/table/ocm"
 )
@@ -32,18 +33,18 @@ func Phase2_ImportFromFiles(ctx context.Context, fs fs.FS, entities *ocm.Entitie
 	return nil
 }
 
-func Phase3_ConfigureCompiler(entities *ocm.Entities, theseSettings *settings.Zootr, options ...mido.ConfigureCompiler) mido.CompileEnv {
+func Phase3_ConfigureCompiler(entities *ocm.Entities, theseSettings *settings.Zootr, skills settings.SkillCatalog, options ...mido.ConfigureCompiler) mido.CompileEnv {
 	defaults := []mido.ConfigureCompiler{
 		mido.CompilerDefaults(),
 		func(env *mido.CompileEnv) {
-			env.Optimize.AddOptimizer(func(env *mido.CompileEnv) ast.Rewriter {
+			env.Optimize.AddOptimizer("InlineSettings", func(env *mido.CompileEnv) ast.Rewriter {
 				return optimizer.InlineSettings(theseSettings, env.Symbols)
 			})
 			slipup.PanicOnError(loadsymbols(entities, env.Symbols))
 			slipup.PanicOnError(loadscripts(entities, env))
 			slipup.PanicOnError(aliassymbols(entities, env.Symbols))
 		},
-		installCompilerFunctions(theseSettings),
+		installCompilerFunctions(theseSettings, skills),
 		installConnectionGenerator(entities),
 		mido.WithBuiltInFunctionDefs(func(*mido.CompileEnv) []objects.BuiltInFunctionDef {
 			return magicbean.CreateBuiltInDefs()
@@ -56,14 +57,27 @@ func Phase3_ConfigureCompiler(entities *ocm.Entities, theseSettings *settings.Zo
 	return mido.NewCompileEnv(defaults...)
 }
 
+// runs every stage over every rule that made it through the previous stage.
+// Diagnostics from all rules are aggregated into a single
+// ruleparser.Diagnostics error
 func Phase4_Compile(entities *ocm.Entities, compiler *mido.CodeGen) error {
-	slipup.PanicOnError(parseall(entities, compiler))
-	slipup.PanicOnError(optimizeall(entities, compiler))
-	slipup.PanicOnError(compileall(entities, compiler))
-	return nil
+	var report ruleparser.Diagnostics
+	for _, stage := range []func(*ocm.Entities, *mido.CodeGen) (ruleparser.Diagnostics, error){
+		parseall, optimizeall, compileall,
+	} {
+		diagnostics, err := stage(entities, compiler)
+		if err != nil {
+			return err
+		}
+		report = append(report, diagnostics...)
+	}
+	return report.Err()
 }
 
-func Phase5_CreateWorld(entities *ocm.Entities, settings *settings.Zootr, objects objects.Table) magicbean.ExplorableWorld {
+func Phase5_CreateWorld(entities *ocm.Entities, settings *settings.Zootr, objects objects.Table) (magicbean.ExplorableWorld, error) {
+	if err := excludelocations(entities, settings); err != nil {
+		return magicbean.ExplorableWorld{}, err
+	}
 	xplore := explorableworldfrom(entities)
-	return xplore
+	return xplore, nil
 }
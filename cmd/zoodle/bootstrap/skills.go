@@ -0,0 +1,85 @@
+package bootstrap
+
+import (
+	"slices"
+	"sudonters/libzootr/internal/settings"
+	"sudonters/libzootr/magicbean"
+	"sudonters/libzootr/mido/ast"
+	"sudonters/libzootr/mido/symbols"
+	"sudonters/libzootr/table"
+	"sudonters/libzootr/table/ocm"
+)
+
+// names of the rules and helpers that directly check each trick or glitch
+type SkillUsage map[settings.SkillKind]map[string][]magicbean.Name
+
+func (this SkillUsage) UnlockedBy(kind settings.SkillKind, name string) []magicbean.Name {
+	return this[kind][name]
+}
+
+func CollectSkillUsage(entities *ocm.Entities, syms *symbols.Table) (SkillUsage, error) {
+	usage := SkillUsage{
+		settings.SkillTrick:  make(map[string][]magicbean.Name),
+		settings.SkillGlitch: make(map[string][]magicbean.Name),
+	}
+
+	checks := make(map[symbols.Index]settings.SkillKind, 2)
+	if symbol := syms.LookUpByName("is_trick_enabled"); symbol != nil {
+		checks[symbol.Index] = settings.SkillTrick
+	}
+	if symbol := syms.LookUpByName("is_glitch_enabled"); symbol != nil {
+		checks[symbol.Index] = settings.SkillGlitch
+	}
+
+	var current magicbean.Name
+	visitor := ast.Visitor{
+		Invoke: func(invoke ast.Invoke, visit ast.Visiting) error {
+			target, isIdent := invoke.Target.(ast.Identifier)
+			kind, isCheck := checks[symbols.Index(target)]
+			if isIdent && isCheck && len(invoke.Args) == 1 {
+				if name, isStr := invoke.Args[0].(ast.String); isStr {
+					users := usage[kind][string(name)]
+					if !slices.Contains(users, current) {
+						usage[kind][string(name)] = append(users, current)
+					}
+					return nil
+				}
+			}
+			return ast.VisitInvoke(invoke, visit)
+		},
+	}
+
+	collect := func(q table.Q, node func(table.Value) ast.Node) error {
+		rows, err := entities.Query(table.Load[magicbean.Name], q)
+		if err != nil {
+			return err
+		}
+		for _, tup := range rows.All {
+			current = tup.Values[0].(magicbean.Name)
+			if err := visitor.Visit(node(tup.Values[1])); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := collect(table.Load[magicbean.ScriptParsed], func(v table.Value) ast.Node {
+		return v.(magicbean.ScriptParsed).Node
+	}); err != nil {
+		return usage, err
+	}
+
+	if err := collect(table.Load[magicbean.RuleParsed], func(v table.Value) ast.Node {
+		return v.(magicbean.RuleParsed).Node
+	}); err != nil {
+		return usage, err
+	}
+
+	for _, named := range usage {
+		for _, users := range named {
+			slices.Sort(users)
+		}
+	}
+
+	return usage, nil
+}
@@ -0,0 +1,43 @@
+package bootstrap
+
+import (
+	"context"
+	"sudonters/libzootr/internal/settings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadSkillCatalogRequiresSkillsFile(t *testing.T) {
+	ctx := context.Background()
+	fsys := fstest.MapFS{}
+	if _, err := LoadSkillCatalog(ctx, fsys, LoadPaths{}); err == nil {
+		t.Error("expected an error without a skills path")
+	}
+	if _, err := LoadSkillCatalog(ctx, fsys, LoadPaths{Skills: "skills.json"}); err == nil {
+		t.Error("expected an error for a missing skills file")
+	}
+}
+
+func TestLoadSkillCatalogReadsDumpedSkills(t *testing.T) {
+	// shaped like dump-zootr.py's data/skills.json
+	fsys := fstest.MapFS{"skills.json": {Data: []byte(`[
+  {"name": "logic_dc_jump", "display": "Dodongo's Cavern Jump", "tooltip": "Jump across the gap.", "tags": ["Dodongo's Cavern"], "glitch": false},
+  {"name": "glitch_isg", "display": "Infinite Sword Glitch", "tooltip": "", "tags": [], "glitch": true}
+]`)}}
+
+	catalog, err := LoadSkillCatalog(context.Background(), fsys, LoadPaths{Skills: "skills.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trick, exists := catalog.Lookup(settings.SkillTrick, "dc_jump")
+	if !exists || trick.Display != "Dodongo's Cavern Jump" {
+		t.Errorf("expected dc_jump trick, got %+v", trick)
+	}
+	if _, exists := catalog.Lookup(settings.SkillGlitch, "isg"); !exists {
+		t.Error("expected isg glitch")
+	}
+	if catalog.Knows(settings.SkillTrick, "not_a_trick") {
+		t.Error("expected unknown trick to be rejected")
+	}
+}
@@ -1,7 +1,10 @@
 package bootstrap
 
 import (
+	"errors"
+	"fmt"
 	"slices"
+	"sudonters/libzootr/internal/settings"
 	"sudonters/libzootr/magicbean"
 	"sudonters/libzootr/table"
 	"sudonters/libzootr/table/ocm"
@@ -35,6 +38,13 @@ func explorableworldfrom(entities *ocm.Entities) magicbean.ExplorableWorld {
 		directed.AddRoot(graph32.Node(root))
 	}
 
+	excluded, excludedErr := entities.Matching(table.Exists[magicbean.Excluded])
+	slipup.PanicOnError(excludedErr)
+	excluding := make(map[ocm.Entity]bool)
+	for entity := range excluded {
+		excluding[entity] = true
+	}
+
 	for entity, tup := range rows.All {
 		trans := tup.Values[2].(magicbean.Connection)
 		directed.AddEdge(graph32.Node(trans.From), graph32.Node(trans.To))
@@ -44,6 +54,7 @@ func explorableworldfrom(entities *ocm.Entities) magicbean.ExplorableWorld {
 			Kind:   tup.Values[1].(magicbean.EdgeKind),
 			Name:   tup.Values[3].(magicbean.Name),
 		}
+		edge.Excluded = edge.Kind == magicbean.EdgePlacement && excluding[trans.To]
 
 		src := tup.Values[4]
 		if src != nil {
@@ -55,3 +66,42 @@ func explorableworldfrom(entities *ocm.Entities) magicbean.ExplorableWorld {
 
 	return world
 }
+
+func excludelocations(entities *ocm.Entities, these *settings.Zootr) error {
+	excluding := slices.Concat(these.DisabledLocations, these.Locations.Disabled)
+	if len(excluding) == 0 {
+		return nil
+	}
+
+	rows, err := entities.Query(table.Load[magicbean.LocationName], table.Exists[magicbean.Placement])
+	if err != nil {
+		return err
+	}
+
+	// every region holding the location, vanilla and MQ alike
+	placements := make(map[magicbean.LocationName][]ocm.Entity, rows.Len())
+	for entity, tup := range rows.All {
+		location := tup.Values[0].(magicbean.LocationName)
+		placements[location] = append(placements[location], entity)
+	}
+
+	var errs []error
+	for _, name := range excluding {
+		holding, exists := placements[magicbean.LocationName(name)]
+		if !exists {
+			errs = append(errs, fmt.Errorf("unknown excluded location %q", name))
+			continue
+		}
+		for _, entity := range holding {
+			proxy, err := entities.Proxy(entity)
+			if err == nil {
+				err = proxy.Attach(magicbean.Excluded{})
+			}
+			if err != nil {
+				errs = append(errs, slipup.Describef(err, "failed to exclude %q", name))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
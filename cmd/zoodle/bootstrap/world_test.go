@@ -0,0 +1,73 @@
+package bootstrap
+
+import (
+	"slices"
+	"strings"
+	"sudonters/libzootr/importers"
+	"sudonters/libzootr/internal/settings"
+	"sudonters/libzootr/magicbean"
+	"sudonters/libzootr/magicbean/tracking"
+	"sudonters/libzootr/table"
+	"testing"
+)
+
+func TestExcludesLocationInEveryRegion(t *testing.T) {
+	_, entities := Phase1_InitializeStorage(nil)
+	set, err := tracking.NewTrackingSet(entities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, relation := range []importers.DumpedRelation{
+		{RegionName: "Root", Exits: map[string]string{"Deku Tree Lobby": "True", "Deku Tree MQ Lobby": "True"}},
+		{RegionName: "Deku Tree Lobby", Relations: map[string]string{"Deku Tree Map Chest": "True", "Deku Tree Compass Chest": "True"}},
+		{RegionName: "Deku Tree MQ Lobby", Relations: map[string]string{"Deku Tree Map Chest": "True"}},
+	} {
+		if err := storeRelation(set.Nodes, set.Tokens, relation); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var these settings.Zootr
+	these.Locations.Disabled = []string{"Deku Tree Map Chest"}
+	if err := excludelocations(entities, &these); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := entities.Query(table.Load[magicbean.Name], table.Exists[magicbean.Excluded])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var excluded []string
+	for _, tup := range rows.All {
+		excluded = append(excluded, string(tup.Values[0].(magicbean.Name)))
+	}
+	slices.Sort(excluded)
+	if expected := []string{"Deku Tree Lobby Deku Tree Map Chest", "Deku Tree MQ Lobby Deku Tree Map Chest"}; !slices.Equal(excluded, expected) {
+		t.Fatalf("expected %v to be excluded, found %v", expected, excluded)
+	}
+
+	edges, err := entities.Query(table.Load[magicbean.EdgeKind])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for entity := range edges.All {
+		proxy, _ := entities.Proxy(entity)
+		proxy.Attach(magicbean.RuleCompiled{})
+	}
+	world := explorableworldfrom(entities)
+	var flagged []string
+	for _, edge := range world.Edges {
+		if edge.Excluded {
+			flagged = append(flagged, string(edge.Name))
+		}
+	}
+	slices.Sort(flagged)
+	if expected := []string{"Deku Tree Lobby -> Deku Tree Lobby Deku Tree Map Chest", "Deku Tree MQ Lobby -> Deku Tree MQ Lobby Deku Tree Map Chest"}; !slices.Equal(flagged, expected) {
+		t.Fatalf("expected edges %v to be excluded, found %v", expected, flagged)
+	}
+
+	these.Locations.Disabled = []string{"Deku Tree Basement Chest"}
+	if err := excludelocations(entities, &these); err == nil || !strings.Contains(err.Error(), "Deku Tree Basement Chest") {
+		t.Fatalf("expected unknown location to be reported, found %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"io/fs"
+	"strconv"
+	"strings"
+	"sudonters/libzootr/internal/settings"
+	"sudonters/libzootr/magicbean"
+	"sudonters/libzootr/mido"
+	"sudonters/libzootr/mido/code"
+	"sudonters/libzootr/mido/compiler"
+	"sudonters/libzootr/mido/objects"
+	"sudonters/libzootr/mido/vm"
+	"sudonters/libzootr/table"
+	"sudonters/libzootr/table/ocm"
+
+	"github.com/etc-sudonters/substrate/dontio"
+	"github.com/etc-sudonters/substrate/stageleft"
+)
+
+var errQuit = errors.New("quit debugger")
+
+// steps through a single rule against a chosen inventory
+func runDebugRule(ctx context.Context, std dontio.Std, opts cliOptions, fs fs.FS) stageleft.ExitCode {
+	flags := flag.NewFlagSet("debug-rule", flag.ContinueOnError)
+	flags.SetOutput(std.Err)
+	child := flags.Bool("child", false, "Evaluate as child instead of adult")
+	starting := flags.Bool("starting", false, "Start from the starting inventory settings create")
+	with := flags.String("with", "", "Comma separated tokens to collect, Name:N collects N")
+	flags.Usage = func() {
+		std.WriteLineErr("usage: zoodle debug-rule [-child] [-starting] [-with Token,Token:N] <edge name>")
+	}
+	if err := flags.Parse(opts.args); err != nil {
+		return stageleft.ExitCode(2)
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return stageleft.ExitCode(2)
+	}
+
+	paths := loadpaths(opts)
+	these := settings.Default()
+	skills, skillsErr := loadskills(ctx, fs, paths, &these)
+	if skillsErr != nil {
+		std.WriteLineErr(skillsErr.Error())
+		return stageleft.ExitCode(2)
+	}
+	generation, _ := setup(ctx, fs, paths, &these, skills, mido.WithMaxOptimizePasses(opts.optimizePasses))
+	generation.Settings = these
+
+	var rule *magicbean.ExplorableEdge
+	for _, edge := range generation.World.Edges {
+		if string(edge.Name) == flags.Arg(0) {
+			rule = &edge
+			break
+		}
+	}
+	if rule == nil {
+		std.WriteLineErr("no edge named %q", flags.Arg(0))
+		return stageleft.ExitCode(1)
+	}
+
+	if *starting {
+		inventory, err := startingInventory(&these, generation.Entities)
+		if err != nil {
+			std.WriteLineErr(err.Error())
+			return stageleft.ExitCode(1)
+		}
+		generation.Inventory = inventory
+	}
+	if err := collectnamed(generation.Entities, &generation.Inventory, *with); err != nil {
+		std.WriteLineErr(err.Error())
+		return stageleft.ExitCode(2)
+	}
+
+	age := AgeAdult
+	if *child {
+		age = AgeChild
+	}
+	funcs, _ := builtins(&generation, age)
+	machine := mido.VM{
+		Objects:     &generation.Objects,
+		Funcs:       funcs.Table(),
+		ChkQty:      funcs.Has,
+		Subroutines: generation.Subroutines,
+		State:       generation.Inventory.Version,
+	}
+	repl := ruleDebugger{
+		std:      std,
+		in:       bufio.NewScanner(std.In),
+		vm:       &machine,
+		debugger: &vm.Debugger{Stepping: true, Ops: map[code.Op]bool{}, Funcs: map[objects.Addr32]bool{}},
+	}
+	repl.debugger.Trace = repl.trace
+	repl.debugger.Pause = repl.pause
+	machine.Debug = repl.debugger.Hook
+
+	bytecode := compiler.Bytecode(rule.Rule)
+	std.WriteLineOut("%s", rule.Name)
+	if rule.Src != "" {
+		std.WriteLineOut("%s", rule.Src)
+	}
+	answer, err := machine.Execute(bytecode)
+	switch {
+	case errors.Is(err, errQuit):
+		return stageleft.ExitSuccess
+	case err != nil:
+		std.WriteLineErr(err.Error())
+		return stageleft.ExitCode(1)
+	}
+	std.WriteLineOut("answered %s, crossed? %t", vm.Step{Objects: &generation.Objects, Bytecode: bytecode}.Describe(answer), machine.Truthy(answer))
+	return stageleft.ExitSuccess
+}
+
+// Token,Token:N
+func collectnamed(entities *ocm.Entities, inventory *magicbean.Inventory, named string) error {
+	if named == "" {
+		return nil
+	}
+	rows, err := entities.Query(table.Load[magicbean.Name], table.Exists[magicbean.Token])
+	if err != nil {
+		return err
+	}
+	tokens := make(map[string]ocm.Entity, rows.Len())
+	for row, tup := range rows.All {
+		tokens[string(tup.Values[0].(magicbean.Name))] = row
+	}
+
+	for _, token := range strings.Split(named, ",") {
+		name, count, counted := strings.Cut(strings.TrimSpace(token), ":")
+		qty := 1.0
+		if counted {
+			if qty, err = strconv.ParseFloat(count, 64); err != nil {
+				return err
+			}
+		}
+		entity, exists := tokens[name]
+		if !exists {
+			return errors.New("no token named " + strconv.Quote(name))
+		}
+		inventory.Collect(entity, qty)
+	}
+	return nil
+}
+
+type ruleDebugger struct {
+	std      dontio.Std
+	in       *bufio.Scanner
+	vm       *mido.VM
+	debugger *vm.Debugger
+}
+
+func (this ruleDebugger) trace(step vm.Step) error {
+	this.std.WriteLineOut("%s", step)
+	return nil
+}
+
+func (this ruleDebugger) pause(step vm.Step) error {
+	for {
+		this.std.Out.Write([]byte("(debug) "))
+		if !this.in.Scan() {
+			return errQuit
+		}
+		command, arg, _ := strings.Cut(strings.TrimSpace(this.in.Text()), " ")
+		switch command {
+		case "", "s", "step":
+			this.debugger.Stepping = true
+			return nil
+		case "c", "continue":
+			this.debugger.Stepping = false
+			return nil
+		case "q", "quit":
+			return errQuit
+		case "stack":
+			if len(step.Stack) == 0 {
+				this.std.WriteLineOut("  empty")
+			}
+			for i := len(step.Stack) - 1; i >= 0; i-- {
+				this.std.WriteLineOut("  %d: %s", i, step.Describe(step.Stack[i]))
+			}
+		case "names":
+			for index, name := range step.Bytecode.Names {
+				this.std.WriteLineOut("  0x%04X: %s %s", index, name, step.Objects.AtIndex(index))
+			}
+		case "dis":
+			this.vm.Dis(this.std.Out, step.Bytecode)
+		case "b", "break":
+			this.breakOn(arg)
+		case "clear":
+			clear(this.debugger.Ops)
+			clear(this.debugger.Funcs)
+		default:
+			this.std.WriteLineOut("step (s), continue (c), break (b) <op or function>, clear, stack, names, dis, quit (q)")
+		}
+	}
+}
+
+func (this ruleDebugger) breakOn(name string) {
+	if def, exists := code.LookUpName(name); exists {
+		this.debugger.Ops[def.Op] = true
+		this.std.WriteLineOut("breaking on %s", def.Name)
+		return
+	}
+	for addr, def := range magicbean.CreateBuiltInDefs() {
+		if def.Name == name {
+			this.debugger.Funcs[objects.Addr32(addr)] = true
+			this.std.WriteLineOut("breaking on %s()", def.Name)
+			return
+		}
+	}
+	this.std.WriteLineOut("no op or function named %q", name)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io/fs"
+	"sudonters/libzootr/internal/settings"
+	"sudonters/libzootr/mido"
+
+	"github.com/etc-sudonters/substrate/dontio"
+	"github.com/etc-sudonters/substrate/stageleft"
+)
+
+// shows how each optimizer rewrote a rule on every pass
+func runExplainRule(ctx context.Context, std dontio.Std, opts cliOptions, fs fs.FS) stageleft.ExitCode {
+	flags := flag.NewFlagSet("explain-rule", flag.ContinueOnError)
+	flags.SetOutput(std.Err)
+	asJson := flags.Bool("json", false, "Write every step as JSON")
+	all := flags.Bool("all", false, "Trace every rule instead of one")
+	width := flags.Int("width", 60, "Break rules across lines at this many columns, 0 never breaks")
+	flags.Usage = func() {
+		std.WriteLineErr("usage: zoodle explain-rule [-json] [-width N] <rule name>")
+		std.WriteLineErr("       zoodle explain-rule [-json] [-width N] -all")
+	}
+
+	if err := flags.Parse(opts.args); err != nil {
+		return stageleft.ExitCode(2)
+	}
+
+	var tracing *mido.Tracing
+	switch {
+	case *all && flags.NArg() == 0:
+		tracing = mido.TraceAll()
+	case !*all && flags.NArg() == 1:
+		tracing = mido.TraceRule(flags.Arg(0))
+	default:
+		flags.Usage()
+		return stageleft.ExitCode(2)
+	}
+	tracing.Width = *width
+
+	paths := loadpaths(opts)
+	these := settings.Default()
+	skills, skillsErr := loadskills(ctx, fs, paths, &these)
+	if skillsErr != nil {
+		std.WriteLineErr(skillsErr.Error())
+		return stageleft.ExitCode(2)
+	}
+	setup(ctx, fs, paths, &these, skills,
+		mido.WithMaxOptimizePasses(opts.optimizePasses),
+		mido.WithOptimizeTracing(tracing),
+	)
+
+	if len(tracing.Traces) == 0 {
+		if *all {
+			std.WriteLineErr("no rules were optimized")
+		} else {
+			std.WriteLineErr("no rule named %q", flags.Arg(0))
+		}
+		return stageleft.ExitCode(1)
+	}
+
+	if *asJson {
+		encoder := json.NewEncoder(std.Out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(tracing.Traces); err != nil {
+			std.WriteLineErr(err.Error())
+			return stageleft.ExitCode(1)
+		}
+		return stageleft.ExitSuccess
+	}
+
+	for _, trace := range tracing.Traces {
+		std.WriteLineOut("%s", trace.Diff())
+	}
+	return stageleft.ExitSuccess
+}
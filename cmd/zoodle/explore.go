@@ -2,12 +2,10 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"math/rand/v2"
 	"slices"
 	"sudonters/libzootr/internal/settings"
 	"sudonters/libzootr/magicbean"
-	"sudonters/libzootr/magicbean/tracking"
 	"sudonters/libzootr/mido"
 	"sudonters/libzootr/mido/objects"
 	"sudonters/libzootr/table"
@@ -35,7 +33,8 @@ func fromStartingAge(start settings.StartingAge) Age {
 	}
 }
 
-func explore(ctx context.Context, xplr *magicbean.Exploration, generation *magicbean.Generation, age Age) magicbean.ExplorationResults {
+// builtins answering from generation's inventory as age
+func builtins(generation *magicbean.Generation, age Age) (magicbean.BuiltIns, magicbean.Pocket) {
 	pockets := magicbean.NewPockets(&generation.Inventory, generation.Entities)
 
 	funcs := magicbean.BuiltIns{}
@@ -44,22 +43,39 @@ func explore(ctx context.Context, xplr *magicbean.Exploration, generation *magic
 	funcs.IsAdult = magicbean.ConstBool(age == AgeAdult)
 	funcs.IsChild = magicbean.ConstBool(age == AgeChild)
 	funcs.IsStartingAge = magicbean.ConstBool(age == fromStartingAge(generation.Settings.Spawns.StartingAge))
+	return funcs, pockets
+}
+
+func explore(ctx context.Context, xplr *magicbean.Exploration, generation *magicbean.Generation, age Age, closures bool) magicbean.ExplorationResults {
+	funcs, pockets := builtins(generation, age)
 
 	std, noStd := dontio.StdFromContext(ctx)
 	if noStd != nil {
 		panic("no std found in context")
 	}
 
-	vm := mido.VM{
-		Objects: &generation.Objects,
-		Funcs:   funcs.Table(),
-		Std:     std,
-		ChkQty:  funcs.Has,
+	if closures {
+		xplr.Evaluator = &mido.Closures{
+			Objects: &generation.Objects,
+			Funcs:   funcs.Table(),
+			Has: func(token objects.Addr32, qty float64) bool {
+				return pockets.Has(ocm.Entity(token), qty)
+			},
+			Subroutines: generation.Subroutines,
+			State:       generation.Inventory.Version,
+		}
+	} else {
+		xplr.Evaluator = &mido.VM{
+			Objects:     &generation.Objects,
+			Funcs:       funcs.Table(),
+			Std:         std,
+			ChkQty:      funcs.Has,
+			Subroutines: generation.Subroutines,
+			State:       generation.Inventory.Version,
+			Profile:     xplr.Profile,
+		}
 	}
 
-	xplr.VM = vm
-	xplr.Objects = &generation.Objects
-
 	return generation.World.ExploreAvailableEdges(ctx, xplr)
 }
 
@@ -78,57 +94,6 @@ func PtrsMatching(entities *ocm.Entities, query ...table.Q) []objects.Object {
 	return ptrs
 }
 
-func CollectStartingItems(generation *magicbean.Generation) {
-	entities := generation.Entities
-	rng := &generation.Rng
-	these := &generation.Settings
-
-	type collecting struct {
-		entity ocm.Entity
-		qty    float64
-	}
-	var starting []collecting
-
-	collect := func(token tracking.Token, qty float64) {
-		starting = append(starting, collecting{token.Entity(), qty})
-	}
-
-	collectOneEach := func(token ...tracking.Token) {
-		new := make([]collecting, len(starting)+len(token))
-		copy(new[len(token):], starting)
-		for i, t := range token {
-			new[i] = collecting{t.Entity(), 1}
-		}
-
-		starting = new
-	}
-
-	tokens, err := tracking.NewTokens(entities)
-	slipup.PanicOnError(err)
-
-	if these.Locations.OpenDoorOfTime {
-		collect(tokens.MustGet("Time Travel"), 1)
-	}
-
-	collectOneEach(
-		tokens.MustGet("Ocarina"),
-		tokens.MustGet("Deku Shield"),
-	)
-
-	collect(tokens.MustGet("Deku Stick (1)"), 10)
-
-	starting = append(starting, collecting{OneOfRandomly(entities, rng, table.Exists[magicbean.Song]), 1})
-	starting = append(starting, collecting{OneOfRandomly(entities, rng, table.Exists[magicbean.DungeonReward]), 1})
-
-	for _, collect := range starting {
-		proxy, _ := entities.Proxy(collect.entity)
-		values, err := proxy.Values(table.ColumnIdFor[magicbean.Name])
-		slipup.PanicOnError(err)
-		fmt.Printf("starting with %f %s\n", collect.qty, values.Values[0].(magicbean.Name))
-		generation.Inventory.Collect(collect.entity, collect.qty)
-	}
-}
-
 func OneOfRandomly(entities *ocm.Entities, rng *rand.Rand, query ...table.Q) ocm.Entity {
 	matched, err := entities.Matching(query...)
 	slipup.PanicOnError(err)
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"sudonters/libzootr/cmd/zoodle/bootstrap"
+	"sudonters/libzootr/cmd/zoodle/lsp"
+	"sudonters/libzootr/internal/settings"
+
+	"github.com/etc-sudonters/substrate/dontio"
+	"github.com/etc-sudonters/substrate/stageleft"
+)
+
+// language server for the logic and helpers files over stdin and stdout
+func runLsp(ctx context.Context, std dontio.Std, opts cliOptions, fsys fs.FS) stageleft.ExitCode {
+	workspace, err := loadworkspace(ctx, fsys, loadpaths(opts))
+	if err != nil {
+		std.WriteLineErr(err.Error())
+		return stageleft.ExitCode(2)
+	}
+
+	if err := lsp.Serve(ctx, workspace, std.In, std.Out); err != nil {
+		std.WriteLineErr(err.Error())
+		return stageleft.ExitCode(1)
+	}
+	return stageleft.ExitSuccess
+}
+
+func loadworkspace(ctx context.Context, fsys fs.FS, paths bootstrap.LoadPaths) (*lsp.Workspace, error) {
+	workspace := lsp.NewWorkspace()
+
+	these := settings.Default()
+	skills, err := loadskills(ctx, fsys, paths, &these)
+	if err != nil {
+		return nil, err
+	}
+	workspace.Skills = skills
+
+	tokens, err := bootstrap.LoadTokenNames(ctx, fsys, paths)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		workspace.AddToken(token)
+	}
+
+	index := func(path string) error {
+		text, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+		_, err = workspace.Update(lsp.FileURI(path), string(text))
+		return err
+	}
+
+	if err := index(paths.Scripts); err != nil {
+		return nil, err
+	}
+
+	err = filepath.WalkDir(paths.Relations, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || filepath.Ext(path) != ".json" {
+			return err
+		}
+		return index(path)
+	})
+	return workspace, err
+}
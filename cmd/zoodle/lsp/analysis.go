@@ -0,0 +1,369 @@
+package lsp
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sudonters/libzootr/internal/settings"
+	"sudonters/libzootr/mido/ast"
+	"sudonters/libzootr/mido/symbols"
+	"sudonters/libzootr/ruleparser"
+
+	"github.com/etc-sudonters/substrate/peruse"
+)
+
+const source = "zoodle"
+
+// syntax errors, rules that cannot be lowered and helper calls with the
+// wrong number of arguments
+func (this *Workspace) Diagnose(uri string) []Diagnostic {
+	doc, index, exists := this.File(uri)
+	if !exists {
+		return nil
+	}
+
+	diagnostics := []Diagnostic{}
+	report := func(rule Rule, severity Severity, span ruleparser.Span, msg string) {
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    doc.Range(rule.value.offsetOf(int(span.Start)), rule.value.offsetOf(int(span.End))),
+			Severity: severity,
+			Source:   source,
+			Message:  msg,
+		})
+	}
+
+	syms := symbols.NewTable()
+	for name, helper := range this.helpers {
+		syms.SetParams(syms.Declare(name, symbols.SCRIPTED_FUNC), helper.Params)
+	}
+	for _, rule := range index.Rules {
+		tree, err := ruleparser.Parse(this.Grammar, rule.Source())
+		var syntax ruleparser.SyntaxErrors
+		if errors.As(err, &syntax) {
+			for _, spanErr := range syntax {
+				report(rule, SeverityError, spanErr.At, spanErr.Cause.Error())
+			}
+			continue
+		}
+
+		if _, err := ast.Lower(&syms, tree); err != nil {
+			span, found := ruleparser.SpanOf(err)
+			if !found {
+				span = tree.Location()
+			}
+			report(rule, SeverityError, span, err.Error())
+			continue
+		}
+
+		ruleparser.Visitor{
+			Call: func(call *ruleparser.Call, visit func(ruleparser.Tree) error) error {
+				for _, arg := range call.Args {
+					visit(arg)
+				}
+				callee, isIdent := call.Callee.(*ruleparser.Identifier)
+				if !isIdent {
+					return nil
+				}
+				if helper, isHelper := this.Helper(callee.Value); isHelper && len(helper.Params) != len(call.Args) {
+					report(rule, SeverityWarning, call.Location(), fmt.Sprintf(
+						"%s expects %d argument(s) but was given %d", helper.Decl, len(helper.Params), len(call.Args),
+					))
+				}
+				return nil
+			},
+		}.Visit(tree)
+	}
+
+	return diagnostics
+}
+
+// what is under the cursor in a rule
+type cursor struct {
+	doc  *Document
+	rule Rule
+	// innermost first
+	path []ruleparser.Tree
+}
+
+func (this *Workspace) cursorAt(uri string, pos Position) (cursor, bool) {
+	var at cursor
+	doc, index, exists := this.File(uri)
+	if !exists {
+		return at, false
+	}
+
+	offset := doc.Offset(pos)
+	rule, found := index.RuleAt(offset)
+	if !found {
+		return at, false
+	}
+	at.doc, at.rule = doc, rule
+
+	if i, inSource := rule.value.indexOf(offset); inSource {
+		// the valid parts of a broken rule are still useful
+		tree, _ := ruleparser.Parse(this.Grammar, rule.Source())
+		at.path = enclosing(tree, peruse.Pos(i))
+	}
+	return at, true
+}
+
+func (this cursor) rangeOf(tree ruleparser.Tree) *Range {
+	span := tree.Location()
+	r := this.doc.Range(this.rule.value.offsetOf(int(span.Start)), this.rule.value.offsetOf(int(span.End)))
+	return &r
+}
+
+func (this *Workspace) Hover(uri string, pos Position) (Hover, bool) {
+	at, found := this.cursorAt(uri, pos)
+	if !found || len(at.path) == 0 {
+		return Hover{}, false
+	}
+
+	var text strings.Builder
+	switch target := at.path[0].(type) {
+	case *ruleparser.Identifier:
+		name := target.Value
+		if helper, isHelper := this.Helper(name); isHelper {
+			this.describeHelper(&text, helper, at.path)
+			break
+		}
+		if trick, isTrick := strings.CutPrefix(name, "logic_"); isTrick {
+			this.describeSkill(&text, settings.SkillTrick, trick)
+			break
+		}
+		if glitch, isGlitch := strings.CutPrefix(name, "glitch_"); isGlitch {
+			this.describeSkill(&text, settings.SkillGlitch, glitch)
+			break
+		}
+		if token, isToken := this.Token(name); isToken {
+			fmt.Fprintf(&text, "token `%s`", token)
+			break
+		}
+		if _, isEvent := this.Event(name); isEvent {
+			fmt.Fprintf(&text, "event `%s`", name)
+			break
+		}
+		for _, setting := range this.Settings {
+			if setting.Name == name {
+				fmt.Fprintf(&text, "setting `%s` (%s)", name, setting.Kind)
+				break
+			}
+		}
+	case *ruleparser.Literal:
+		if str, isStr := target.AsString(); isStr {
+			if _, isRegion := this.Region(str); isRegion {
+				fmt.Fprintf(&text, "region `%s`", str)
+			}
+		}
+	}
+
+	if text.Len() == 0 {
+		return Hover{}, false
+	}
+	return Hover{MarkupContent{"markdown", text.String()}, at.rangeOf(at.path[0])}, true
+}
+
+func (this *Workspace) describeHelper(text *strings.Builder, helper Helper, path []ruleparser.Tree) {
+	printer := ruleparser.Printer{Width: 80}
+	body, err := ruleparser.Parse(this.Grammar, helper.Body)
+	if err != nil {
+		fmt.Fprintf(text, "```python\n%s\n```\n\n%s", helper.Decl, err)
+		return
+	}
+
+	fmt.Fprintf(text, "```python\n%s:\n    %s\n```", helper.Decl, indent(printer.Print(body)))
+	if len(path) < 2 {
+		return
+	}
+
+	call, isCall := path[1].(*ruleparser.Call)
+	if !isCall || call.Callee != path[0] || len(call.Args) != len(helper.Params) || len(call.Args) == 0 {
+		return
+	}
+
+	bindings := make(map[string]ruleparser.Tree, len(call.Args))
+	for i, arg := range call.Args {
+		if keyword, isKeyword := arg.(*ruleparser.Keyword); isKeyword {
+			bindings[keyword.Name] = keyword.Value
+			continue
+		}
+		bindings[helper.Params[i]] = arg
+	}
+	fmt.Fprintf(text, "\n\ninlined:\n```python\n%s\n```", printer.Print(substitute(body, bindings)))
+}
+
+func (this *Workspace) describeSkill(text *strings.Builder, kind settings.SkillKind, name string) {
+	fmt.Fprintf(text, "%s `%s`", kind, name)
+	if skill, known := this.Skills.Lookup(kind, name); known {
+		fmt.Fprintf(text, "\n\n**%s**\n\n%s", skill.Display, skill.Tooltip)
+	} else if !this.Skills.Knows(kind, name) {
+		fmt.Fprintf(text, "\n\nunknown %s", kind)
+	}
+}
+
+// helpers and events from identifiers, regions from exits and 'quoted'
+// region names
+func (this *Workspace) Definition(uri string, pos Position) (Location, bool) {
+	at, found := this.cursorAt(uri, pos)
+	if !found {
+		return Location{}, false
+	}
+
+	offset := at.doc.Offset(pos)
+	if at.rule.key.start <= offset && offset <= at.rule.key.end {
+		if at.rule.Kind == RuleExit {
+			return this.Region(at.rule.Name)
+		}
+		return Location{}, false
+	}
+
+	if len(at.path) == 0 {
+		return Location{}, false
+	}
+
+	switch target := at.path[0].(type) {
+	case *ruleparser.Identifier:
+		if helper, isHelper := this.Helper(target.Value); isHelper {
+			return helper.At, true
+		}
+		return this.Event(target.Value)
+	case *ruleparser.Literal:
+		if str, isStr := target.AsString(); isStr {
+			return this.Region(str)
+		}
+	}
+	return Location{}, false
+}
+
+// names that begin with the identifier under the cursor
+func (this *Workspace) Complete(uri string, pos Position) []CompletionItem {
+	doc, index, exists := this.File(uri)
+	if !exists {
+		return nil
+	}
+
+	offset := doc.Offset(pos)
+	rule, found := index.RuleAt(offset)
+	if !found {
+		return nil
+	}
+	i, inSource := rule.value.indexOf(offset)
+	if !inSource {
+		return nil
+	}
+
+	src := rule.Source()
+	begin := i
+	for begin > 0 && ruleparser.IsIdentifier("_"+src[begin-1:i]) {
+		begin--
+	}
+	prefix := src[begin:i]
+
+	items := []CompletionItem{}
+	offer := func(kind CompletionKind, detail string, names ...string) {
+		for _, name := range names {
+			if strings.HasPrefix(name, prefix) {
+				items = append(items, CompletionItem{name, kind, detail})
+			}
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(this.helpers)) {
+		offer(CompletionFunction, this.helpers[name].Decl, name)
+	}
+	offer(CompletionConstant, "token", slices.Sorted(maps.Keys(this.tokens))...)
+	offer(CompletionEvent, "event", slices.Sorted(maps.Keys(this.events))...)
+	for _, setting := range this.Settings {
+		offer(CompletionVariable, "setting", setting.Name)
+	}
+	for _, skill := range this.Skills.All(settings.SkillTrick) {
+		offer(CompletionVariable, skill.Display, "logic_"+skill.Name)
+	}
+	for _, skill := range this.Skills.All(settings.SkillGlitch) {
+		offer(CompletionVariable, skill.Display, "glitch_"+skill.Name)
+	}
+	return items
+}
+
+// every tree containing pos, innermost first
+func enclosing(tree ruleparser.Tree, pos peruse.Pos) []ruleparser.Tree {
+	span := tree.Location()
+	if pos < span.Start || pos > span.End {
+		return nil
+	}
+
+	for _, child := range children(tree) {
+		if path := enclosing(child, pos); path != nil {
+			return append(path, tree)
+		}
+	}
+	return []ruleparser.Tree{tree}
+}
+
+func children(tree ruleparser.Tree) []ruleparser.Tree {
+	switch tree := tree.(type) {
+	case *ruleparser.BinOp:
+		return []ruleparser.Tree{tree.Left, tree.Right}
+	case *ruleparser.BoolOp:
+		return []ruleparser.Tree{tree.Left, tree.Right}
+	case *ruleparser.Call:
+		return append([]ruleparser.Tree{tree.Callee}, tree.Args...)
+	case *ruleparser.Subscript:
+		return []ruleparser.Tree{tree.Target, tree.Index}
+	case *ruleparser.Tuple:
+		return tree.Elems
+	case *ruleparser.UnaryOp:
+		return []ruleparser.Tree{tree.Target}
+	case *ruleparser.Attribute:
+		return []ruleparser.Tree{tree.Target}
+	case *ruleparser.Keyword:
+		return []ruleparser.Tree{tree.Value}
+	case *ruleparser.Invalid:
+		return tree.Partial
+	default:
+		return nil
+	}
+}
+
+// copies tree replacing bound identifiers
+func substitute(tree ruleparser.Tree, bindings map[string]ruleparser.Tree) ruleparser.Tree {
+	each := func(trees []ruleparser.Tree) []ruleparser.Tree {
+		substituted := make([]ruleparser.Tree, len(trees))
+		for i := range trees {
+			substituted[i] = substitute(trees[i], bindings)
+		}
+		return substituted
+	}
+
+	switch tree := tree.(type) {
+	case *ruleparser.Identifier:
+		if bound, isBound := bindings[tree.Value]; isBound {
+			return bound
+		}
+		return tree
+	case *ruleparser.BinOp:
+		return &ruleparser.BinOp{Left: substitute(tree.Left, bindings), Op: tree.Op, Right: substitute(tree.Right, bindings)}
+	case *ruleparser.BoolOp:
+		return &ruleparser.BoolOp{Left: substitute(tree.Left, bindings), Op: tree.Op, Right: substitute(tree.Right, bindings)}
+	case *ruleparser.Call:
+		return &ruleparser.Call{Callee: substitute(tree.Callee, bindings), Args: each(tree.Args)}
+	case *ruleparser.Subscript:
+		return &ruleparser.Subscript{Target: substitute(tree.Target, bindings), Index: substitute(tree.Index, bindings)}
+	case *ruleparser.Tuple:
+		return &ruleparser.Tuple{Elems: each(tree.Elems)}
+	case *ruleparser.UnaryOp:
+		return &ruleparser.UnaryOp{Op: tree.Op, Target: substitute(tree.Target, bindings)}
+	case *ruleparser.Attribute:
+		return &ruleparser.Attribute{Target: substitute(tree.Target, bindings), Name: tree.Name}
+	case *ruleparser.Keyword:
+		return &ruleparser.Keyword{Name: tree.Name, Value: substitute(tree.Value, bindings)}
+	default:
+		return tree
+	}
+}
+
+func indent(text string) string {
+	return strings.ReplaceAll(text, "\n", "\n    ")
+}
@@ -0,0 +1,163 @@
+package lsp
+
+import (
+	"net/url"
+	"path/filepath"
+	"sort"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+func FileURI(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String()
+}
+
+// the text of a file along with a line table, positions use the utf-16
+// character offsets the protocol requires
+type Document struct {
+	URI   string
+	Text  string
+	lines []int
+}
+
+func NewDocument(uri, text string) *Document {
+	doc := &Document{URI: uri, Text: text, lines: []int{0}}
+	for i := range len(text) {
+		if text[i] == '\n' {
+			doc.lines = append(doc.lines, i+1)
+		}
+	}
+	return doc
+}
+
+func (this *Document) Position(offset int) Position {
+	offset = min(max(offset, 0), len(this.Text))
+	line := sort.Search(len(this.lines), func(i int) bool { return this.lines[i] > offset }) - 1
+	character := 0
+	for _, r := range this.Text[this.lines[line]:offset] {
+		character += utf16.RuneLen(r)
+	}
+	return Position{Line: line, Character: character}
+}
+
+func (this *Document) Offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(this.lines) {
+		return len(this.Text)
+	}
+	offset := this.lines[pos.Line]
+	for character := 0; character < pos.Character && offset < len(this.Text); {
+		r, size := utf8.DecodeRuneInString(this.Text[offset:])
+		if r == '\n' {
+			break
+		}
+		character += utf16.RuneLen(r)
+		offset += size
+	}
+	return offset
+}
+
+func (this *Document) Range(start, end int) Range {
+	return Range{this.Position(start), this.Position(end)}
+}
+
+func (this *Document) Location(start, end int) Location {
+	return Location{this.URI, this.Range(start, end)}
+}
+
+type RuleKind string
+
+const (
+	RuleExit     RuleKind = "exit"
+	RuleLocation RuleKind = "location"
+	RuleEvent    RuleKind = "event"
+	RuleHelper   RuleKind = "helper"
+)
+
+var ruleKinds = map[string]RuleKind{
+	"exits":     RuleExit,
+	"locations": RuleLocation,
+	"events":    RuleEvent,
+}
+
+// a rule string embedded in a logic or helpers document. Name is the exit's
+// destination, the location or event name or the helper's declaration
+type Rule struct {
+	Kind   RuleKind
+	Region string
+	Name   string
+	key    node
+	value  node
+}
+
+func (this Rule) Source() string {
+	return this.value.text
+}
+
+type Region struct {
+	Name string
+	name node
+}
+
+// what a logic or helpers document declares
+type Index struct {
+	Regions []Region
+	Rules   []Rule
+}
+
+// logic documents are an array of regions, the helpers document is an
+// object of declarations to bodies
+func IndexDocument(text string) (Index, error) {
+	var index Index
+	root, err := parseJson(text)
+	if err != nil {
+		return index, err
+	}
+
+	switch root.kind {
+	case nodeObject:
+		for _, helper := range root.members {
+			if helper.value.kind == nodeString {
+				index.Rules = append(index.Rules, Rule{Kind: RuleHelper, Name: helper.key.text, key: helper.key, value: helper.value})
+			}
+		}
+	case nodeArray:
+		for _, elem := range root.elems {
+			name, hasName := elem.member("region_name")
+			if !hasName || name.kind != nodeString {
+				continue
+			}
+			index.Regions = append(index.Regions, Region{name.text, name})
+
+			for _, m := range elem.members {
+				kind, isRules := ruleKinds[m.key.text]
+				if !isRules {
+					continue
+				}
+				for _, rule := range m.value.members {
+					if rule.value.kind == nodeString {
+						index.Rules = append(index.Rules, Rule{kind, name.text, rule.key.text, rule.key, rule.value})
+					}
+				}
+			}
+		}
+	}
+
+	return index, nil
+}
+
+// the rule whose key or value contains offset
+func (this Index) RuleAt(offset int) (Rule, bool) {
+	for _, rule := range this.Rules {
+		if rule.key.start <= offset && offset <= rule.value.end {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
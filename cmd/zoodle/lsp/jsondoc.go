@@ -0,0 +1,292 @@
+package lsp
+
+import (
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// internal/json streams and forgets where things were, editors need to know
+// exactly where every string is so logic documents are read with this small
+// positional parser instead. Like OOTR it allows # and // comments and
+// trailing commas. OOTR also joins lines before decoding so strings may span
+// several lines.
+
+type nodeKind uint8
+
+const (
+	_ nodeKind = iota
+	nodeObject
+	nodeArray
+	nodeString
+	nodeScalar
+)
+
+// a json value and the raw byte range it occupies
+type node struct {
+	kind       nodeKind
+	start, end int
+	// decoded string and the raw offset of each decoded byte, followed by
+	// the offset of the closing quote
+	text    string
+	offsets []int
+	members []member
+	elems   []node
+}
+
+type member struct {
+	key, value node
+}
+
+func (this node) member(key string) (node, bool) {
+	for _, m := range this.members {
+		if m.key.text == key {
+			return m.value, true
+		}
+	}
+	return node{}, false
+}
+
+// raw offset of the decoded byte at index, index may be one past the end
+func (this node) offsetOf(index int) int {
+	return this.offsets[min(max(index, 0), len(this.offsets)-1)]
+}
+
+// decoded index of the raw offset if it's inside the string's quotes
+func (this node) indexOf(offset int) (int, bool) {
+	if this.kind != nodeString || offset <= this.start || offset >= this.end {
+		return 0, false
+	}
+	for i, at := range this.offsets {
+		if at >= offset {
+			return i, true
+		}
+	}
+	return len(this.text), true
+}
+
+type jsonError struct {
+	At  int
+	Msg string
+}
+
+func (this jsonError) Error() string {
+	return this.Msg
+}
+
+func parseJson(text string) (node, error) {
+	s := jsonScanner{text: text}
+	value, err := s.value()
+	if err != nil {
+		return value, err
+	}
+	s.skip()
+	if s.pos != len(s.text) {
+		return value, s.errorf("unexpected %q after document", s.text[s.pos])
+	}
+	return value, nil
+}
+
+type jsonScanner struct {
+	text string
+	pos  int
+}
+
+func (this *jsonScanner) errorf(tpl string, v ...any) error {
+	return jsonError{this.pos, fmt.Sprintf(tpl, v...)}
+}
+
+func (this *jsonScanner) skip() {
+	for this.pos < len(this.text) {
+		switch c := this.text[this.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			this.pos++
+		case c == '#' || c == '/' && this.pos+1 < len(this.text) && this.text[this.pos+1] == '/':
+			for this.pos < len(this.text) && this.text[this.pos] != '\n' {
+				this.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (this *jsonScanner) value() (node, error) {
+	this.skip()
+	if this.pos >= len(this.text) {
+		return node{}, this.errorf("unexpected end of document")
+	}
+
+	switch this.text[this.pos] {
+	case '{':
+		return this.object()
+	case '[':
+		return this.array()
+	case '"':
+		return this.string()
+	default:
+		return this.scalar()
+	}
+}
+
+func (this *jsonScanner) object() (node, error) {
+	obj := node{kind: nodeObject, start: this.pos}
+	this.pos++
+	for {
+		this.skip()
+		if this.pos >= len(this.text) {
+			return obj, this.errorf("unclosed object")
+		}
+		if this.text[this.pos] == '}' {
+			this.pos++
+			obj.end = this.pos
+			return obj, nil
+		}
+		if this.text[this.pos] != '"' {
+			return obj, this.errorf("expected property name")
+		}
+
+		key, err := this.string()
+		if err != nil {
+			return obj, err
+		}
+		this.skip()
+		if this.pos >= len(this.text) || this.text[this.pos] != ':' {
+			return obj, this.errorf("expected ':' after %q", key.text)
+		}
+		this.pos++
+
+		value, err := this.value()
+		if err != nil {
+			return obj, err
+		}
+		obj.members = append(obj.members, member{key, value})
+
+		if err := this.separator('}'); err != nil {
+			return obj, err
+		}
+	}
+}
+
+func (this *jsonScanner) array() (node, error) {
+	arr := node{kind: nodeArray, start: this.pos}
+	this.pos++
+	for {
+		this.skip()
+		if this.pos >= len(this.text) {
+			return arr, this.errorf("unclosed array")
+		}
+		if this.text[this.pos] == ']' {
+			this.pos++
+			arr.end = this.pos
+			return arr, nil
+		}
+
+		elem, err := this.value()
+		if err != nil {
+			return arr, err
+		}
+		arr.elems = append(arr.elems, elem)
+
+		if err := this.separator(']'); err != nil {
+			return arr, err
+		}
+	}
+}
+
+func (this *jsonScanner) separator(closing byte) error {
+	this.skip()
+	if this.pos < len(this.text) {
+		switch this.text[this.pos] {
+		case ',':
+			this.pos++
+			return nil
+		case closing:
+			return nil
+		}
+	}
+	return this.errorf("expected ',' or '%c'", closing)
+}
+
+func (this *jsonScanner) string() (node, error) {
+	str := node{kind: nodeString, start: this.pos}
+	var decoded []byte
+	this.pos++
+
+	for this.pos < len(this.text) {
+		at := this.pos
+		switch c := this.text[this.pos]; c {
+		case '"':
+			str.offsets = append(str.offsets, at)
+			this.pos++
+			str.end = this.pos
+			str.text = string(decoded)
+			return str, nil
+		case '\\':
+			if this.pos+1 >= len(this.text) {
+				return str, this.errorf("unterminated string")
+			}
+			var r rune
+			switch esc := this.text[this.pos+1]; esc {
+			case 'n':
+				r = '\n'
+			case 't':
+				r = '\t'
+			case 'r':
+				r = '\r'
+			case 'b':
+				r = '\b'
+			case 'f':
+				r = '\f'
+			case 'u':
+				if this.pos+6 > len(this.text) {
+					return str, this.errorf("invalid unicode escape")
+				}
+				code, err := strconv.ParseUint(this.text[this.pos+2:this.pos+6], 16, 32)
+				if err != nil {
+					return str, this.errorf("invalid unicode escape")
+				}
+				r = rune(code)
+				this.pos += 4
+			default:
+				r = rune(esc)
+			}
+			this.pos += 2
+			encoded := utf8.AppendRune(nil, r)
+			decoded = append(decoded, encoded...)
+			for range encoded {
+				str.offsets = append(str.offsets, at)
+			}
+		default:
+			decoded = append(decoded, c)
+			str.offsets = append(str.offsets, at)
+			this.pos++
+		}
+	}
+
+	return str, this.errorf("unterminated string")
+}
+
+func (this *jsonScanner) scalar() (node, error) {
+	scalar := node{kind: nodeScalar, start: this.pos}
+scan:
+	for this.pos < len(this.text) {
+		switch this.text[this.pos] {
+		case ',', '}', ']', ' ', '\t', '\n', '\r', '#':
+			break scan
+		}
+		this.pos++
+	}
+	scalar.end = this.pos
+	scalar.text = this.text[scalar.start:scalar.end]
+	switch scalar.text {
+	case "":
+		return scalar, this.errorf("unexpected %q", this.text[this.pos])
+	case "true", "false", "null":
+		return scalar, nil
+	}
+	if _, err := strconv.ParseFloat(scalar.text, 64); err != nil {
+		return scalar, jsonError{scalar.start, fmt.Sprintf("invalid value %q", scalar.text)}
+	}
+	return scalar, nil
+}
@@ -0,0 +1,216 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const helpersURI = "file:///logic/helpers.json"
+const helpersText = `{
+    # comments are allowed
+    "can_use(item)": "is_adult and item or is_child and has(item, 2)",
+    "has_bottle": "Bottle",
+}`
+
+const forestURI = "file:///logic/World/Overworld.json"
+const forestText = `[
+    {
+        "region_name": "Kokiri Forest",
+        "events": {
+            "Showed Mido Sword": "is_child and Kokiri_Sword"
+        },
+        "locations": {
+            "KF Kokiri Sword Chest": "can_use(Kokiri_Sword) and and has_bottle",
+            "KF Mido Chest": "can_use(Slingshot, 1)"
+        },
+        "exits": {
+            "Lost Woods": "at('Kokiri Forest', Showed_Mido_Sword)"
+        }
+    },
+    {
+        "region_name": "Lost Woods",
+        "exits": {
+            "Kokiri Forest": "True"
+        }
+    }
+]`
+
+func workspace(t *testing.T) *Workspace {
+	t.Helper()
+	ws := NewWorkspace()
+	ws.AddToken("Kokiri Sword")
+	ws.AddToken("Slingshot")
+	for uri, text := range map[string]string{helpersURI: helpersText, forestURI: forestText} {
+		if _, err := ws.Update(uri, text); err != nil {
+			t.Fatalf("could not index %s: %s", uri, err)
+		}
+	}
+	return ws
+}
+
+// position of the nth byte of needle in the forest document
+func at(t *testing.T, text, needle string, nth int) Position {
+	t.Helper()
+	offset := strings.Index(text, needle)
+	if offset == -1 {
+		t.Fatalf("%q not in document", needle)
+	}
+	return NewDocument("", text).Position(offset + nth)
+}
+
+func TestDiagnosticsPointIntoRules(t *testing.T) {
+	ws := workspace(t)
+	diagnostics := ws.Diagnose(forestURI)
+	if len(diagnostics) != 2 {
+		t.Fatalf("expected 2 diagnostics but found %d: %+v", len(diagnostics), diagnostics)
+	}
+
+	syntax := diagnostics[0]
+	if expected := at(t, forestText, "and has_bottle", 0); syntax.Range.Start != expected || syntax.Severity != SeverityError {
+		t.Errorf("expected syntax error at %+v, found %+v", expected, syntax)
+	}
+
+	arity := diagnostics[1]
+	if expected := at(t, forestText, "can_use(Slingshot, 1)", 0); arity.Range.Start != expected || arity.Severity != SeverityWarning {
+		t.Errorf("expected arity warning at %+v, found %+v", expected, arity)
+	}
+
+	if diagnostics := ws.Diagnose(helpersURI); len(diagnostics) != 0 {
+		t.Errorf("expected helpers to be clean, found %+v", diagnostics)
+	}
+}
+
+func TestHoverInlinesHelper(t *testing.T) {
+	ws := workspace(t)
+	hover, found := ws.Hover(forestURI, at(t, forestText, "can_use(Kokiri_Sword)", 2))
+	if !found {
+		t.Fatal("expected hover for can_use")
+	}
+
+	for _, expected := range []string{
+		"can_use(item):\n    is_adult and item or is_child and has(item, 2)",
+		"is_adult and Kokiri_Sword or is_child and has(Kokiri_Sword, 2)",
+	} {
+		if !strings.Contains(hover.Contents.Value, expected) {
+			t.Errorf("expected hover to contain %q:\n%s", expected, hover.Contents.Value)
+		}
+	}
+
+	token, found := ws.Hover(forestURI, at(t, forestText, "Kokiri_Sword\"", 0))
+	if !found || !strings.Contains(token.Contents.Value, "token `Kokiri Sword`") {
+		t.Errorf("expected hover to name the token, found %+v", token)
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	ws := workspace(t)
+	helpers := NewDocument(helpersURI, helpersText)
+	forest := NewDocument(forestURI, forestText)
+
+	cases := []struct {
+		name     string
+		from     Position
+		uri      string
+		expected Position
+	}{
+		{"helper", at(t, forestText, "has_bottle", 3), helpersURI, helpers.Position(strings.Index(helpersText, `"has_bottle"`))},
+		{"exit", at(t, forestText, `"Lost Woods": "at`, 4), forestURI, forest.Position(strings.LastIndex(forestText, `"Lost Woods"`))},
+		{"region literal", at(t, forestText, "'Kokiri Forest'", 3), forestURI, forest.Position(strings.Index(forestText, `"Kokiri Forest"`))},
+		{"event", at(t, forestText, "Showed_Mido", 1), forestURI, forest.Position(strings.Index(forestText, `"Showed Mido`))},
+	}
+
+	for _, c := range cases {
+		location, found := ws.Definition(forestURI, c.from)
+		if !found {
+			t.Errorf("%s: no definition found", c.name)
+			continue
+		}
+		if location.URI != c.uri || location.Range.Start != c.expected {
+			t.Errorf("%s: expected %s %+v, found %+v", c.name, c.uri, c.expected, location)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	ws := workspace(t)
+	items := ws.Complete(forestURI, at(t, forestText, "Kokiri_Sword\"", 3))
+	labels := make([]string, len(items))
+	for i := range items {
+		labels[i] = items[i].Label
+	}
+	if len(labels) != 1 || labels[0] != "Kokiri_Sword" {
+		t.Fatalf("expected to complete Kokiri_Sword, found %v", labels)
+	}
+
+	items = ws.Complete(forestURI, at(t, forestText, "has_bottle", 2))
+	if len(items) != 1 || items[0].Label != "has_bottle" || items[0].Kind != CompletionFunction {
+		t.Fatalf("expected to complete has_bottle, found %+v", items)
+	}
+}
+
+func TestServe(t *testing.T) {
+	var in bytes.Buffer
+	send := func(msg string) {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(msg), msg)
+	}
+
+	broken, _ := json.Marshal(`[{"region_name": "Broken", "exits": {"Nowhere": "is_adult and"}}]`)
+	send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	send(`{"jsonrpc":"2.0","method":"initialized","params":{}}`)
+	send(fmt.Sprintf(`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///broken.json","version":1,"text":%s}}}`, broken))
+	send(`{"jsonrpc":"2.0","id":2,"method":"textDocument/unknown","params":{}}`)
+	send(`{"jsonrpc":"2.0","id":3,"method":"shutdown"}`)
+	send(`{"jsonrpc":"2.0","method":"exit"}`)
+
+	var out bytes.Buffer
+	if err := Serve(context.Background(), NewWorkspace(), &in, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	replies := textproto.NewReader(bufio.NewReader(&out))
+	var messages []map[string]any
+	for {
+		headers, err := replies.ReadMIMEHeader()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		length, _ := strconv.Atoi(headers.Get("Content-Length"))
+		body := make([]byte, length)
+		if _, err := io.ReadFull(replies.R, body); err != nil {
+			t.Fatal(err)
+		}
+		var msg map[string]any
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, msg)
+	}
+
+	if len(messages) != 4 {
+		t.Fatalf("expected 4 messages but found %d: %v", len(messages), messages)
+	}
+	if messages[1]["method"] != "textDocument/publishDiagnostics" {
+		t.Fatalf("expected diagnostics to be published on open, found %v", messages[1])
+	}
+	diagnostics := messages[1]["params"].(map[string]any)["diagnostics"].([]any)
+	if len(diagnostics) != 1 {
+		t.Fatalf("expected a single diagnostic, found %v", diagnostics)
+	}
+	if _, isErr := messages[2]["error"]; !isErr {
+		t.Fatalf("expected unknown method to fail, found %v", messages[2])
+	}
+	if result, hasResult := messages[3]["result"]; !hasResult || result != nil {
+		t.Fatalf("expected null shutdown result, found %v", messages[3])
+	}
+}
@@ -0,0 +1,95 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+const (
+	codeParseError     = -32700
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+// a request or notification from the client, notifications have no ID
+type request struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (this *responseError) Error() string {
+	return fmt.Sprintf("%d: %s", this.Code, this.Message)
+}
+
+// reads and writes base protocol messages: headers, a blank line and then
+// Content-Length bytes of json
+type conn struct {
+	in  *textproto.Reader
+	out io.Writer
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{textproto.NewReader(bufio.NewReader(r)), w}
+}
+
+func (this *conn) read() (request, error) {
+	var req request
+	headers, err := this.in.ReadMIMEHeader()
+	if err != nil {
+		return req, err
+	}
+
+	length, err := strconv.Atoi(strings.TrimSpace(headers.Get("Content-Length")))
+	if err != nil {
+		return req, fmt.Errorf("invalid Content-Length: %w", err)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(this.in.R, body); err != nil {
+		return req, err
+	}
+
+	if err := json.Unmarshal(body, &req); err != nil {
+		return req, &responseError{codeParseError, err.Error()}
+	}
+	return req, nil
+}
+
+func (this *conn) reply(id *json.RawMessage, result any, err *responseError) error {
+	msg := map[string]any{"jsonrpc": "2.0", "id": id}
+	if err != nil {
+		msg["error"] = err
+	} else {
+		msg["result"] = result
+	}
+	return this.write(msg)
+}
+
+func (this *conn) notify(method string, params any) error {
+	return this.write(map[string]any{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (this *conn) write(msg any) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(this.out, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = this.out.Write(body)
+	return err
+}
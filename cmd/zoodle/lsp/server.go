@@ -0,0 +1,154 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+)
+
+// serves the workspace over r and w until the client sends exit, r is
+// exhausted or ctx is canceled
+func Serve(ctx context.Context, workspace *Workspace, r io.Reader, w io.Writer) error {
+	server := server{workspace, newConn(r, w)}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		req, err := server.conn.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		var malformed *responseError
+		if errors.As(err, &malformed) {
+			if err := server.conn.reply(nil, nil, malformed); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if req.Method == "exit" {
+			return nil
+		}
+
+		result, reqErr := server.handle(ctx, req)
+		if req.ID == nil {
+			if reqErr != nil {
+				slog.WarnContext(ctx, "failed to handle notification", "method", req.Method, "err", reqErr)
+			}
+			continue
+		}
+		if err := server.conn.reply(req.ID, result, reqErr); err != nil {
+			return err
+		}
+	}
+}
+
+type server struct {
+	workspace *Workspace
+	conn      *conn
+}
+
+func (this server) handle(ctx context.Context, req request) (any, *responseError) {
+	switch req.Method {
+	case "initialize":
+		var result initializeResult
+		result.ServerInfo.Name = "zoodle"
+		result.Capabilities.TextDocumentSync = 1 // full
+		result.Capabilities.HoverProvider = true
+		result.Capabilities.DefinitionProvider = true
+		result.Capabilities.CompletionProvider.TriggerCharacters = []string{"(", " ", "_"}
+		return result, nil
+	case "shutdown":
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := decode(req, &params); err != nil {
+			return nil, err
+		}
+		return nil, this.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := decode(req, &params); err != nil {
+			return nil, err
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		return nil, this.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := decode(req, &params); err != nil {
+			return nil, err
+		}
+		return nil, this.publish(params.TextDocument.URI, []Diagnostic{})
+	case "textDocument/hover":
+		var params positionParams
+		if err := decode(req, &params); err != nil {
+			return nil, err
+		}
+		if hover, found := this.workspace.Hover(params.TextDocument.URI, params.Position); found {
+			return hover, nil
+		}
+		return nil, nil
+	case "textDocument/definition":
+		var params positionParams
+		if err := decode(req, &params); err != nil {
+			return nil, err
+		}
+		if location, found := this.workspace.Definition(params.TextDocument.URI, params.Position); found {
+			return location, nil
+		}
+		return nil, nil
+	case "textDocument/completion":
+		var params positionParams
+		if err := decode(req, &params); err != nil {
+			return nil, err
+		}
+		return completionList{Items: this.workspace.Complete(params.TextDocument.URI, params.Position)}, nil
+	default:
+		if req.ID == nil {
+			// initialized, $/cancelRequest, etc
+			return nil, nil
+		}
+		return nil, &responseError{codeMethodNotFound, req.Method}
+	}
+}
+
+func (this server) update(uri, text string) *responseError {
+	doc, err := this.workspace.Update(uri, text)
+	if err == nil {
+		return this.publish(uri, this.workspace.Diagnose(uri))
+	}
+
+	var unreadable jsonError
+	if !errors.As(err, &unreadable) {
+		return &responseError{codeInternalError, err.Error()}
+	}
+	return this.publish(uri, []Diagnostic{{
+		Range:    doc.Range(unreadable.At, unreadable.At+1),
+		Severity: SeverityError,
+		Source:   source,
+		Message:  unreadable.Msg,
+	}})
+}
+
+func (this server) publish(uri string, diagnostics []Diagnostic) *responseError {
+	err := this.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{uri, diagnostics})
+	if err != nil {
+		return &responseError{codeInternalError, err.Error()}
+	}
+	return nil
+}
+
+func decode(req request, params any) *responseError {
+	if err := json.Unmarshal(req.Params, params); err != nil {
+		return &responseError{codeInvalidParams, err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package lsp
+
+import (
+	"maps"
+	"slices"
+	"sudonters/libzootr/internal/settings"
+	"sudonters/libzootr/mido/optimizer"
+	"sudonters/libzootr/mido/symbols"
+	"sudonters/libzootr/ruleparser"
+
+	"github.com/etc-sudonters/substrate/peruse"
+)
+
+// a helper from the ScriptedFunctions document
+type Helper struct {
+	Name, Decl, Body string
+	Params           []string
+	At               Location
+}
+
+// everything the server knows about: every indexed logic and helpers
+// document plus the token, setting and trick names rules may refer to
+type Workspace struct {
+	Grammar  peruse.Grammar[ruleparser.Tree]
+	Settings []settings.Described
+	Skills   settings.SkillCatalog
+
+	files   map[string]indexed
+	tokens  map[string]string
+	helpers map[string]Helper
+	regions map[string]Location
+	events  map[string]Location
+}
+
+type indexed struct {
+	doc   *Document
+	index Index
+}
+
+func NewWorkspace() *Workspace {
+	return &Workspace{
+		Grammar:  ruleparser.NewRulesGrammar(),
+		Settings: settings.Catalog(),
+		files:    make(map[string]indexed),
+		tokens:   make(map[string]string),
+		helpers:  make(map[string]Helper),
+		regions:  make(map[string]Location),
+		events:   make(map[string]Location),
+	}
+}
+
+// tokens are referred to by their escaped name, e.g. Goron_Tunic
+func (this *Workspace) AddToken(name string) {
+	this.tokens[symbols.Escape(name)] = name
+}
+
+// indexes text as the contents of uri. If text is not a readable document
+// the previous contents remain indexed and the returned document holds the
+// unreadable text
+func (this *Workspace) Update(uri, text string) (*Document, error) {
+	doc := NewDocument(uri, text)
+	index, err := IndexDocument(text)
+	if err != nil {
+		return doc, err
+	}
+	this.files[uri] = indexed{doc, index}
+	this.rebuild()
+	return doc, nil
+}
+
+func (this *Workspace) File(uri string) (*Document, Index, bool) {
+	file, exists := this.files[uri]
+	return file.doc, file.index, exists
+}
+
+func (this *Workspace) Helper(name string) (Helper, bool) {
+	helper, exists := this.helpers[name]
+	return helper, exists
+}
+
+func (this *Workspace) Region(name string) (Location, bool) {
+	region, exists := this.regions[name]
+	return region, exists
+}
+
+// events are referred to by their escaped name
+func (this *Workspace) Event(name string) (Location, bool) {
+	event, exists := this.events[name]
+	return event, exists
+}
+
+func (this *Workspace) Token(name string) (string, bool) {
+	token, exists := this.tokens[name]
+	return token, exists
+}
+
+func (this *Workspace) rebuild() {
+	clear(this.helpers)
+	clear(this.regions)
+	clear(this.events)
+
+	for _, uri := range slices.Sorted(maps.Keys(this.files)) {
+		file := this.files[uri]
+		for _, region := range file.index.Regions {
+			this.regions[region.Name] = file.doc.Location(region.name.start, region.name.end)
+		}
+
+		for _, rule := range file.index.Rules {
+			switch rule.Kind {
+			case RuleEvent:
+				this.events[symbols.Escape(rule.Name)] = file.doc.Location(rule.key.start, rule.key.end)
+			case RuleHelper:
+				helper := Helper{
+					Name:   optimizer.FastScriptNameFromDecl(rule.Name),
+					Decl:   rule.Name,
+					Body:   rule.Source(),
+					Params: this.params(rule.Name),
+					At:     file.doc.Location(rule.key.start, rule.key.end),
+				}
+				this.helpers[helper.Name] = helper
+			}
+		}
+	}
+}
+
+func (this *Workspace) params(decl string) []string {
+	tree, err := ruleparser.Parse(this.Grammar, decl)
+	if err != nil {
+		return nil
+	}
+	call, isCall := tree.(*ruleparser.Call)
+	if !isCall {
+		return nil
+	}
+
+	params := make([]string, 0, len(call.Args))
+	for _, arg := range call.Args {
+		if ident, isIdent := arg.(*ruleparser.Identifier); isIdent {
+			params = append(params, ident.Value)
+		}
+	}
+	return params
+}
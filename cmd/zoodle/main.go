@@ -11,6 +11,7 @@ import (
 	"runtime/debug"
 	"runtime/pprof"
 	"sudonters/libzootr/cmd/cmdlib"
+	"sudonters/libzootr/mido"
 
 	"github.com/etc-sudonters/substrate/dontio"
 	"github.com/etc-sudonters/substrate/files"
@@ -82,7 +83,17 @@ type cliOptions struct {
 	dataDir   string
 	includeMq bool
 	profile   string
-	logging   *cmdlib.LoggingConfig
+	// cap on optimization passes per rule
+	optimizePasses int
+	logging        *cmdlib.LoggingConfig
+	command        string
+	args           []string
+	// evaluate rules with vm.Closures instead of the bytecode VM
+	closures bool
+	// where to write per rule pprof samples, enables VM profiling
+	ruleProfile string
+	// accept every trick and glitch name without a skills file
+	uncheckedSkills bool
 }
 
 func (opts *cliOptions) init(flags *flag.FlagSet, args []string) error {
@@ -90,6 +101,10 @@ func (opts *cliOptions) init(flags *flag.FlagSet, args []string) error {
 	flags.StringVar(&opts.dataDir, "d", "", "Directory where data files are stored")
 	flags.StringVar(&opts.profile, "p", "", "profile file name")
 	flags.BoolVar(&opts.includeMq, "M", false, "Whether or not to include MQ data")
+	flags.IntVar(&opts.optimizePasses, "optimize-passes", mido.DefaultMaxOptimizePasses, "Most optimization passes a single rule may take")
+	flags.BoolVar(&opts.closures, "closures", false, "Evaluate rules with compiled closures instead of the bytecode VM")
+	flags.StringVar(&opts.ruleProfile, "rule-profile", "", "Profile each rule the VM executes and write pprof samples labelled by rule to this file")
+	flags.BoolVar(&opts.uncheckedSkills, "unchecked-skills", false, "Accept every trick and glitch name instead of validating them against skills.json")
 	opts.logging.AddFlags(flags)
 
 	flagErr := flags.Parse(args)
@@ -97,6 +112,20 @@ func (opts *cliOptions) init(flags *flag.FlagSet, args []string) error {
 		return flagErr
 	}
 
+	opts.command, opts.args = "explore", flags.Args()
+	if len(opts.args) > 0 {
+		opts.command, opts.args = opts.args[0], opts.args[1:]
+	}
+
+	cmd, exists := commands[opts.command]
+	if !exists {
+		return fmt.Errorf("unknown command %q", opts.command)
+	}
+
+	if !cmd.needsLogic {
+		return nil
+	}
+
 	if opts.logicDir == "" {
 		flagErr = errors.Join(flagErr, missingRequired("l"))
 	}
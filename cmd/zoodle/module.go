@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"sudonters/libzootr/cmd/zoodle/bootstrap"
+	"sudonters/libzootr/internal/settings"
+	"sudonters/libzootr/magicbean/tracking"
+	"sudonters/libzootr/mido"
+	"sudonters/libzootr/mido/module"
+
+	"github.com/etc-sudonters/substrate/dontio"
+	"github.com/etc-sudonters/substrate/slipup"
+	"github.com/etc-sudonters/substrate/stageleft"
+)
+
+// writes compiled logic to a module file or checks one still matches the
+// current settings and data
+func runModule(ctx context.Context, std dontio.Std, opts cliOptions, fs fs.FS) stageleft.ExitCode {
+	flags := flag.NewFlagSet("module", flag.ContinueOnError)
+	flags.SetOutput(std.Err)
+	flags.Usage = func() {
+		std.WriteLineErr("usage: zoodle module write <path>")
+		std.WriteLineErr("       zoodle module check <path>")
+	}
+	if err := flags.Parse(opts.args); err != nil {
+		return stageleft.ExitCode(2)
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return stageleft.ExitCode(2)
+	}
+
+	paths := loadpaths(opts)
+	these := settings.Default()
+	skills, skillsErr := loadskills(ctx, fs, paths, &these)
+	if skillsErr != nil {
+		std.WriteLineErr(skillsErr.Error())
+		return stageleft.ExitCode(2)
+	}
+	data, dataErr := bootstrap.DataHash(fs, paths)
+	if dataErr != nil {
+		std.WriteLineErr(dataErr.Error())
+		return stageleft.ExitCode(1)
+	}
+
+	switch path := flags.Arg(1); flags.Arg(0) {
+	case "write":
+		return writeModule(ctx, std, opts, fs, paths, &these, skills, data, path)
+	case "check":
+		return checkModule(ctx, std, fs, paths, &these, skills, data, path)
+	default:
+		flags.Usage()
+		return stageleft.ExitCode(2)
+	}
+}
+
+func writeModule(ctx context.Context, std dontio.Std, opts cliOptions, fs fs.FS, paths bootstrap.LoadPaths, these *settings.Zootr, skills settings.SkillCatalog, data uint64, path string) stageleft.ExitCode {
+	generation, _ := setup(ctx, fs, paths, these, skills, mido.WithMaxOptimizePasses(opts.optimizePasses))
+	compiled, compiledErr := bootstrap.CompiledModule(
+		generation.Entities, generation.Objects, generation.Subroutines, these.LogicFingerprint(), data,
+	)
+	slipup.PanicOnError(compiledErr)
+
+	fh, createErr := os.Create(path)
+	if createErr != nil {
+		std.WriteLineErr(createErr.Error())
+		return stageleft.ExitCode(1)
+	}
+	defer fh.Close()
+	if err := module.Write(fh, compiled); err != nil {
+		std.WriteLineErr("%s: %s", path, err)
+		return stageleft.ExitCode(1)
+	}
+
+	std.WriteLineOut("%s: wrote %d rules", path, len(compiled.Rules))
+	return stageleft.ExitSuccess
+}
+
+func checkModule(ctx context.Context, std dontio.Std, fs fs.FS, paths bootstrap.LoadPaths, these *settings.Zootr, skills settings.SkillCatalog, data uint64, path string) stageleft.ExitCode {
+	fh, openErr := os.Open(path)
+	if openErr != nil {
+		std.WriteLineErr(openErr.Error())
+		return stageleft.ExitCode(1)
+	}
+	defer fh.Close()
+
+	loaded, readErr := module.Read(fh)
+	if readErr != nil {
+		std.WriteLineErr("%s: %s", path, readErr)
+		return stageleft.ExitCode(1)
+	}
+	if err := loaded.Check(these.LogicFingerprint(), data); err != nil {
+		std.WriteLineErr("%s: %s", path, err)
+		return stageleft.ExitCode(1)
+	}
+
+	_, entities := bootstrap.Phase1_InitializeStorage(nil)
+	trackSet, trackingErr := tracking.NewTrackingSet(entities)
+	slipup.PanicOnError(trackingErr)
+	slipup.PanicOnError(bootstrap.Phase2_ImportFromFiles(ctx, fs, entities, &trackSet, paths))
+	env := bootstrap.Phase3_ConfigureCompiler(entities, these, skills)
+	if err := bootstrap.LoadModule(entities, &env, &loaded); err != nil {
+		std.WriteLineErr("%s: %s", path, err)
+		if errors.Is(err, module.ErrStale) || errors.Is(err, module.ErrCorrupt) {
+			return stageleft.ExitCode(1)
+		}
+		panic(err)
+	}
+	if _, err := bootstrap.Phase5_CreateWorld(entities, these, loaded.Objects); err != nil {
+		std.WriteLineErr("%s: %s", path, err)
+		return stageleft.ExitCode(1)
+	}
+
+	std.WriteLineOut("%s: %d rules, %d subroutines, %d objects", path,
+		len(loaded.Rules), len(loaded.Subroutines), len(loaded.Objects.Values()))
+	return stageleft.ExitSuccess
+}
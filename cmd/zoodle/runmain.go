@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"io/fs"
+	"log/slog"
 	"math/rand/v2"
+	"os"
 	"path/filepath"
 	"sudonters/libzootr/internal/settings"
 	"sudonters/libzootr/magicbean/tracking"
@@ -18,45 +20,134 @@ import (
 	"sudonters/libzootr/magicbean"
 	"sudonters/libzootr/mido"
 	"sudonters/libzootr/mido/objects"
+	"sudonters/libzootr/table/ocm"
 )
 
+type command struct {
+	needsLogic bool
+	run        func(context.Context, dontio.Std, cliOptions, fs.FS) stageleft.ExitCode
+}
+
+var commands = map[string]command{
+	"debug-rule":   {needsLogic: true, run: runDebugRule},
+	"explain-rule": {needsLogic: true, run: runExplainRule},
+	"explore":      {needsLogic: true, run: runExplore},
+	"lsp":          {needsLogic: true, run: runLsp},
+	"module":       {needsLogic: true, run: runModule},
+	"settings":     {needsLogic: false, run: runSettings},
+	"tricks":       {needsLogic: true, run: runTricks},
+}
+
 func runMain(ctx context.Context, std dontio.Std, opts cliOptions, fs fs.FS) stageleft.ExitCode {
-	paths := bootstrap.LoadPaths{
-		Tokens:     filepath.Join(opts.dataDir, "items.json"),
-		Placements: filepath.Join(opts.dataDir, "locations.json"),
-		Scripts:    filepath.Join(opts.logicDir, "..", "helpers.json"),
-		Relations:  opts.logicDir,
+	return commands[opts.command].run(ctx, std, opts, fs)
+}
+
+func runExplore(ctx context.Context, std dontio.Std, opts cliOptions, fs fs.FS) stageleft.ExitCode {
+	if opts.ruleProfile != "" && opts.closures {
+		std.WriteLineErr("-rule-profile profiles the bytecode VM and cannot be used with -closures")
+		return stageleft.ExitCode(2)
 	}
+	paths := loadpaths(opts)
 
 	theseSettings := settings.Default()
 	theseSettings.Seed = 0x76E76E14E9691280
 	theseSettings.Shuffling.OcarinaNotes = true
 	theseSettings.Spawns.StartingAge = settings.StartAgeAdult
 	theseSettings.Locations.OpenDoorOfTime = true
-	generation := setup(ctx, fs, paths, &theseSettings)
+	skills, skillsErr := loadskills(ctx, fs, paths, &theseSettings)
+	if skillsErr != nil {
+		std.WriteLineErr(skillsErr.Error())
+		return stageleft.ExitCode(2)
+	}
+	generation, _ := setup(ctx, fs, paths, &theseSettings, skills, mido.WithMaxOptimizePasses(opts.optimizePasses))
 	generation.Settings = theseSettings
-	CollectStartingItems(&generation)
+	starting, startingErr := startingInventory(&theseSettings, generation.Entities)
+	if startingErr != nil {
+		std.WriteLineErr(startingErr.Error())
+		return stageleft.ExitCode(1)
+	}
+	generation.Inventory = starting
 	visited := bitset32.Bitset{}
 	workset := generation.World.Graph.Roots()
 	xplr := magicbean.Exploration{
 		Visited: &visited,
 		Workset: &workset,
 	}
-	results := explore(ctx, &xplr, &generation, AgeAdult)
+	if opts.ruleProfile != "" {
+		xplr.Profile = mido.NewProfile(magicbean.CreateBuiltInDefs())
+	}
+	results := explore(ctx, &xplr, &generation, AgeAdult, opts.closures)
 	std.WriteLineOut("Visited %d", visited.Len())
 	std.WriteLineOut("Reached %d", results.Reached.Len())
 	std.WriteLineOut("Pending %d", results.Pending.Len())
+	std.WriteLineOut("Excluded %d", results.Excluded.Len())
+	if xplr.Profile != nil {
+		if err := writeRuleProfile(std, xplr.Profile, opts.ruleProfile); err != nil {
+			std.WriteLineErr(err.Error())
+			return stageleft.ExitCode(1)
+		}
+	}
 	return stageleft.ExitCode(0)
 }
 
-func setup(ctx context.Context, fs fs.FS, paths bootstrap.LoadPaths, settings *settings.Zootr) (generation magicbean.Generation) {
+// sorted report to stdout, pprof samples to path
+func writeRuleProfile(std dontio.Std, profile *mido.Profile, path string) error {
+	std.WriteLineOut("")
+	if err := profile.WriteReport(std.Out); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return profile.WritePprof(f)
+}
+
+func loadpaths(opts cliOptions) bootstrap.LoadPaths {
+	paths := bootstrap.LoadPaths{
+		Tokens:     filepath.Join(opts.dataDir, "items.json"),
+		Placements: filepath.Join(opts.dataDir, "locations.json"),
+		Scripts:    filepath.Join(opts.logicDir, "..", "helpers.json"),
+		Relations:  opts.logicDir,
+	}
+
+	if !opts.uncheckedSkills {
+		paths.Skills = filepath.Join(opts.dataDir, "skills.json")
+	}
+
+	return paths
+}
+
+// without a skills file only -unchecked-skills accepts every name
+func loadskills(ctx context.Context, fs fs.FS, paths bootstrap.LoadPaths, these *settings.Zootr) (settings.SkillCatalog, error) {
+	if paths.Skills == "" {
+		slog.WarnContext(ctx, "-unchecked-skills is set, trick and glitch names are not validated")
+		return settings.SkillCatalog{}, nil
+	}
+	skills, err := bootstrap.LoadSkillCatalog(ctx, fs, paths)
+	if err != nil {
+		return skills, err
+	}
+	return skills, skills.Validate(these.Skills)
+}
+
+func startingInventory(these *settings.Zootr, entities *ocm.Entities) (magicbean.Inventory, error) {
+	tokens, err := tracking.NewTokens(entities)
+	if err != nil {
+		return magicbean.NewInventory(), err
+	}
+	return tracking.StartingInventory(these, tokens)
+}
+
+func setup(ctx context.Context, fs fs.FS, paths bootstrap.LoadPaths, settings *settings.Zootr, skills settings.SkillCatalog, options ...mido.ConfigureCompiler) (generation magicbean.Generation, env mido.CompileEnv) {
 	tbl, entities := bootstrap.Phase1_InitializeStorage(nil)
 	_ = tbl
 	trackSet, trackingErr := tracking.NewTrackingSet(entities)
 	slipup.PanicOnError(trackingErr)
 	slipup.PanicOnError(bootstrap.Phase2_ImportFromFiles(ctx, fs, entities, &trackSet, paths))
 
-	compileEnv := bootstrap.Phase3_ConfigureCompiler(entities, settings)
+	compileEnv := bootstrap.Phase3_ConfigureCompiler(entities, settings, skills, options...)
 
 	codegen := mido.Compiler(&compileEnv)
 
@@ -64,13 +155,15 @@ func setup(ctx context.Context, fs fs.FS, paths bootstrap.LoadPaths, settings *s
 		entities, &codegen,
 	))
 
-	world := bootstrap.Phase5_CreateWorld(entities, settings, objects.TableFrom(compileEnv.Objects))
+	world, worldErr := bootstrap.Phase5_CreateWorld(entities, settings, objects.TableFrom(compileEnv.Objects))
+	slipup.PanicOnError(worldErr)
 
 	generation.Entities = entities
 	generation.World = world
 	generation.Objects = objects.TableFrom(compileEnv.Objects)
+	generation.Subroutines = codegen.Subroutines()
 	generation.Inventory = magicbean.NewInventory()
 	generation.Rng = *rand.New(rng.NewXoshiro256PPFromU64(settings.Seed))
 
-	return generation
+	return generation, compileEnv
 }
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io/fs"
+	"strings"
+	"sudonters/libzootr/internal/settings"
+
+	"github.com/etc-sudonters/substrate/dontio"
+	"github.com/etc-sudonters/substrate/stageleft"
+)
+
+func runSettings(ctx context.Context, std dontio.Std, opts cliOptions, _ fs.FS) stageleft.ExitCode {
+	if len(opts.args) == 0 {
+		std.WriteLineErr("settings expects a subcommand: diff, fingerprint, presets")
+		return stageleft.ExitCode(2)
+	}
+
+	switch sub, args := opts.args[0], opts.args[1:]; sub {
+	case "diff":
+		return settingsDiff(std, args)
+	case "fingerprint":
+		if len(args) == 0 {
+			args = []string{"default"}
+		}
+		for _, name := range args {
+			preset, err := settings.LoadPreset(name)
+			if err != nil {
+				std.WriteLineErr(err.Error())
+				return stageleft.ExitCode(2)
+			}
+			std.WriteLineOut("%s: %016x", name, uint64(preset.LogicFingerprint()))
+		}
+		return stageleft.ExitSuccess
+	case "presets":
+		for _, name := range settings.PresetNames() {
+			std.WriteLineOut(name)
+		}
+		return stageleft.ExitSuccess
+	default:
+		std.WriteLineErr("unknown settings subcommand %q", sub)
+		return stageleft.ExitCode(2)
+	}
+}
+
+func settingsDiff(std dontio.Std, args []string) stageleft.ExitCode {
+	flags := flag.NewFlagSet("settings diff", flag.ContinueOnError)
+	flags.SetOutput(std.Err)
+	asJson := flags.Bool("json", false, "Write differences as JSON")
+	flags.Usage = func() {
+		std.WriteLineErr("usage: zoodle settings diff [-json] <preset> <preset>")
+		std.WriteLineErr("presets: %s", strings.Join(settings.PresetNames(), ", "))
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return stageleft.ExitCode(2)
+	}
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return stageleft.ExitCode(2)
+	}
+
+	old, oldErr := settings.LoadPreset(flags.Arg(0))
+	new, newErr := settings.LoadPreset(flags.Arg(1))
+	if oldErr != nil || newErr != nil {
+		for _, err := range []error{oldErr, newErr} {
+			if err != nil {
+				std.WriteLineErr(err.Error())
+			}
+		}
+		return stageleft.ExitCode(2)
+	}
+
+	diffs := settings.Diff(&old, &new)
+	if *asJson {
+		if diffs == nil {
+			diffs = []settings.Difference{}
+		}
+		encoder := json.NewEncoder(std.Out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(diffs); err != nil {
+			std.WriteLineErr(err.Error())
+			return stageleft.ExitCode(1)
+		}
+		return stageleft.ExitSuccess
+	}
+
+	for _, diff := range diffs {
+		std.WriteLineOut("%s: %s -> %s", diff.Name, diff.Old, diff.New)
+	}
+	return stageleft.ExitSuccess
+}
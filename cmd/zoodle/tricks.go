@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"io/fs"
+	"maps"
+	"slices"
+	"sudonters/libzootr/cmd/zoodle/bootstrap"
+	"sudonters/libzootr/internal/settings"
+	"sudonters/libzootr/mido"
+
+	"github.com/etc-sudonters/substrate/dontio"
+	"github.com/etc-sudonters/substrate/stageleft"
+)
+
+// lists every trick and glitch along with the rules that check it
+func runTricks(ctx context.Context, std dontio.Std, opts cliOptions, fs fs.FS) stageleft.ExitCode {
+	paths := loadpaths(opts)
+	these := settings.Default()
+	skills, skillsErr := loadskills(ctx, fs, paths, &these)
+	if skillsErr != nil {
+		std.WriteLineErr(skillsErr.Error())
+		return stageleft.ExitCode(2)
+	}
+
+	generation, env := setup(ctx, fs, paths, &these, skills, mido.WithMaxOptimizePasses(opts.optimizePasses))
+	usage, usageErr := bootstrap.CollectSkillUsage(generation.Entities, env.Symbols)
+	if usageErr != nil {
+		std.WriteLineErr(usageErr.Error())
+		return stageleft.ExitCode(1)
+	}
+
+	for _, kind := range []settings.SkillKind{settings.SkillTrick, settings.SkillGlitch} {
+		names := slices.Collect(maps.Keys(usage[kind]))
+		for _, skill := range skills.All(kind) {
+			names = append(names, skill.Name)
+		}
+		slices.Sort(names)
+
+		for _, name := range slices.Compact(names) {
+			enabled := these.Skills.Tricks[name]
+			if kind == settings.SkillGlitch {
+				enabled = these.Skills.Glitches[name]
+			}
+
+			std.WriteLineOut("%s %s enabled=%t", kind, name, enabled)
+			if skill, known := skills.Lookup(kind, name); known && skill.Display != "" {
+				std.WriteLineOut("\t%s", skill.Display)
+			}
+			for _, rule := range usage.UnlockedBy(kind, name) {
+				std.WriteLineOut("\t-> %s", rule)
+			}
+		}
+	}
+
+	return stageleft.ExitSuccess
+}
@@ -43,6 +43,7 @@ def main(args: argparse.Namespace) -> int:
         output / "data" / "locations.json",
         rearrange_location_table(LocationList.location_table),
     )
+    dump_to_file("skills", output / "data" / "skills.json", collect_skills())
     copy_logic_dir(zootr / "data" / "World", output / "logic" / "glitchless")
     copy_logic_dir(zootr / "data" / "Glitched World",
                    output / "logic" / "glitched")
@@ -97,6 +98,37 @@ def copy_file(src: pathlib.Path, dest: pathlib.Path) -> None:
         d.write(s.read())
 
 
+def _find_table(names: tuple[tuple[str, str], ...]) -> dict:
+    import importlib
+    for module, attr in names:
+        try:
+            return getattr(importlib.import_module(module), attr)
+        except (ImportError, AttributeError):
+            continue
+    return {}
+
+
+def collect_skills() -> Any:
+    # newer releases moved the tables out of SettingsList
+    tricks = _find_table((("LogicTricks", "known_logic_tricks"),
+                          ("SettingsList", "logic_tricks")))
+    glitches = _find_table((("LogicGlitches", "known_logic_glitches"),
+                            ("SettingsList", "logic_glitches")))
+    if not tricks:
+        raise RuntimeError("could not find the logic trick table")
+
+    return [{
+        "name": info["name"],
+        "display": display,
+        "tooltip": " ".join(info.get("tooltip", "").split()),
+        "tags": list(info.get("tags", ())),
+        "glitch": glitch,
+    }
+        for glitch, table in ((False, tricks), (True, glitches))
+        for display, info in table.items()
+    ]
+
+
 def rearrange_location_table(tbl) -> Any:
     return [{
         "name": name,
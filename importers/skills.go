@@ -0,0 +1,102 @@
+package importers
+
+import (
+	"io"
+	"iter"
+	"sudonters/libzootr/internal/json"
+
+	"github.com/etc-sudonters/substrate/slipup"
+)
+
+var DumpSkills = &DumpedSkills{}
+
+type DumpedSkill struct {
+	// setting name including its logic_ or glitch_ prefix
+	Name, Display, Tooltip string
+	Tags                   []string
+	Glitch                 bool
+}
+
+// imports data/skills.json generated by dump-zootr.py
+type DumpedSkills struct{}
+
+// yields tricks and glitches from the reader until the reader is completely
+// consumed or an error occurs. If an error occurs then an empty DumpedSkill is
+// yielded and the error will not be nil. Iteration may be canceled from the
+// provided context
+//
+//	for skill, err := importers.DumpSkills.ImportFrom(ctx, skillReader) {
+//	    if err != nil {
+//	        return err
+//	    }
+//	    storeSkill(skill)
+//	}
+func (this *DumpedSkills) ImportFrom(ctx ctx, r io.Reader) iter.Seq2[DumpedSkill, error] {
+	return func(yield func(DumpedSkill, error) bool) {
+		var emptySkill DumpedSkill
+		parser := json.ParserFrom(r)
+		skills, notSkillArray := parser.ReadArray()
+		if notSkillArray != nil {
+			yield(emptySkill, notSkillArray)
+			return
+		}
+		for skills.More() {
+			select {
+			case <-ctx.Done():
+				yield(emptySkill, ctx.Err())
+				return
+			default:
+				obj, notSkillErr := skills.ReadObject()
+				if notSkillErr != nil {
+					yield(emptySkill, notSkillErr)
+					return
+				}
+
+				dumped, dumpErr := dumpOneSkill(ctx, obj)
+				if !yield(dumped, dumpErr) || dumpErr != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+func dumpOneSkill(ctx ctx, obj *json.ObjectParser) (DumpedSkill, error) {
+	var skill DumpedSkill
+
+	for obj.More() {
+		select {
+		case <-ctx.Done():
+			return skill, ctx.Err()
+		default:
+			property, propertyErr := obj.ReadPropertyName()
+			if propertyErr != nil {
+				return skill, propertyErr
+			}
+
+			var readErr error
+			switch property {
+			case "name":
+				skill.Name, readErr = obj.ReadString()
+			case "display":
+				skill.Display, readErr = obj.ReadString()
+			case "tooltip":
+				skill.Tooltip, readErr = obj.ReadString()
+			case "tags":
+				skill.Tags, readErr = json.ReadStringSlice(obj)
+			case "glitch":
+				skill.Glitch, readErr = obj.ReadBool()
+			default:
+				readErr = slipup.Createf("unknown property %s", property)
+			}
+
+			if readErr != nil {
+				return skill, slipup.Describef(
+					readErr, "failed while reading skill %#v", skill,
+				)
+			}
+		}
+	}
+
+	return skill, obj.ReadEnd()
+}
@@ -0,0 +1,49 @@
+package importers
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const sampleDumpedSkills string = `
+[
+  {
+    "name": "logic_dc_jump",
+    "display": "Dodongo's Cavern Spike Trap Room Jump without Hover Boots",
+    "tooltip": "The jump is adult Link only.",
+    "tags": ["Dodongo's Cavern", "Vanilla Dungeons"]
+  },
+  {
+    "name": "glitch_isg",
+    "display": "Infinite Sword Glitch",
+    "tags": [],
+    "glitch": true
+  }
+]
+`
+
+func TestImportsDumpedSkills(t *testing.T) {
+	var skills []DumpedSkill
+	for skill, err := range DumpSkills.ImportFrom(
+		t.Context(),
+		strings.NewReader(sampleDumpedSkills),
+	) {
+		if err != nil {
+			t.Fatalf("Failed while importing %#v: %s", skill, err)
+		}
+		skills = append(skills, skill)
+	}
+
+	if len(skills) != 2 {
+		t.Fatalf("expected 2 skills, got %d", len(skills))
+	}
+
+	if !slices.Equal(skills[0].Tags, []string{"Dodongo's Cavern", "Vanilla Dungeons"}) {
+		t.Errorf("unexpected tags %#v", skills[0].Tags)
+	}
+
+	if skills[0].Glitch || !skills[1].Glitch {
+		t.Errorf("glitch flag not read %#v", skills)
+	}
+}
@@ -214,6 +214,7 @@ func (this *Scanner) scanString(buffer []byte, atEof bool, n *int) ([]byte, erro
 
 	if buffer[startAt] == '"' {
 		(*n)++
+		this.scanned = scanned_string
 		return []byte{}, nil
 	}
 
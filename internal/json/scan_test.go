@@ -46,6 +46,7 @@ func TestCanScanJson(t *testing.T) {
 		makeSeveralTokens("{}", expectChar('{'), expectChar('}')),
 		makeSeveralTokens("[]", expectChar('['), expectChar(']')),
 		makeSeveralTokens(`["str"]`, expectChar('['), expectString("str"), expectChar(']')),
+		makeSeveralTokens(`{"": ""}`, expectChar('{'), expectString(""), expectChar(':'), expectString(""), expectChar('}')),
 		makeSeveralTokens(
 			`{"prop": "value"}`,
 			expectChar('{'),
@@ -0,0 +1,228 @@
+package settings
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// which accessor answers for a setting name
+type Kind uint8
+
+const (
+	_ Kind = iota
+	KindString
+	KindFloat64
+	KindBool
+	KindList
+)
+
+func (this Kind) String() string {
+	switch this {
+	case KindString:
+		return "string"
+	case KindFloat64:
+		return "float64"
+	case KindBool:
+		return "bool"
+	case KindList:
+		return "list"
+	default:
+		panic(fmt.Errorf("unknown setting kind %x", uint8(this)))
+	}
+}
+
+type Described struct {
+	Name string
+	Kind Kind
+}
+
+// false for settings whose fields are all tagged cosmetic, they only change
+// presentation or seed generation and are excluded from LogicFingerprint
+func (this Described) AffectsLogic() bool {
+	named := fieldsNamed(this.Name)
+	for _, field := range named {
+		if !field.cosmetic {
+			return true
+		}
+	}
+	return len(named) == 0
+}
+
+// every setting that can be read by its OOTR name
+func Catalog() []Described {
+	return catalog[:]
+}
+
+// renders any cataloged or tagged setting as a display string, lists are
+// rendered sorted and comma separated. Settings stored in Zootr are rendered
+// from their fields, the rest through the accessor logic reads them with.
+// Quantity conditions that are not the active condition render as "n/a"
+func (this *Zootr) Render(name string) (string, error) {
+	if named := fieldsNamed(name); len(named) > 0 {
+		return this.renderFields(named)
+	}
+
+	kind, exists := kinds[name]
+	if !exists {
+		return "", unknown(name)
+	}
+
+	switch kind {
+	case KindString:
+		return this.String(name)
+	case KindFloat64:
+		f64, err := this.Float64(name)
+		if err != nil {
+			return "", err
+		}
+		if f64 == math.MaxFloat64 {
+			return "n/a", nil
+		}
+		return strconv.FormatFloat(f64, 'g', -1, 64), nil
+	case KindBool:
+		b, err := this.Bool(name)
+		return strconv.FormatBool(b), err
+	default:
+		return "", unknown(name)
+	}
+}
+
+func list(items []string) string {
+	return strings.Join(sorted(items), ", ")
+}
+
+func counted(items map[string]uint8) string {
+	var rendered []string
+	for name, qty := range items {
+		rendered = append(rendered, fmt.Sprintf("%s x%d", name, qty))
+	}
+	return list(rendered)
+}
+
+func enabled(flags map[string]bool) []string {
+	var on []string
+	for name, isOn := range flags {
+		if isOn {
+			on = append(on, name)
+		}
+	}
+	return on
+}
+
+var catalog = []Described{
+	{"logic_rules", KindString},
+	{"reachable_locations", KindString},
+	{"lacs_condition", KindString},
+	{"bridge", KindString},
+	{"shuffle_ganon_bosskey", KindString},
+	{"open_forest", KindString},
+	{"open_kakariko", KindString},
+	{"zora_fountain", KindString},
+	{"gerudo_fortress", KindString},
+	{"shuffle_scrubs", KindString},
+	{"shuffle_pots", KindString},
+	{"shuffle_crates", KindString},
+	{"shuffle_dungeon_rewards", KindString},
+	{"shuffle_tcgkeys", KindString},
+	{"hints", KindString},
+	{"damage_multiplier", KindString},
+	{"deadly_bonks", KindString},
+	{"shuffle_gerudo_fortress_heart_piece", KindString},
+	{"scarecrow_behavior", KindString},
+	{"starting_tod", KindString},
+	{"starting_age", KindString},
+	{"item_pool_value", KindString},
+
+	{"triforce_count_per_world", KindFloat64},
+	{"triforce_goal_per_world", KindFloat64},
+	{"lacs_medallions", KindFloat64},
+	{"lacs_stones", KindFloat64},
+	{"lacs_rewards", KindFloat64},
+	{"lacs_tokens", KindFloat64},
+	{"lacs_hearts", KindFloat64},
+	{"bridge_medallions", KindFloat64},
+	{"bridge_stones", KindFloat64},
+	{"bridge_rewards", KindFloat64},
+	{"bridge_tokens", KindFloat64},
+	{"bridge_hearts", KindFloat64},
+	{"trials", KindFloat64},
+	{"ganon_bosskey_medallions", KindFloat64},
+	{"ganon_bosskey_stones", KindFloat64},
+	{"ganon_bosskey_rewards", KindFloat64},
+	{"ganon_bosskey_tokens", KindFloat64},
+	{"ganon_bosskey_hearts", KindFloat64},
+	{"chicken_count", KindFloat64},
+	{"big_poe_count", KindFloat64},
+	{"starting_hearts", KindFloat64},
+	{"starting_rupees", KindFloat64},
+	{"world_count", KindFloat64},
+
+	{"trials_random", KindBool},
+	{"triforce_hunt", KindBool},
+	{"open_door_of_time", KindBool},
+	{"shuffle_hideout_entrances", KindBool},
+	{"shuffle_grotto_entrances", KindBool},
+	{"shuffle_ganon_tower", KindBool},
+	{"shuffle_overworld_entrances", KindBool},
+	{"shuffle_gerudo_valley_river_exit", KindBool},
+	{"owl_drops", KindBool},
+	{"free_bombchu_drops", KindBool},
+	{"warp_songs", KindBool},
+	{"adult_trade_shuffle", KindBool},
+	{"shuffle_empty_pots", KindBool},
+	{"shuffle_empty_crates", KindBool},
+	{"shuffle_cows", KindBool},
+	{"shuffle_beehives", KindBool},
+	{"shuffle_wonderitems", KindBool},
+	{"shuffle_kokiri_sword", KindBool},
+	{"shuffle_ocarinas", KindBool},
+	{"shuffle_gerudo_card", KindBool},
+	{"shuffle_beans", KindBool},
+	{"shuffle_expensive_merchants", KindBool},
+	{"shuffle_frog_song_rupees", KindBool},
+	{"shuffle_individual_ocarina_notes", KindBool},
+	{"keyring_give_bk", KindBool},
+	{"enhance_map_compass", KindBool},
+	{"start_with_consumables", KindBool},
+	{"start_with_rupees", KindBool},
+	{"skip_reward_from_rauru", KindBool},
+	{"no_escape_sequence", KindBool},
+	{"no_guard_stealth", KindBool},
+	{"no_epona_race", KindBool},
+	{"skip_some_minigame_phases", KindBool},
+	{"skip_child_zelda", KindBool},
+	{"complete_mask_quest", KindBool},
+	{"useful_cutscenes", KindBool},
+	{"fast_chests", KindBool},
+	{"free_scarecrow", KindBool},
+	{"plant_beans", KindBool},
+	{"easier_fire_arrow_entry", KindBool},
+	{"ruto_already_f1_jabu", KindBool},
+	{"chicken_count_random", KindBool},
+	{"clearer_hints", KindBool},
+	{"blue_fire_arrows", KindBool},
+	{"fix_broken_drops", KindBool},
+	{"tcg_requires_lens", KindBool},
+	{"no_collectible_hearts", KindBool},
+	{"one_item_per_dungeon", KindBool},
+	{"shuffle_interior_entrances", KindBool},
+	{"shuffle_silver_rupees", KindBool},
+
+	{"allowed_tricks", KindList},
+	{"allowed_glitches", KindList},
+	{"disabled_locations", KindList},
+	{"starting_items", KindList},
+	{"starting_equipment", KindList},
+	{"starting_inventory", KindList},
+	{"starting_songs", KindList},
+}
+
+var kinds = func() map[string]Kind {
+	kinds := make(map[string]Kind, len(catalog))
+	for _, described := range catalog {
+		kinds[described.Name] = described.Kind
+	}
+	return kinds
+}()
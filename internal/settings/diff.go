@@ -0,0 +1,37 @@
+package settings
+
+// a single setting that differs between two configurations, rendered with
+// Zootr.Render
+type Difference struct {
+	Name string `json:"name"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// lists every setting stored in Zootr whose rendered value differs between
+// a and b in declaration order. A setting that cannot be rendered on one side
+// is reported with the render error as its value.
+func Diff(a, b *Zootr) []Difference {
+	var diffs []Difference
+	seen := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		if seen[field.name] {
+			continue
+		}
+		seen[field.name] = true
+		named := fieldsNamed(field.name)
+		old, oldErr := a.renderFields(named)
+		if oldErr != nil {
+			old = oldErr.Error()
+		}
+		new, newErr := b.renderFields(named)
+		if newErr != nil {
+			new = newErr.Error()
+		}
+
+		if old != new {
+			diffs = append(diffs, Difference{field.name, old, new})
+		}
+	}
+	return diffs
+}
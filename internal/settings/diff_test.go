@@ -0,0 +1,103 @@
+package settings
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDiffListsEverythingHellModeChanges(t *testing.T) {
+	def, hell := Default(), HellMode()
+	var names []string
+	for _, diff := range Diff(&def, &hell) {
+		names = append(names, diff.Name)
+	}
+
+	for _, expected := range []string{
+		"shuffle_smallkeys", "shuffle_bosskeys", "shuffle_hideoutkeys",
+		"shuffle_ganon_bosskey", "mq_dungeons_mode",
+		"shuffle_mapcompass", "shuffle_song_items", "tokensanity",
+		"shuffle_freestanding_items", "shuffle_dungeon_entrances", "shuffle_bosses",
+		"adult_spawn", "child_spawn", "bridge", "starting_items",
+	} {
+		if !slices.Contains(names, expected) {
+			t.Errorf("expected %q to differ, found %v", expected, names)
+		}
+	}
+}
+
+func TestDiffRendersOldAndNew(t *testing.T) {
+	def, hell := Default(), HellMode()
+	diffs := Diff(&def, &hell)
+	expected := []Difference{
+		{"shuffle_ganon_bosskey", "remove", "on_lacs"},
+		{"ganon_bosskey_condition", "medallions 6", "medallions 6"},
+		{"bridge", "medallions 6", "tokens 100"},
+		{"shuffle_mapcompass", "remove", "keysanity"},
+		{"child_spawn", "vanilla", "random"},
+	}
+	for _, diff := range expected {
+		found := slices.ContainsFunc(diffs, func(d Difference) bool { return d == diff })
+		if diff.Old == diff.New {
+			found = !slices.ContainsFunc(diffs, func(d Difference) bool { return d.Name == diff.Name })
+		}
+		if !found {
+			t.Errorf("expected %+v, found %+v", diff, diffs)
+		}
+	}
+}
+
+func TestPresetsLoadAndDiffCleanly(t *testing.T) {
+	for _, name := range PresetNames() {
+		preset, err := LoadPreset(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, _ := LoadPreset(name)
+		if diffs := Diff(&preset, &again); len(diffs) != 0 {
+			t.Errorf("expected %s to equal itself, found %+v", name, diffs)
+		}
+		def := Default()
+		if diffs := Diff(&def, &preset); (len(diffs) == 0) != (name == "default") {
+			t.Errorf("expected %s to differ from default only if it is not default, found %+v", name, diffs)
+		}
+		for _, diff := range Diff(&def, &preset) {
+			for _, rendered := range []string{diff.Old, diff.New} {
+				if strings.Contains(rendered, "could not render") {
+					t.Errorf("%s: could not render %s: %s", name, diff.Name, rendered)
+				}
+			}
+		}
+		for _, described := range Catalog() {
+			if _, err := preset.Render(described.Name); err != nil {
+				t.Errorf("%s: %s", name, err)
+			}
+		}
+	}
+
+	if _, err := LoadPreset("speedrun"); err == nil {
+		t.Fatal("expected unknown preset to be rejected")
+	}
+}
+
+func TestRenderReportsUnknownValues(t *testing.T) {
+	settings := Default()
+	settings.Spawns.StartingAge = 0xFF
+	if _, err := settings.Render("starting_age"); err == nil {
+		t.Fatal("expected unknown starting age to fail rendering")
+	}
+
+	hell := HellMode()
+	if diffs := Diff(&settings, &hell); !slices.ContainsFunc(diffs, func(d Difference) bool { return d.Name == "starting_age" }) {
+		t.Fatalf("expected unrenderable starting age to be reported, found %+v", diffs)
+	}
+}
+
+func TestCosmeticSettingsDoNotAffectLogic(t *testing.T) {
+	for _, described := range Catalog() {
+		cosmetic := slices.Contains([]string{"hints", "clearer_hints", "fast_chests", "enhance_map_compass", "world_count"}, described.Name)
+		if described.AffectsLogic() == cosmetic {
+			t.Errorf("expected %s to affect logic: %t", described.Name, !cosmetic)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package settings
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/etc-sudonters/substrate/slipup"
+)
+
+// a setting stored directly in Zootr, named by its field's ootr tag. Every
+// field that is not a struct of further settings is tagged so walking them
+// covers the whole configuration. Tagged ",cosmetic" fields never change
+// what logic can reach. Several fields may share a name, such as
+// Locations.Disabled and DisabledLocations, and are rendered together.
+type field struct {
+	name     string
+	cosmetic bool
+	index    []int
+}
+
+var fields = func() []field {
+	var fields []field
+	walkFields(reflect.TypeFor[Zootr](), nil, &fields)
+	return fields
+}()
+
+func walkFields(ty reflect.Type, parent []int, fields *[]field) {
+	for i := range ty.NumField() {
+		declared := ty.Field(i)
+		index := append(append([]int(nil), parent...), i)
+		tag, tagged := declared.Tag.Lookup("ootr")
+		if !tagged && declared.Type.Kind() == reflect.Struct {
+			walkFields(declared.Type, index, fields)
+			continue
+		}
+		if !tagged {
+			panic(fmt.Errorf("setting %s.%s has no ootr tag", ty.Name(), declared.Name))
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		*fields = append(*fields, field{name, options == "cosmetic", index})
+	}
+}
+
+func fieldsNamed(name string) []field {
+	var named []field
+	for _, field := range fields {
+		if field.name == name {
+			named = append(named, field)
+		}
+	}
+	return named
+}
+
+func (this *Zootr) renderFields(named []field) (string, error) {
+	settings := reflect.ValueOf(this).Elem()
+	var lists []string
+	var rendered []string
+	for _, field := range named {
+		value := settings.FieldByIndex(field.index)
+		if items, isList := value.Interface().([]string); isList {
+			lists = append(lists, items...)
+			continue
+		}
+		display, err := renderValue(value)
+		if err != nil {
+			return "", slipup.Describef(err, "could not render %q", field.name)
+		}
+		rendered = append(rendered, display)
+	}
+	if len(lists) > 0 {
+		rendered = append(rendered, list(lists))
+	}
+	return strings.Join(rendered, ", "), nil
+}
+
+type namedValue interface {
+	Name() (string, error)
+}
+
+func renderValue(value reflect.Value) (string, error) {
+	switch setting := value.Interface().(type) {
+	case LacsCondition:
+		return renderCondition(DecodeCondition(setting))
+	case BridgeCondition:
+		return renderCondition(DecodeCondition(setting))
+	case GanonBKCondition:
+		return renderCondition(DecodeCondition(setting))
+	case []string:
+		return list(setting), nil
+	case map[string]bool:
+		return list(enabled(setting)), nil
+	case map[string]uint8:
+		return counted(setting), nil
+	case namedValue:
+		return setting.Name()
+	case fmt.Stringer:
+		return setting.String(), nil
+	}
+
+	switch value.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		// flag sets have no names for every combination
+		if value.Type().PkgPath() != "" {
+			return fmt.Sprintf("0x%X", value.Uint()), nil
+		}
+		return strconv.FormatUint(value.Uint(), 10), nil
+	default:
+		return "", slipup.Createf("cannot render %s", value.Type())
+	}
+}
+
+func renderCondition(which Condition, qty uint8) (string, error) {
+	if which == CondUnitialized {
+		return "", slipup.Createf("uninitialized condition")
+	}
+	return fmt.Sprintf("%s %d", which, qty), nil
+}
@@ -0,0 +1,63 @@
+package settings
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"reflect"
+	"slices"
+)
+
+type Fingerprint uint64
+
+// deterministic hash of every setting that influences compiled logic.
+// Fields tagged cosmetic, which includes the seed, are zeroed, tricks and
+// glitches are reduced to the sorted enabled names and location lists are
+// sorted so that equivalent configurations produce the same fingerprint.
+// Starting tokens are sorted but keep their duplicates, each entry is
+// collected.
+func (this *Zootr) LogicFingerprint() Fingerprint {
+	canonical := *this
+	zeroing := reflect.ValueOf(&canonical).Elem()
+	for _, field := range fields {
+		if field.cosmetic {
+			zeroing.FieldByIndex(field.index).SetZero()
+		}
+	}
+
+	canonical.DisabledLocations = set(this.DisabledLocations)
+	canonical.Locations.Disabled = set(this.Locations.Disabled)
+	canonical.Starting.Tokens = sorted(this.Starting.Tokens)
+	canonical.Skills.Tricks = nil
+	canonical.Skills.Glitches = nil
+
+	encoded, err := json.Marshal(struct {
+		Settings Zootr
+		Tricks   []string
+		Glitches []string
+	}{
+		canonical,
+		set(enabled(this.Skills.Tricks)),
+		set(enabled(this.Skills.Glitches)),
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	hasher := fnv.New64a()
+	hasher.Write(encoded)
+	return Fingerprint(hasher.Sum64())
+}
+
+func sorted(items []string) []string {
+	if len(items) == 0 {
+		return nil
+	}
+	sorted := slices.Clone(items)
+	slices.Sort(sorted)
+	return sorted
+}
+
+// sorted without duplicates
+func set(items []string) []string {
+	return slices.Compact(sorted(items))
+}
@@ -0,0 +1,70 @@
+package settings
+
+import "testing"
+
+func TestFingerprintIsDeterministic(t *testing.T) {
+	for _, name := range PresetNames() {
+		a, _ := LoadPreset(name)
+		b, _ := LoadPreset(name)
+		if a.LogicFingerprint() != b.LogicFingerprint() {
+			t.Errorf("expected %s to fingerprint the same every time", name)
+		}
+		if a.LogicFingerprint() != a.LogicFingerprint() {
+			t.Errorf("expected %s to fingerprint the same when hashed again", name)
+		}
+	}
+
+	def, hell := Default(), HellMode()
+	if def.LogicFingerprint() == hell.LogicFingerprint() {
+		t.Fatal("expected default and hell to fingerprint differently")
+	}
+}
+
+func TestFingerprintIgnoresCosmeticSettings(t *testing.T) {
+	def := Default()
+	expected := def.LogicFingerprint()
+
+	cosmetic := Default()
+	cosmetic.Seed = 0x76E76E14E9691280
+	cosmetic.Worlds = 3
+	cosmetic.HintsRevealed = HintsRevealedNever
+	cosmetic.ClearerHints = !def.ClearerHints
+	cosmetic.FastChests = !def.FastChests
+	cosmetic.EnhanceMapAndCompass = !def.EnhanceMapAndCompass
+	if cosmetic.LogicFingerprint() != expected {
+		t.Fatal("expected cosmetic settings to not change the fingerprint")
+	}
+
+	logic := Default()
+	logic.Shuffling.Songs = ShuffleSongsAnywhere
+	if logic.LogicFingerprint() == expected {
+		t.Fatal("expected song shuffle to change the fingerprint")
+	}
+}
+
+func TestFingerprintIgnoresOrder(t *testing.T) {
+	a, b := Default(), Default()
+	a.Skills.Tricks = map[string]bool{"dc_jump": true, "man_on_roof": true}
+	b.Skills.Tricks = map[string]bool{"man_on_roof": true, "lens_gtg": false, "dc_jump": true}
+	a.Locations.Disabled = []string{"Deku Theater Mask of Truth", "Kak 40 Gold Skulltula Reward"}
+	b.Locations.Disabled = []string{"Kak 40 Gold Skulltula Reward", "Deku Theater Mask of Truth", "Deku Theater Mask of Truth"}
+	a.Starting.Tokens = []string{"Ocarina", "Deku Shield"}
+	b.Starting.Tokens = []string{"Deku Shield", "Ocarina"}
+	if a.LogicFingerprint() != b.LogicFingerprint() {
+		t.Fatal("expected trick, location and token order to not change the fingerprint")
+	}
+
+	b.Skills.Tricks["lens_gtg"] = true
+	if a.LogicFingerprint() == b.LogicFingerprint() {
+		t.Fatal("expected enabling a trick to change the fingerprint")
+	}
+}
+
+func TestFingerprintCountsDuplicateStartingTokens(t *testing.T) {
+	once, twice := Default(), Default()
+	once.Starting.Tokens = []string{"Progressive Hookshot"}
+	twice.Starting.Tokens = []string{"Progressive Hookshot", "Progressive Hookshot"}
+	if once.LogicFingerprint() == twice.LogicFingerprint() {
+		t.Fatal("expected a second progressive hookshot to change the fingerprint")
+	}
+}
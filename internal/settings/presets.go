@@ -0,0 +1,124 @@
+package settings
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+type Preset func() Zootr
+
+// names of every registered preset, sorted
+func PresetNames() []string {
+	return slices.Sorted(maps.Keys(presets))
+}
+
+func LoadPreset(name string) (Zootr, error) {
+	preset, exists := presets[name]
+	if !exists {
+		return Zootr{}, fmt.Errorf("%q is not a known preset", name)
+	}
+	return preset(), nil
+}
+
+var presets = map[string]Preset{
+	"default":    Default,
+	"tournament": Tournament,
+	"beginner":   Beginner,
+	"hell":       HellMode,
+}
+
+// roughly the standard weekly/tournament settings: closed deku, 3 stone
+// bridge, ganon's boss key on 4 dungeon rewards and a handful of commonly
+// allowed tricks
+func Tournament() Zootr {
+	s := Default()
+	s.BridgeCondition = CreateBridge(CondStones, 3)
+	s.KeyShuffle.GanonBKShuffle = GanonBKDungeonRewards
+	s.KeyShuffle.GanonBKCondition = CreateGanonBK(CondRewards, 4)
+	s.Dungeons.Trials = TrialsEnabledNone
+	s.Shuffling.Scrubs = ShuffleScrubsUpgradeOnly
+	s.Shuffling.Tokens = ShuffleGoldTokenOff
+	s.Spawns.StartingAge = StartAgeRandom
+	s.Minigames.BigPoeCount = 1
+	s.Starting.Tokens = append(s.Starting.Tokens, "Deku Nuts (5)", "Deku Stick (1)")
+	s.HintsRevealed = HintsRevealedAlways
+
+	s.Skills.Tricks["visible_collision"] = true
+	s.Skills.Tricks["deku_b1_skip"] = true
+	s.Skills.Tricks["dc_scarecrow_gs"] = true
+	s.Skills.Tricks["kakariko_tower_gs"] = true
+	s.Skills.Tricks["lost_woods_gs_bean"] = true
+	s.Skills.Tricks["shadow_fire_arrow_entry"] = true
+	return s
+}
+
+// open world and no tricks
+func Beginner() Zootr {
+	s := Default()
+	s.Skills.Tricks = map[string]bool{}
+	s.Locations.KokriForest = KokriForestOpen
+	s.Locations.ZoraFountain = ZoraFountainOpenAdult
+	s.Locations.GerudoFortress = GerudoFortressOpen
+	s.BridgeCondition = CreateBridge(CondMedallions, 3)
+	s.Spawns.StartingAge = StartAgeChild
+	s.Shuffling.Scrubs = ShuffleScrubsUpgradeOnly
+	s.Starting.Hearts = 5
+	s.Starting.TimeOfDay = StartingTimeOfDayMorning
+	s.Minigames.BigPoeCount = 1
+	s.Minigames.KakChickens = 1
+	s.ItemPool = ItemPoolPlentiful
+	return s
+}
+
+// everything shuffled everywhere, keysanity and one hit ko
+func HellMode() Zootr {
+	s := Default()
+	s.BridgeCondition = CreateBridge(CondTokens, 100)
+	s.LacsCondition = CreateLacs(CondMedallions, 6)
+	s.KeyShuffle.BossKeys = KeysAnywhere
+	s.KeyShuffle.SmallKeys = KeysAnywhere
+	s.KeyShuffle.HideoutKeys = KeysAnywhere
+	s.KeyShuffle.TreasureChestGame = KeysAnywhere
+	s.KeyShuffle.SilverRupees = KeysAnywhere
+	s.KeyShuffle.GanonBKShuffle = GanonBKOnLacs
+	s.KeyShuffle.GanonBKCondition = CreateGanonBK(CondMedallions, 6)
+	s.Locations.KokriForest = KokriForestClosed
+	s.Locations.Kakariko = KakGateClosed
+	s.Locations.OpenDoorOfTime = false
+	s.Locations.GerudoFortress = GerudoFortressNormal
+	s.Dungeons.Trials = TrialsEnabledAll
+	s.Dungeons.MasterQuest = MasterQuestDungeonsAll
+	s.Dungeons.Rewards = DungeonRewardAnywhere
+	s.Dungeons.MapsCompasses = MapsCompassesAnywhere
+	s.Entrances.Interior = InteriorShuffleAll
+	s.Entrances.DungeonEntrances = DungeonEntranceShuffleAll
+	s.Entrances.Bosses = BossShuffleAll
+	s.Entrances.Grottos = true
+	s.Entrances.Overworld = true
+	s.Entrances.OwlDrops = true
+	s.Entrances.WarpSongs = true
+	s.Spawns.AdultSpawn = RandomSpawn
+	s.Spawns.ChildSpawn = RandomSpawn
+	s.Shuffling.Beans = true
+	s.Shuffling.Beehives = true
+	s.Shuffling.Cows = true
+	s.Shuffling.Crates = ShuffleCratesAll
+	s.Shuffling.Freestandings = ShuffleFreestandingsOverworld
+	s.Shuffling.FrogRupeeRewards = true
+	s.Shuffling.GerudoCard = true
+	s.Shuffling.OcarinaNotes = true
+	s.Shuffling.Ocarinas = true
+	s.Shuffling.Pots = ShufflePotsAll
+	s.Shuffling.Scrubs = ShuffleScrubsRandom
+	s.Shuffling.Songs = ShuffleSongsAnywhere
+	s.Shuffling.Tokens = ShuffleGoldTokenOverworld
+	s.Shuffling.WonderItems = true
+	s.Starting.Tokens = nil
+	s.Starting.WithConsumables = false
+	s.Damage.Multiplier = DamageMultiplierOhko
+	s.Damage.Bonk = BonkDamageOhko
+	s.HintsRevealed = HintsRevealedNever
+	s.ItemPool = ItemPoolMinimal
+	return s
+}
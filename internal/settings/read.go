@@ -3,6 +3,8 @@ package settings
 import (
 	"fmt"
 	"math"
+	"reflect"
+	"slices"
 )
 
 func (this *Zootr) String(name string) (string, error) {
@@ -290,6 +292,36 @@ func (this *Zootr) Bool(name string) (bool, error) {
 	return val, nil
 }
 
+// true if member is in the named list setting, several fields sharing the
+// name are searched together
+func (this *Zootr) Contains(name, member string) (bool, error) {
+	named := fieldsNamed(name)
+	if len(named) == 0 {
+		return false, unknown(name)
+	}
+
+	settings := reflect.ValueOf(this).Elem()
+	for _, field := range named {
+		switch list := settings.FieldByIndex(field.index).Interface().(type) {
+		case []string:
+			if slices.Contains(list, member) {
+				return true, nil
+			}
+		case map[string]bool:
+			if list[member] {
+				return true, nil
+			}
+		case map[string]uint8:
+			if list[member] > 0 {
+				return true, nil
+			}
+		default:
+			return false, fmt.Errorf("%q is not a list setting", name)
+		}
+	}
+	return false, nil
+}
+
 func unknown(name string) error {
 	return fmt.Errorf("%q is not a known setting", name)
 }
@@ -0,0 +1,36 @@
+package settings
+
+import "testing"
+
+func TestContainsSearchesListSettings(t *testing.T) {
+	these := Default()
+	these.Locations.Disabled = []string{"Deku Tree Map Chest"}
+	these.DisabledLocations = []string{"Kokiri Sword Chest"}
+	these.Skills.Tricks = map[string]bool{"dc_jump": true, "lens_bottom_of_the_well": false}
+
+	for _, check := range []struct {
+		name, member string
+		contains     bool
+	}{
+		{"disabled_locations", "Deku Tree Map Chest", true},
+		{"disabled_locations", "Kokiri Sword Chest", true},
+		{"disabled_locations", "Deku Tree Compass Chest", false},
+		{"allowed_tricks", "dc_jump", true},
+		{"allowed_tricks", "lens_bottom_of_the_well", false},
+	} {
+		contains, err := these.Contains(check.name, check.member)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contains != check.contains {
+			t.Errorf("expected %q in %s to be %t", check.member, check.name, check.contains)
+		}
+	}
+
+	if _, err := these.Contains("bridge", "open"); err == nil {
+		t.Error("expected bridge not to be a list setting")
+	}
+	if _, err := these.Contains("key_rings", "Forest Temple"); err == nil {
+		t.Error("expected key_rings to be unknown")
+	}
+}
@@ -10,12 +10,12 @@ func Has[F flagged](setting, expecting F) bool {
 }
 
 type Zootr struct {
-	Seed            uint64
-	Worlds          uint8
-	LogicRules      LogicRuleSet
+	Seed            uint64       `ootr:"seed,cosmetic"`
+	Worlds          uint8        `ootr:"world_count,cosmetic"`
+	LogicRules      LogicRuleSet `ootr:"logic_rules"`
 	TriforceHunt    TriforceHunt
-	LacsCondition   LacsCondition
-	BridgeCondition BridgeCondition
+	LacsCondition   LacsCondition   `ootr:"lacs_condition"`
+	BridgeCondition BridgeCondition `ootr:"bridge"`
 	KeyShuffle      KeyShuffling
 	Locations       Locations
 	Dungeons        Dungeons
@@ -28,20 +28,20 @@ type Zootr struct {
 	Minigames       Minigames
 	Damage          Damage
 	Trades          Trades
-	ItemPool        ItemPool
+	ItemPool        ItemPool `ootr:"item_pool_value"`
 
 	// uncategorized
-	BlueFireArrows       bool
-	DisabledLocations    []string
-	FixBrokenDrops       bool
-	FreeBombchuDrops     bool
-	HintsRevealed        HintsRevealed
-	ClearerHints         bool
-	EnhanceMapAndCompass bool
-	UsefulCutscenes      bool
-	FastChests           bool
-	NoCollectibleHearts  bool
-	ScarecrowBehavior    ScarecrowBehavior
+	BlueFireArrows       bool              `ootr:"blue_fire_arrows"`
+	DisabledLocations    []string          `ootr:"disabled_locations"`
+	FixBrokenDrops       bool              `ootr:"fix_broken_drops"`
+	FreeBombchuDrops     bool              `ootr:"free_bombchu_drops"`
+	HintsRevealed        HintsRevealed     `ootr:"hints,cosmetic"`
+	ClearerHints         bool              `ootr:"clearer_hints,cosmetic"`
+	EnhanceMapAndCompass bool              `ootr:"enhance_map_compass,cosmetic"`
+	UsefulCutscenes      bool              `ootr:"useful_cutscenes"`
+	FastChests           bool              `ootr:"fast_chests,cosmetic"`
+	NoCollectibleHearts  bool              `ootr:"no_collectible_hearts"`
+	ScarecrowBehavior    ScarecrowBehavior `ootr:"scarecrow_behavior"`
 }
 
 type ScarecrowBehavior uint8
@@ -97,79 +97,86 @@ type ShuffleTradeAdult uint16
 type ShuffleTradeChild uint16
 
 type Trades struct {
-	Adult         ShuffleTradeAdult
-	Child         ShuffleTradeChild
-	DisableRevert bool
+	Adult         ShuffleTradeAdult `ootr:"adult_trade_start"`
+	Child         ShuffleTradeChild `ootr:"shuffle_child_trade"`
+	DisableRevert bool              `ootr:"disable_trade_revert"`
 }
 
 type Damage struct {
-	Multiplier DamageMultiplier
-	Bonk       BonkDamage
+	Multiplier DamageMultiplier `ootr:"damage_multiplier"`
+	Bonk       BonkDamage       `ootr:"deadly_bonks"`
 }
 
 type Starting struct {
-	PlantBeans        bool
-	Hearts            uint8
-	RauruReward       bool
-	Rupees            uint16
-	TimeOfDay         StartingTimeOfDay
-	Tokens            []string // ootr uses equip, song and inventory separately
-	WithConsumables   bool
-	CompleteMaskQuest bool
+	PlantBeans        bool              `ootr:"plant_beans"`
+	Hearts            uint8             `ootr:"starting_hearts"`
+	RauruReward       bool              `ootr:"skip_reward_from_rauru"`
+	Rupees            uint16            `ootr:"starting_rupees"`
+	TimeOfDay         StartingTimeOfDay `ootr:"starting_tod"`
+	Tokens            []string          `ootr:"starting_items"` // token names, collected once per entry
+	WithConsumables   bool              `ootr:"start_with_consumables"`
+	CompleteMaskQuest bool              `ootr:"complete_mask_quest"`
+
+	// ootr's starting_equipment, starting_items and starting_songs keyed by
+	// their setting names, e.g. "deku_shield", with a count of each
+	Equipment map[string]uint8 `ootr:"starting_equipment"`
+	Inventory map[string]uint8 `ootr:"starting_inventory"`
+	Songs     map[string]uint8 `ootr:"starting_songs"`
 }
 
 type Skills struct {
-	Tricks   map[string]bool
-	Glitches map[string]bool
+	Tricks   map[string]bool `ootr:"allowed_tricks"`
+	Glitches map[string]bool `ootr:"allowed_glitches"`
 
-	ShadowFireArrowEntry uint8
+	ShadowFireArrowEntry uint8 `ootr:"fae_torch_count"`
 }
 
 type Skips struct {
-	TowerEscape bool
-	EponaRace   bool
-	ChildZelda  bool
+	TowerEscape bool `ootr:"no_escape_sequence"`
+	EponaRace   bool `ootr:"no_epona_race"`
+	ChildZelda  bool `ootr:"skip_child_zelda"`
 
-	RutoAlreadyOnFloor1 bool
-	HyruleCastleStealth bool
+	RutoAlreadyOnFloor1 bool `ootr:"ruto_already_f1_jabu"`
+	HyruleCastleStealth bool `ootr:"no_guard_stealth"`
 }
 
 type Minigames struct {
-	CollapsePhases bool
-	KakChickens    uint8
-	BigPoeCount    uint8
+	CollapsePhases bool  `ootr:"skip_some_minigame_phases"`
+	KakChickens    uint8 `ootr:"chicken_count"`
+	BigPoeCount    uint8 `ootr:"big_poe_count"`
 
-	TreasureChestGameRequiresLens bool
+	TreasureChestGameRequiresLens bool `ootr:"tcg_requires_lens"`
 }
 
 type Shuffling struct {
-	Beans                                bool
-	Beehives                             bool
-	Cows                                 bool
-	Crates                               ShuffleCrates
-	ExpensiveMerchants                   bool
-	Freestandings                        ShuffleFreestandings
-	FrogRupeeRewards                     bool
-	GerudoCard                           bool
-	KokriSword                           bool
-	LoachReward                          ShuffleLoachReward
-	NightTokensWithoutSuns               bool
-	OcarinaNotes                         bool
-	Ocarinas                             bool
-	Pots                                 ShufflePots
-	Scrubs                               ShuffleScrubs
-	Shops                                ShuffleShops
-	SongPatterns                         ShuffleSongPatterns
-	Songs                                ShuffleSongs
-	Tokens                               ShuffleTokens
-	WonderItems                          bool
-	IncludeEmptyPots, IncludeEmptyCrates bool
+	Beans                  bool                 `ootr:"shuffle_beans"`
+	Beehives               bool                 `ootr:"shuffle_beehives"`
+	Cows                   bool                 `ootr:"shuffle_cows"`
+	Crates                 ShuffleCrates        `ootr:"shuffle_crates"`
+	ExpensiveMerchants     bool                 `ootr:"shuffle_expensive_merchants"`
+	Freestandings          ShuffleFreestandings `ootr:"shuffle_freestanding_items"`
+	FrogRupeeRewards       bool                 `ootr:"shuffle_frog_song_rupees"`
+	GerudoCard             bool                 `ootr:"shuffle_gerudo_card"`
+	KokriSword             bool                 `ootr:"shuffle_kokiri_sword"`
+	LoachReward            ShuffleLoachReward   `ootr:"shuffle_loach_reward"`
+	NightTokensWithoutSuns bool                 `ootr:"logic_no_night_tokens_without_suns_song"`
+	OcarinaNotes           bool                 `ootr:"shuffle_individual_ocarina_notes"`
+	Ocarinas               bool                 `ootr:"shuffle_ocarinas"`
+	Pots                   ShufflePots          `ootr:"shuffle_pots"`
+	Scrubs                 ShuffleScrubs        `ootr:"shuffle_scrubs"`
+	Shops                  ShuffleShops         `ootr:"shopsanity"`
+	SongPatterns           ShuffleSongPatterns  `ootr:"ocarina_songs"`
+	Songs                  ShuffleSongs         `ootr:"shuffle_song_items"`
+	Tokens                 ShuffleTokens        `ootr:"tokensanity"`
+	WonderItems            bool                 `ootr:"shuffle_wonderitems"`
+	IncludeEmptyPots       bool                 `ootr:"shuffle_empty_pots"`
+	IncludeEmptyCrates     bool                 `ootr:"shuffle_empty_crates"`
 }
 
 type SpawnSettings struct {
-	StartingAge StartingAge
-	AdultSpawn  Spawn
-	ChildSpawn  Spawn
+	StartingAge StartingAge `ootr:"starting_age"`
+	AdultSpawn  Spawn       `ootr:"adult_spawn"`
+	ChildSpawn  Spawn       `ootr:"child_spawn"`
 }
 
 func (spawn SpawnSettings) Randomized() bool {
@@ -189,17 +196,17 @@ func (er EntranceRandomizer) ShufflingAny() bool {
 }
 
 type EntranceRandomizer struct {
-	Interior         InteriorShuffle
-	DungeonEntrances DungeonEntranceShuffle
-	Bosses           BossShuffle
-	HideoutEntrances bool
-	Grottos          bool
-	Overworld        bool
-	RiverExit        bool
-	OwlDrops         bool
-	WarpSongs        bool
-	Tower            bool
-	ValleyExit       bool
+	Interior         InteriorShuffle        `ootr:"shuffle_interior_entrances"`
+	DungeonEntrances DungeonEntranceShuffle `ootr:"shuffle_dungeon_entrances"`
+	Bosses           BossShuffle            `ootr:"shuffle_bosses"`
+	HideoutEntrances bool                   `ootr:"shuffle_hideout_entrances"`
+	Grottos          bool                   `ootr:"shuffle_grotto_entrances"`
+	Overworld        bool                   `ootr:"shuffle_overworld_entrances"`
+	RiverExit        bool                   `ootr:"river_exit"`
+	OwlDrops         bool                   `ootr:"owl_drops"`
+	WarpSongs        bool                   `ootr:"warp_songs"`
+	Tower            bool                   `ootr:"shuffle_ganon_tower"`
+	ValleyExit       bool                   `ootr:"shuffle_gerudo_valley_river_exit"`
 }
 
 func (this EntranceRandomizer) AffectedTodChecks() bool {
@@ -207,41 +214,42 @@ func (this EntranceRandomizer) AffectedTodChecks() bool {
 }
 
 type Dungeons struct {
-	Trials        TrialsEnabled
-	Shortcuts     DungeonShortcuts
-	MasterQuest   MasterQuestDungeons
-	Rewards       DungeonRewardShuffle
-	MapsCompasses MapsCompasses
-	OneItemPer    bool
-	Completed     CompletedDungeons
+	Trials        TrialsEnabled        `ootr:"trials"`
+	Shortcuts     DungeonShortcuts     `ootr:"dungeon_shortcuts"`
+	MasterQuest   MasterQuestDungeons  `ootr:"mq_dungeons_mode"`
+	Rewards       DungeonRewardShuffle `ootr:"shuffle_dungeon_rewards"`
+	MapsCompasses MapsCompasses        `ootr:"shuffle_mapcompass"`
+	OneItemPer    bool                 `ootr:"one_item_per_dungeon"`
+	Completed     CompletedDungeons    `ootr:"empty_dungeons_mode"`
 
-	RandomTrials bool
+	RandomTrials bool `ootr:"trials_random"`
 
-	ForestTemplePoes
+	ForestTemplePoes `ootr:"forest_temple_poes"`
 }
 
 type Locations struct {
-	ReachableLocations ReachableLocations
-	KokriForest        OpenForest
-	Kakariko           OpenKak
-	OpenDoorOfTime     bool
-	ZoraFountain       OpenZoraFountain
-	GerudoFortress     GerudoFortress
-	Disabled           []string
+	ReachableLocations ReachableLocations `ootr:"reachable_locations"`
+	KokriForest        OpenForest         `ootr:"open_forest"`
+	Kakariko           OpenKak            `ootr:"open_kakariko"`
+	OpenDoorOfTime     bool               `ootr:"open_door_of_time"`
+	ZoraFountain       OpenZoraFountain   `ootr:"zora_fountain"`
+	GerudoFortress     GerudoFortress     `ootr:"gerudo_fortress"`
+	Disabled           []string           `ootr:"disabled_locations"`
 }
 
 type TriforceHunt struct {
-	CountPerWorld, GoalPerWorld uint
+	CountPerWorld uint `ootr:"triforce_count_per_world"`
+	GoalPerWorld  uint `ootr:"triforce_goal_per_world"`
 }
 
 type KeyShuffling struct {
-	BossKeys           KeyShuffle
-	TreasureChestGame  KeyShuffle
-	GanonBKCondition   GanonBKCondition
-	GanonBKShuffle     GanonBKShuffleKind
-	HideoutKeys        KeyShuffle
-	Keyrings           Keyrings
-	SilverRupeePouches SilverRupeePouches
-	SilverRupees       KeyShuffle
-	SmallKeys          KeyShuffle
+	BossKeys           KeyShuffle         `ootr:"shuffle_bosskeys"`
+	TreasureChestGame  KeyShuffle         `ootr:"shuffle_tcgkeys"`
+	GanonBKCondition   GanonBKCondition   `ootr:"ganon_bosskey_condition"`
+	GanonBKShuffle     GanonBKShuffleKind `ootr:"shuffle_ganon_bosskey"`
+	HideoutKeys        KeyShuffle         `ootr:"shuffle_hideoutkeys"`
+	Keyrings           Keyrings           `ootr:"key_rings"`
+	SilverRupeePouches SilverRupeePouches `ootr:"silver_rupee_pouches"`
+	SilverRupees       KeyShuffle         `ootr:"shuffle_silver_rupees"`
+	SmallKeys          KeyShuffle         `ootr:"shuffle_smallkeys"`
 }
@@ -0,0 +1,123 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+type SkillKind uint8
+
+const (
+	_ SkillKind = iota
+	SkillTrick
+	SkillGlitch
+)
+
+func (this SkillKind) String() string {
+	switch this {
+	case SkillTrick:
+		return "trick"
+	case SkillGlitch:
+		return "glitch"
+	default:
+		panic(fmt.Errorf("unknown skill kind %x", uint8(this)))
+	}
+}
+
+// a trick or glitch known to ootr. Name is how Skills and is_trick_enabled
+// refer to it, without its logic_ or glitch_ prefix
+type Skill struct {
+	Kind                   SkillKind
+	Name, Display, Tooltip string
+	Tags                   []string
+}
+
+// known tricks and glitches, a kind with nothing cataloged knows every name
+// of that kind
+type SkillCatalog struct {
+	tricks, glitches map[string]Skill
+}
+
+func (this *SkillCatalog) Add(skill Skill) {
+	var into *map[string]Skill
+	switch skill.Kind {
+	case SkillTrick:
+		into = &this.tricks
+	case SkillGlitch:
+		into = &this.glitches
+	default:
+		panic(fmt.Errorf("unknown skill kind %x", uint8(skill.Kind)))
+	}
+
+	if *into == nil {
+		*into = make(map[string]Skill)
+	}
+	(*into)[skill.Name] = skill
+}
+
+// false if names of this kind cannot be validated
+func (this SkillCatalog) Cataloged(kind SkillKind) bool {
+	switch kind {
+	case SkillTrick:
+		return len(this.tricks) > 0
+	case SkillGlitch:
+		return len(this.glitches) > 0
+	default:
+		return false
+	}
+}
+
+func (this SkillCatalog) Lookup(kind SkillKind, name string) (Skill, bool) {
+	var skill Skill
+	var exists bool
+	switch kind {
+	case SkillTrick:
+		skill, exists = this.tricks[name]
+	case SkillGlitch:
+		skill, exists = this.glitches[name]
+	}
+	return skill, exists
+}
+
+// true if nothing of kind is cataloged or the named skill is
+func (this SkillCatalog) Knows(kind SkillKind, name string) bool {
+	if !this.Cataloged(kind) {
+		return true
+	}
+	_, exists := this.Lookup(kind, name)
+	return exists
+}
+
+func (this SkillCatalog) All(kind SkillKind) []Skill {
+	var skills map[string]Skill
+	switch kind {
+	case SkillTrick:
+		skills = this.tricks
+	case SkillGlitch:
+		skills = this.glitches
+	}
+
+	all := make([]Skill, 0, len(skills))
+	for _, name := range slices.Sorted(maps.Keys(skills)) {
+		all = append(all, skills[name])
+	}
+	return all
+}
+
+// rejects every trick or glitch in skills the catalog does not know
+func (this SkillCatalog) Validate(skills Skills) error {
+	var errs []error
+	check := func(kind SkillKind, named map[string]bool) {
+		for _, name := range slices.Sorted(maps.Keys(named)) {
+			if !this.Knows(kind, name) {
+				errs = append(errs, fmt.Errorf("unknown %s %q", kind, name))
+			}
+		}
+	}
+
+	check(SkillTrick, skills.Tricks)
+	check(SkillGlitch, skills.Glitches)
+	return errors.Join(errs...)
+}
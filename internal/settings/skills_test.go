@@ -0,0 +1,26 @@
+package settings
+
+import "testing"
+
+func TestSkillCatalogValidatesEachKindSeparately(t *testing.T) {
+	var catalog SkillCatalog
+	if catalog.Cataloged(SkillTrick) || !catalog.Knows(SkillTrick, "dc_jump") {
+		t.Fatal("expected an empty catalog to know every trick")
+	}
+
+	catalog.Add(Skill{Kind: SkillTrick, Name: "dc_jump"})
+	if !catalog.Knows(SkillTrick, "dc_jump") || catalog.Knows(SkillTrick, "dc_jmup") {
+		t.Fatal("expected cataloged tricks to be validated")
+	}
+	if catalog.Cataloged(SkillGlitch) || !catalog.Knows(SkillGlitch, "ground_jump") {
+		t.Fatal("expected glitches to be known while none are cataloged")
+	}
+
+	err := catalog.Validate(Skills{
+		Tricks:   map[string]bool{"dc_jump": true, "dc_jmup": true},
+		Glitches: map[string]bool{"ground_jump": true},
+	})
+	if err == nil || err.Error() != `unknown trick "dc_jmup"` {
+		t.Fatalf("expected only the misspelled trick to be rejected, found %v", err)
+	}
+}
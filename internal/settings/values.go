@@ -675,3 +675,183 @@ func (this ScarecrowBehavior) String() string {
 		panic(slipup.Createf("unknown scarecrow behavior %x", uint(this)))
 	}
 }
+
+func (this StartingAge) Name() (string, error) {
+	switch this {
+	case StartAgeChild:
+		return "child", nil
+	case StartAgeAdult:
+		return "adult", nil
+	case StartAgeRandom:
+		return "random", nil
+	default:
+		return "", slipup.Createf("unknown starting age %x", uint8(this))
+	}
+}
+
+func (this StartingTimeOfDay) Name() (string, error) {
+	switch this {
+	case StartingTimeOfDayDefault:
+		return "default", nil
+	case StartingTimeOfDayRandom:
+		return "random", nil
+	case StartingTimeOfDaySunrise:
+		return "sunrise", nil
+	case StartingTimeOfDayMorning:
+		return "morning", nil
+	case StartingTimeOfDayNoon:
+		return "noon", nil
+	case StartingTimeOfDayAfternoon:
+		return "afternoon", nil
+	case StartingTimeOfDaySunset:
+		return "sunset", nil
+	case StartingTimeOfDayEvening:
+		return "evening", nil
+	case StartingTimeOfDayMidnight:
+		return "midnight", nil
+	case StartingTimeOfDayWitching:
+		return "witching-hour", nil
+	default:
+		return "", slipup.Createf("unknown starting time of day %x", uint8(this))
+	}
+}
+
+func (this ItemPool) Name() (string, error) {
+	switch this {
+	case ItemPoolMinimal:
+		return "minimal", nil
+	case ItemPoolScarce:
+		return "scarce", nil
+	case ItemPoolDefault:
+		return "balanced", nil
+	case ItemPoolPlentiful:
+		return "plentiful", nil
+	case ItemPoolLudicrous:
+		return "ludicrous", nil
+	default:
+		return "", slipup.Createf("unknown item pool %x", uint8(this))
+	}
+}
+
+func (this GanonBKShuffleKind) Name() (string, error) {
+	switch this {
+	case GanonBKRemove:
+		return "remove", nil
+	case GanonBKVanilla:
+		return "vanilla", nil
+	case GanonBKDungeon:
+		return "dungeon", nil
+	case GanonBKRegional:
+		return "regional", nil
+	case GanonBKOverworld:
+		return "overworld", nil
+	case GanonBKAnyDungeon:
+		return "any_dungeon", nil
+	case GanonBKKeysanity:
+		return "keysanity", nil
+	case GanonBKOnLacs:
+		return "on_lacs", nil
+	case GanonBKStones:
+		return "stones", nil
+	case GanonBKMedallions:
+		return "medallions", nil
+	case GanonBKDungeonRewards:
+		return "dungeons", nil
+	case GanonBKTokens:
+		return "tokens", nil
+	case GanonBKHearts:
+		return "hearts", nil
+	case GanonBKTriforcePieces:
+		return "triforce", nil
+	default:
+		return "", slipup.Createf("unknown ganon boss key shuffle %x", uint16(this))
+	}
+}
+
+func (this Spawn) Name() (string, error) {
+	switch this {
+	case SpawnVanilla:
+		return "vanilla", nil
+	case RandomSpawn:
+		return "random", nil
+	case SetSpawnLocation:
+		return "set", nil
+	default:
+		return "", slipup.Createf("unknown spawn %x", uint64(this))
+	}
+}
+
+func (this ShuffleSongs) Name() (string, error) {
+	switch this {
+	case ShuffleSongsOnSong:
+		return "song", nil
+	case ShuffleSongsOnRewards:
+		return "dungeon", nil
+	case ShuffleSongsAnywhere:
+		return "any", nil
+	default:
+		return "", slipup.Createf("unknown song shuffle %x", uint8(this))
+	}
+}
+
+func (this ShuffleTokens) Name() (string, error) {
+	switch this {
+	case ShuffleGoldTokenOff:
+		return "off", nil
+	case ShuffleGoldTokenDungeons:
+		return "dungeons", nil
+	case ShuffleGoldTokenOverworld:
+		return "overworld", nil
+	default:
+		return "", slipup.Createf("unknown token shuffle %x", uint8(this))
+	}
+}
+
+func (this ShuffleFreestandings) Name() (string, error) {
+	switch this {
+	case ShuffleFreestandingsOff:
+		return "off", nil
+	case ShuffleFreestandingsDungeon:
+		return "dungeons", nil
+	case ShuffleFreestandingsOverworld:
+		return "overworld", nil
+	default:
+		return "", slipup.Createf("unknown freestanding shuffle %x", uint8(this))
+	}
+}
+
+func (this InteriorShuffle) Name() (string, error) {
+	return offSimpleAll(uint8(this), "interior shuffle")
+}
+
+func (this DungeonEntranceShuffle) Name() (string, error) {
+	return offSimpleAll(uint8(this), "dungeon entrance shuffle")
+}
+
+func (this BossShuffle) Name() (string, error) {
+	return offSimpleAll(uint8(this), "boss shuffle")
+}
+
+func offSimpleAll(value uint8, setting string) (string, error) {
+	switch value {
+	case 0:
+		return "off", nil
+	case 2:
+		return "simple", nil
+	case 4:
+		return "all", nil
+	default:
+		return "", slipup.Createf("unknown %s %x", setting, value)
+	}
+}
+
+func (this MapsCompasses) Name() (string, error) {
+	switch {
+	case this == MapsCompassesStartWith:
+		return "startwith", nil
+	case this <= MapsCompassesAnywhere:
+		return KeyShuffle(this).String(), nil
+	default:
+		return "", slipup.Createf("unknown map and compass shuffle %x", uint16(this))
+	}
+}
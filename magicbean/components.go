@@ -28,10 +28,23 @@ type ScriptSource string
 type ScriptParsed struct{ ast.Node }
 
 type RuleSource string
-type RuleParsed struct{ ast.Node }
-type RuleOptimized struct{ ast.Node }
+type RuleParsed struct {
+	ast.Node
+	Spans ast.Spans
+}
+type RuleOptimized struct {
+	ast.Node
+	Spans ast.Spans
+}
 type RuleCompiled compiler.Bytecode
 
+// how many optimization passes a rule took, Converged is false if it hit
+// the cap while still changing
+type RuleOptimizePasses struct {
+	Passes    int
+	Converged bool
+}
+
 type HeldAt ocm.Entity
 type HoldsToken ocm.Entity
 type Empty struct{}
@@ -51,6 +64,13 @@ type TimePassess struct{}
 type CollectablePriority uint8
 type WorldGraphRoot struct{}
 
+// the ootr name of a placement, which is keyed by its region so that
+// vanilla and MQ regions holding the same location stay distinct
+type LocationName string
+
+// placement that may only hold junk, reported apart from reached placements
+type Excluded struct{}
+
 const (
 	_             EdgeKind = 0
 	EdgeTransit   EdgeKind = 0x69
@@ -5,22 +5,23 @@ import (
 	"fmt"
 	"github.com/etc-sudonters/substrate/skelly/bitset32"
 	"github.com/etc-sudonters/substrate/skelly/graph32"
+	"runtime/pprof"
 	"sudonters/libzootr/mido"
 	"sudonters/libzootr/mido/code"
 	"sudonters/libzootr/mido/compiler"
 	"sudonters/libzootr/mido/objects"
 	"sudonters/libzootr/table/ocm"
 
-	"github.com/etc-sudonters/substrate/dontio"
 	"github.com/etc-sudonters/substrate/slipup"
 )
 
 type ExplorableEdge struct {
-	Kind   EdgeKind
-	Entity ocm.Entity
-	Rule   RuleCompiled
-	Src    RuleSource
-	Name   Name
+	Kind     EdgeKind
+	Entity   ocm.Entity
+	Rule     RuleCompiled
+	Src      RuleSource
+	Name     Name
+	Excluded bool
 }
 
 type ExplorableWorld struct {
@@ -34,10 +35,12 @@ func (this ExplorableWorld) Edge(from, to graph32.Node) (ExplorableEdge, bool) {
 }
 
 type Exploration struct {
-	VM      mido.VM
-	Visited *bitset32.Bitset
-	Workset *bitset32.Bitset
-	Objects *objects.Table
+	Evaluator mido.Evaluator
+	Visited   *bitset32.Bitset
+	Workset   *bitset32.Bitset
+	// when set each rule is named before it's evaluated and evaluation is
+	// labelled with its name for CPU profiles
+	Profile *mido.Profile
 }
 
 func (this *Exploration) evaluateRule(bytecode compiler.Bytecode) bool {
@@ -50,37 +53,39 @@ func (this *Exploration) evaluateRule(bytecode compiler.Bytecode) bool {
 		}
 	}
 
-	answer, vmErr := this.VM.Execute(bytecode)
+	answer, vmErr := this.Evaluator.Execute(bytecode)
 	if vmErr != nil {
 		fmt.Println(vmErr)
 		answer = objects.PackedFalse
 	}
 
-	return this.VM.Truthy(answer)
+	return this.Evaluator.Truthy(answer)
 }
 
+// see zoodle debug-rule to list or step through a single edge's rule
 func (this *Exploration) CanTransit(ctx context.Context, world *ExplorableWorld, from, to graph32.Node) bool {
-	std, nostd := dontio.StdFromContext(ctx)
-	slipup.PanicOnError(nostd)
-
 	edge, exists := world.Edge(from, to)
 	if !exists {
 		panic(fmt.Errorf("no edge registered between %d %d", from, to))
 	}
-	fmt.Printf("exploring %q\n", edge.Name)
-	if edge.Src != "" {
-		std.WriteLineOut(string(edge.Src))
-	}
 	bytecode := compiler.Bytecode(edge.Rule)
-	this.VM.Dis(std.Out, bytecode)
-	result := this.evaluateRule(bytecode)
-	std.WriteLineOut("\tcrossed? %t\n\n", result)
+	var result bool
+	if this.Profile == nil {
+		result = this.evaluateRule(bytecode)
+	} else {
+		this.Profile.Rule(uint32(edge.Entity), string(edge.Name))
+		pprof.Do(ctx, pprof.Labels("rule", string(edge.Name)), func(context.Context) {
+			result = this.evaluateRule(bytecode)
+		})
+	}
 	return result
 }
 
 type ExplorationResults struct {
 	Pending bitset32.Bitset
 	Reached bitset32.Bitset
+	// reached placements that are disabled or excluded
+	Excluded bitset32.Bitset
 }
 
 func (this *ExplorableWorld) ExploreAvailableEdges(ctx context.Context, xplr *Exploration) ExplorationResults {
@@ -94,8 +99,12 @@ func (this *ExplorableWorld) ExploreAvailableEdges(ctx context.Context, xplr *Ex
 			if xplr.CanTransit(ctx, this, current, neighbor) {
 				bitset32.Unset(&neighbors, neighbor)
 				bitset32.Set(xplr.Workset, neighbor)
-				bitset32.Set(&results.Reached, neighbor)
 				bitset32.Set(xplr.Visited, neighbor)
+				if edge, _ := this.Edge(current, neighbor); edge.Excluded {
+					bitset32.Set(&results.Excluded, neighbor)
+				} else {
+					bitset32.Set(&results.Reached, neighbor)
+				}
 			}
 		}
 
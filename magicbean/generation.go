@@ -5,14 +5,16 @@ import (
 	"sudonters/libzootr/internal/settings"
 	"sudonters/libzootr/table/ocm"
 
+	"sudonters/libzootr/mido/compiler"
 	"sudonters/libzootr/mido/objects"
 )
 
 type Generation struct {
-	Entities  *ocm.Entities
-	World     ExplorableWorld
-	Objects   objects.Table
-	Inventory Inventory
-	Rng       rand.Rand
-	Settings  settings.Zootr
+	Entities    *ocm.Entities
+	World       ExplorableWorld
+	Objects     objects.Table
+	Subroutines []compiler.Bytecode
+	Inventory   Inventory
+	Rng         rand.Rand
+	Settings    settings.Zootr
 }
@@ -5,11 +5,17 @@ import (
 )
 
 func NewInventory() Inventory {
-	return Inventory{make(map[ocm.Entity]float64)}
+	return Inventory{onhand: make(map[ocm.Entity]float64)}
 }
 
 type Inventory struct {
-	onhand map[ocm.Entity]float64
+	onhand  map[ocm.Entity]float64
+	version uint64
+}
+
+// changes every time something is collected or removed
+func (this *Inventory) Version() uint64 {
+	return this.version
 }
 
 func (this *Inventory) CollectOne(entity ocm.Entity) {
@@ -19,10 +25,14 @@ func (this *Inventory) CollectOne(entity ocm.Entity) {
 func (this *Inventory) Collect(entity ocm.Entity, n float64) {
 	has := this.onhand[entity]
 	this.onhand[entity] = has + n
+	this.version++
 }
 
 func (this *Inventory) Remove(entity ocm.Entity, n float64) float64 {
 	has := this.onhand[entity]
+	if has > 0 {
+		this.version++
+	}
 
 	switch {
 	case has == 0:
@@ -31,7 +31,7 @@ type BuiltIns struct {
 	IsAdult                 objects.BuiltInFunction `libzootr:"is_adult,params=0"`
 	IsChild                 objects.BuiltInFunction `libzootr:"is_child,params=0"`
 	IsStartingAge           objects.BuiltInFunction `libzootr:"is_starting_age,params=0"`
-	NeedsHeartForDamageMult objects.BuiltInFunction `libzootr:"needs_hearts_for_damage_multipler,params=3"`
+	NeedsHeartForDamageMult objects.BuiltInFunction `libzootr:"needs_hearts_for_damage_multipler,params=1"`
 }
 
 func (this BuiltIns) Table() objects.BuiltInFunctions {
@@ -54,21 +54,22 @@ func (this BuiltIns) Table() objects.BuiltInFunctions {
 }
 
 func CreateBuiltInDefs() []objects.BuiltInFunctionDef {
+	token, number, str := objects.ArgToken, objects.ArgNumber, objects.ArgString
 	return []objects.BuiltInFunctionDef{
-		{Name: "check_tod_access", Params: 1},
-		{Name: "has", Params: 2},
-		{Name: "has_anyof", Params: -1},
+		{Name: "check_tod_access", Params: 1, Args: []objects.ArgKind{str}},
+		{Name: "has", Params: 2, Args: []objects.ArgKind{token, number}},
+		{Name: "has_anyof", Params: -1, Args: []objects.ArgKind{token}},
 		{Name: "has_bottle", Params: 0},
-		{Name: "has_dungeon_rewards", Params: 1},
-		{Name: "has_every", Params: -1},
-		{Name: "has_hearts", Params: 1},
-		{Name: "has_medallions", Params: 1},
-		{Name: "has_notes_for_song", Params: 1},
-		{Name: "has_stones", Params: 1},
+		{Name: "has_dungeon_rewards", Params: 1, Args: []objects.ArgKind{number}},
+		{Name: "has_every", Params: -1, Args: []objects.ArgKind{token}},
+		{Name: "has_hearts", Params: 1, Args: []objects.ArgKind{number}},
+		{Name: "has_medallions", Params: 1, Args: []objects.ArgKind{number}},
+		{Name: "has_notes_for_song", Params: 1, Args: []objects.ArgKind{token}},
+		{Name: "has_stones", Params: 1, Args: []objects.ArgKind{number}},
 		{Name: "is_adult", Params: 0},
 		{Name: "is_child", Params: 0},
 		{Name: "is_starting_age", Params: 0},
-		{Name: "needs_hearts_for_damage_multipler", Params: 1},
+		{Name: "needs_hearts_for_damage_multipler", Params: 1, Args: []objects.ArgKind{number}},
 	}
 }
 
@@ -0,0 +1,137 @@
+package tracking
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sudonters/libzootr/internal/settings"
+	"sudonters/libzootr/magicbean"
+)
+
+// ootr setting names to the token they award
+var (
+	startingEquipment = map[string]name{
+		"kokiri_sword":        "Kokiri Sword",
+		"giants_knife":        "Giants Knife",
+		"biggoron_sword":      "Biggoron Sword",
+		"deku_shield":         "Deku Shield",
+		"hylian_shield":       "Hylian Shield",
+		"mirror_shield":       "Mirror Shield",
+		"goron_tunic":         "Goron Tunic",
+		"zora_tunic":          "Zora Tunic",
+		"iron_boots":          "Iron Boots",
+		"hover_boots":         "Hover Boots",
+		"magic":               "Magic Meter",
+		"strength":            "Progressive Strength Upgrade",
+		"scale":               "Progressive Scale",
+		"wallet":              "Progressive Wallet",
+		"stone_of_agony":      "Stone of Agony",
+		"deku_stick_capacity": "Deku Stick Capacity",
+		"deku_nut_capacity":   "Deku Nut Capacity",
+		"defense":             "Double Defense",
+	}
+
+	startingInventory = map[string]name{
+		"deku_stick":     "Deku Stick (1)",
+		"deku_nut":       "Deku Nuts (5)",
+		"bombs":          "Bomb Bag",
+		"bow":            "Bow",
+		"fire_arrow":     "Fire Arrows",
+		"dins_fire":      "Dins Fire",
+		"slingshot":      "Slingshot",
+		"ocarina":        "Ocarina",
+		"bombchus":       "Bombchus",
+		"hookshot":       "Progressive Hookshot",
+		"ice_arrow":      "Ice Arrows",
+		"farores_wind":   "Farores Wind",
+		"boomerang":      "Boomerang",
+		"lens":           "Lens of Truth",
+		"beans":          "Magic Bean",
+		"megaton_hammer": "Megaton Hammer",
+		"light_arrow":    "Light Arrows",
+		"nayrus_love":    "Nayrus Love",
+		"bottle":         "Bottle",
+		"rutos_letter":   "Rutos Letter",
+		"zeldas_letter":  "Zeldas Letter",
+		"weird_egg":      "Weird Egg",
+		"pocket_egg":     "Pocket Egg",
+		"claim_check":    "Claim Check",
+		"gerudo_card":    "Gerudo Membership Card",
+	}
+
+	startingSongs = map[string]name{
+		"zeldas_lullaby":     "Zeldas Lullaby",
+		"eponas_song":        "Eponas Song",
+		"sarias_song":        "Sarias Song",
+		"suns_song":          "Suns Song",
+		"song_of_time":       "Song of Time",
+		"song_of_storms":     "Song of Storms",
+		"minuet_of_forest":   "Minuet of Forest",
+		"bolero_of_fire":     "Bolero of Fire",
+		"serenade_of_water":  "Serenade of Water",
+		"requiem_of_spirit":  "Requiem of Spirit",
+		"nocturne_of_shadow": "Nocturne of Shadow",
+		"prelude_of_light":   "Prelude of Light",
+	}
+)
+
+// derives the inventory a seed starts with from its settings. Every unknown
+// setting name or token name is reported, the returned inventory holds
+// everything that could be resolved. This lives here rather than in
+// magicbean because it resolves names through Tokens and tracking already
+// imports magicbean. It takes no rng since nothing is chosen randomly, a
+// seed starts with exactly what its settings list.
+func StartingInventory(these *settings.Zootr, tokens Tokens) (magicbean.Inventory, error) {
+	inventory := magicbean.NewInventory()
+
+	var errs []error
+	collect := func(name name, qty float64) {
+		token, exists := tokens.Lookup(name)
+		if !exists {
+			errs = append(errs, fmt.Errorf("unknown starting token %q", name))
+			return
+		}
+		inventory.Collect(token.Entity(), qty)
+	}
+
+	collectFrom := func(kind string, named map[string]name, counts map[string]uint8) {
+		for _, setting := range slices.Sorted(maps.Keys(counts)) {
+			token, exists := named[setting]
+			if !exists {
+				errs = append(errs, fmt.Errorf("unknown starting %s %q", kind, setting))
+				continue
+			}
+			collect(token, float64(counts[setting]))
+		}
+	}
+
+	for _, token := range these.Starting.Tokens {
+		collect(name(token), 1)
+	}
+	collectFrom("equipment", startingEquipment, these.Starting.Equipment)
+	collectFrom("item", startingInventory, these.Starting.Inventory)
+	collectFrom("song", startingSongs, these.Starting.Songs)
+
+	// HasHearts counts only heart pieces
+	if these.Starting.Hearts > 0 {
+		collect("Piece of Heart", 4*float64(these.Starting.Hearts))
+	}
+
+	if these.Starting.Rupees > 0 {
+		collect("Rupee (1)", float64(these.Starting.Rupees))
+	}
+
+	if these.Starting.WithConsumables {
+		collect("Deku Stick (1)", 10)
+		collect("Deku Nuts (5)", 4)
+	}
+
+	// plant_beans is a setting logic reads, it grants no Magic Beans
+
+	if these.Locations.OpenDoorOfTime {
+		collect("Time Travel", 1)
+	}
+
+	return inventory, errors.Join(errs...)
+}
@@ -0,0 +1,92 @@
+package tracking_test
+
+import (
+	"strings"
+	"sudonters/libzootr/cmd/zoodle/bootstrap"
+	"sudonters/libzootr/internal/settings"
+	"sudonters/libzootr/magicbean"
+	"sudonters/libzootr/magicbean/tracking"
+	"testing"
+)
+
+func startingTokens(t *testing.T, names ...magicbean.Name) tracking.Tokens {
+	t.Helper()
+	_, entities := bootstrap.Phase1_InitializeStorage(nil)
+	tokens, err := tracking.NewTokens(entities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if _, err := tokens.Named(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tokens
+}
+
+func TestStartingInventoryCollectsSettings(t *testing.T) {
+	tokens := startingTokens(t, "Piece of Heart", "Progressive Hookshot", "Deku Shield", "Eponas Song")
+	var these settings.Zootr
+	these.Starting.Hearts = 3
+	these.Starting.Tokens = []string{"Progressive Hookshot", "Progressive Hookshot"}
+	these.Starting.Equipment = map[string]uint8{"deku_shield": 1}
+	these.Starting.Songs = map[string]uint8{"eponas_song": 1}
+
+	inventory, err := tracking.StartingInventory(&these, tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[magicbean.Name]float64{
+		"Piece of Heart":       12,
+		"Progressive Hookshot": 2,
+		"Deku Shield":          1,
+		"Eponas Song":          1,
+	} {
+		if count := inventory.Count(tokens.MustGet(name).Entity()); count != expected {
+			t.Errorf("expected %v %s, found %v", expected, name, count)
+		}
+	}
+}
+
+func TestStartingInventoryReportsUnknownNames(t *testing.T) {
+	tokens := startingTokens(t, "Deku Shield")
+	var these settings.Zootr
+	these.Starting.Tokens = []string{"Deku Shield", "Progressive Hookshot"}
+	these.Starting.Inventory = map[string]uint8{"hookshot": 1, "clawshot": 1}
+
+	inventory, err := tracking.StartingInventory(&these, tokens)
+	if err == nil {
+		t.Fatal("expected unknown names to be reported")
+	}
+	for _, expected := range []string{
+		`unknown starting token "Progressive Hookshot"`,
+		`unknown starting item "clawshot"`,
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in %q", expected, err)
+		}
+	}
+	if count := inventory.Count(tokens.MustGet("Deku Shield").Entity()); count != 1 {
+		t.Fatalf("expected resolved tokens to still be collected, found %v Deku Shield", count)
+	}
+	if _, created := tokens.Lookup("Progressive Hookshot"); created {
+		t.Fatal("expected lookup to not create unknown tokens")
+	}
+}
+
+func TestPlantBeansGrantsNoMagicBeans(t *testing.T) {
+	tokens := startingTokens(t, "Magic Bean")
+	var these settings.Zootr
+	these.Starting.PlantBeans = true
+
+	inventory, err := tracking.StartingInventory(&these, tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := inventory.Count(tokens.MustGet("Magic Bean").Entity()); count != 0 {
+		t.Fatalf("expected plant_beans to grant no Magic Beans, found %v", count)
+	}
+	if planted, err := these.Bool("plant_beans"); err != nil || !planted {
+		t.Fatalf("expected logic to read plant_beans as a setting, found %t %v", planted, err)
+	}
+}
@@ -29,6 +29,11 @@ func (this Tokens) Named(name name) (Token, error) {
 	return Token{token, name}, err
 }
 
+func (this Tokens) Lookup(name name) (Token, bool) {
+	token, exists := this.tokens.Lookup(name)
+	return Token{token, name}, exists
+}
+
 func (this Tokens) MustGet(name name) Token {
 	token := this.tokens.MustGet(name)
 	return Token{token, name}
@@ -52,6 +52,16 @@ func (this *Tracked[K]) For(key K) (ocm.Proxy, error) {
 	return entity, nil
 }
 
+// like For but never creates an entity for an unknown key
+func (this *Tracked[K]) Lookup(key K) (ocm.Proxy, bool) {
+	entity, exists := this.cache[key]
+	if !exists {
+		return ocm.Proxy{}, false
+	}
+	proxy, err := this.parent.Proxy(entity)
+	return proxy, err == nil
+}
+
 func (this *Tracked[K]) MustGet(key K) ocm.Proxy {
 	entity, exists := this.cache[key]
 	if !exists {
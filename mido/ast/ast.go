@@ -156,11 +156,20 @@ func LookUpNodeInTable(tbl *symbols.Table, node Node) *symbols.Sym {
 }
 
 func Parse(input string, symbols *symbols.Table, grammar peruse.Grammar[ruleparser.Tree]) (Node, error) {
-	parser := peruse.NewParser(grammar, ruleparser.NewRulesLexer(input))
-	pt, parseErr := parser.ParseAt(ruleparser.LOWEST)
+	pt, parseErr := ruleparser.Parse(grammar, input)
 	if parseErr != nil {
 		return nil, parseErr
 	}
 
 	return Lower(symbols, pt)
 }
+
+// same as Parse but also records where each node came from in input
+func ParseWithSpans(input string, symbols *symbols.Table, grammar peruse.Grammar[ruleparser.Tree]) (Node, Spans, error) {
+	pt, parseErr := ruleparser.Parse(grammar, input)
+	if parseErr != nil {
+		return nil, nil, parseErr
+	}
+
+	return LowerWithSpans(symbols, pt)
+}
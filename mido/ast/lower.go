@@ -3,6 +3,7 @@ package ast
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"sudonters/libzootr/ruleparser"
 	"sudonters/libzootr/mido/symbols"
@@ -13,6 +14,12 @@ var (
 	ErrUnknownOperator = errors.New("unknown operator")
 	ErrUnknownLiteral  = errors.New("unknown literal type")
 
+	// list settings that have their own compiler function
+	containsCalls = map[string]string{
+		"dungeon_shortcuts": "region_has_shortcuts",
+		"skipped_trials":    "is_trial_skipped",
+	}
+
 	compareOps = map[ruleparser.BinOpKind]CompareOp{
 		ruleparser.BinOpEq:    CompareEq,
 		ruleparser.BinOpNotEq: CompareNq,
@@ -35,24 +42,69 @@ func (err CouldNotLowerTree) Error() string {
 	return fmt.Sprintf("could not lower parse tree: %s", err.Node.Type())
 }
 
+func (err CouldNotLowerTree) Unwrap() error {
+	return err.Cause
+}
+
+func (err CouldNotLowerTree) Location() ruleparser.Span {
+	return err.Node.Location()
+}
+
 func Lower(tbl *symbols.Table, node ruleparser.Tree) (Node, error) {
+	lowering := lowering{tbl, nil}
+	return lowering.lower(node)
+}
+
+// lowers the tree and records where each lowered node came from
+func LowerWithSpans(tbl *symbols.Table, node ruleparser.Tree) (Node, Spans, error) {
+	lowering := lowering{tbl, make(Spans)}
+	lowered, err := lowering.lower(node)
+	return lowered, lowering.spans, err
+}
+
+type lowering struct {
+	tbl   *symbols.Table
+	spans Spans
+}
+
+func (this lowering) lower(node ruleparser.Tree) (Node, error) {
+	lowered, err := this.lowerTree(node)
+	if err == nil && this.spans != nil {
+		this.spans.record(lowered, node.Location())
+	}
+	return lowered, err
+}
+
+func (this lowering) lowerTree(node ruleparser.Tree) (Node, error) {
+	tbl := this.tbl
 	switch node := node.(type) {
 	case *ruleparser.BinOp:
 		switch node.Op {
 		case ruleparser.BinOpContains:
-			if ident, isIdent := node.Right.(*ruleparser.Identifier); isIdent && ident.Value == "dungeon_shortcuts" {
-				if literal, isLiteral := node.Left.(*ruleparser.Literal); isLiteral && literal.Kind == ruleparser.LiteralStr {
-					return createCall(tbl, "region_has_shortcuts", literal)
-				}
+			return this.lowerContains(node)
+		case ruleparser.BinOpNotContains:
+			contains, err := this.lowerContains(node)
+			if err != nil {
+				return nil, err
 			}
-
-			return nil, fmt.Errorf("invalid contains construction: %#v", node)
-		case ruleparser.BinOpEq, ruleparser.BinOpNotEq, ruleparser.BinOpLt:
-			lhs, lhsErr := Lower(tbl, node.Left)
-			rhs, rhsErr := Lower(tbl, node.Right)
+			return Invert{contains}, nil
+		case ruleparser.BinOpEq, ruleparser.BinOpNotEq, ruleparser.BinOpLt,
+			ruleparser.BinOpGt, ruleparser.BinOpLtEq, ruleparser.BinOpGtEq:
+			lhs, lhsErr := this.lower(node.Left)
+			rhs, rhsErr := this.lower(node.Right)
 			if lhsErr != nil || rhsErr != nil {
 				return nil, CouldNotLowerTree{node, errors.Join(lhsErr, rhsErr)}
 			}
+
+			// only <, == and != exist after lowering
+			switch node.Op {
+			case ruleparser.BinOpGt:
+				return Compare{LHS: rhs, RHS: lhs, Op: CompareLt}, nil
+			case ruleparser.BinOpLtEq:
+				return Invert{Compare{LHS: rhs, RHS: lhs, Op: CompareLt}}, nil
+			case ruleparser.BinOpGtEq:
+				return Invert{Compare{LHS: lhs, RHS: rhs, Op: CompareLt}}, nil
+			}
 			op := compareOps[node.Op]
 
 			return Compare{
@@ -66,16 +118,16 @@ func Lower(tbl *symbols.Table, node ruleparser.Tree) (Node, error) {
 	case *ruleparser.BoolOp:
 		switch node.Op {
 		case ruleparser.BoolOpAnd:
-			lhs, lhsErr := Lower(tbl, node.Left)
-			rhs, rhsErr := Lower(tbl, node.Right)
+			lhs, lhsErr := this.lower(node.Left)
+			rhs, rhsErr := this.lower(node.Right)
 			if lhsErr != nil || rhsErr != nil {
 				return nil, CouldNotLowerTree{node, errors.Join(lhsErr, rhsErr)}
 			}
 			every := Every{lhs, rhs}
 			return every.Flatten(), nil
 		case ruleparser.BoolOpOr:
-			lhs, lhsErr := Lower(tbl, node.Left)
-			rhs, rhsErr := Lower(tbl, node.Right)
+			lhs, lhsErr := this.lower(node.Left)
+			rhs, rhsErr := this.lower(node.Right)
 			if lhsErr != nil || rhsErr != nil {
 				return nil, CouldNotLowerTree{node, errors.Join(lhsErr, rhsErr)}
 			}
@@ -88,33 +140,46 @@ func Lower(tbl *symbols.Table, node ruleparser.Tree) (Node, error) {
 		var invoke Invoke
 		var err error
 
-		invoke.Target, err = Lower(tbl, node.Callee)
+		invoke.Target, err = this.lower(node.Callee)
 		if err != nil {
 			return nil, CouldNotLowerTree{node, err}
 		}
 
-		invoke.Args = make([]Node, len(node.Args))
-		for i := range node.Args {
+		args, err := this.positional(node)
+		if err != nil {
+			return nil, CouldNotLowerTree{node, err}
+		}
+
+		invoke.Args = make([]Node, len(args))
+		for i := range args {
 			var argErr error
-			invoke.Args[i], argErr = Lower(tbl, node.Args[i])
+			invoke.Args[i], argErr = this.lower(args[i])
 			if argErr != nil {
 				err = errors.Join(err, argErr)
 			}
 		}
 
 		return invoke, err
+	case *ruleparser.Attribute:
+		if name, isSetting := settingName(node); isSetting {
+			return this.lower(&ruleparser.Identifier{Value: name, At: node.At})
+		}
+
+		return nil, CouldNotLowerTree{node, errors.New("invalid attribute construction")}
+	case *ruleparser.Keyword:
+		return nil, CouldNotLowerTree{node, errors.New("keyword argument outside of call")}
 	case *ruleparser.Identifier:
 		if trimmed, didTrim := strings.CutPrefix(node.Value, isTrickEnabledPrefix); didTrim {
 			//TODO how to not special case
 			if node.Value != "logic_rules" {
-				return createCall(tbl, "is_trick_enabled", ruleparser.StringLiteral(trimmed))
+				return this.createCall("is_trick_enabled", ruleparser.StringLiteral(trimmed))
 			}
 		}
 		if trimmed, didTrim := strings.CutPrefix(node.Value, isAdvTrickEnabledPrefix); didTrim {
-			return createCall(tbl, "is_trick_enabled", ruleparser.StringLiteral(trimmed))
+			return this.createCall("is_trick_enabled", ruleparser.StringLiteral(trimmed))
 		}
 		if trimmed, didTrim := strings.CutPrefix(node.Value, isGlitchEnabledPrefix); didTrim {
-			return createCall(tbl, "is_glitch_enabled", ruleparser.StringLiteral(trimmed))
+			return this.createCall("is_glitch_enabled", ruleparser.StringLiteral(trimmed))
 		}
 
 		symbol := tbl.Declare(node.Value, symbols.UNKNOWN)
@@ -131,19 +196,24 @@ func Lower(tbl *symbols.Table, node ruleparser.Tree) (Node, error) {
 			return nil, CouldNotLowerTree{node, ErrUnknownLiteral}
 		}
 	case *ruleparser.Subscript:
-		if target, isIdent := node.Target.(*ruleparser.Identifier); isIdent && target.Value == "skipped_trials" {
-			if trial, isIdent := node.Index.(*ruleparser.Identifier); isIdent {
-				return createCall(tbl, "is_trial_skipped", ruleparser.StringLiteral(trial.Value))
+		if target, isSetting := settingName(node.Target); isSetting && target == "skipped_trials" {
+			switch trial := node.Index.(type) {
+			case *ruleparser.Identifier:
+				return this.createCall("is_trial_skipped", ruleparser.StringLiteral(trial.Value))
+			case *ruleparser.Literal:
+				if trial.Kind == ruleparser.LiteralStr {
+					return this.createCall("is_trial_skipped", trial)
+				}
 			}
 		}
 
-		return nil, fmt.Errorf("invalid subscript construction %#v", node)
+		return nil, CouldNotLowerTree{node, errors.New("invalid subscript construction")}
 	case *ruleparser.Tuple:
-		return createCall(tbl, "has", node.Elems...)
+		return this.createCall("has", node.Elems...)
 	case *ruleparser.UnaryOp:
 		switch node.Op {
 		case ruleparser.UnaryNot:
-			body, err := Lower(tbl, node.Target)
+			body, err := this.lower(node.Target)
 			if err != nil {
 				return nil, CouldNotLowerTree{node, err}
 			}
@@ -155,8 +225,122 @@ func Lower(tbl *symbols.Table, node ruleparser.Tree) (Node, error) {
 	return nil, CouldNotLowerTree{node, ErrUnknownNode}
 }
 
-func createCall(tbl *symbols.Table, name string, args ...ruleparser.Tree) (Node, error) {
-	symbol := tbl.Declare(name, symbols.FUNCTION)
+// x in (a, b) is x == a or x == b, x in setting asks the compiler if the
+// list setting contains x
+func (this lowering) lowerContains(node *ruleparser.BinOp) (Node, error) {
+	if tuple, isTuple := node.Right.(*ruleparser.Tuple); isTuple {
+		needle, err := this.lower(node.Left)
+		if err != nil {
+			return nil, CouldNotLowerTree{node, err}
+		}
+
+		anyOf := make(AnyOf, len(tuple.Elems))
+		for i := range tuple.Elems {
+			elem, elemErr := this.lower(tuple.Elems[i])
+			if elemErr != nil {
+				err = errors.Join(err, elemErr)
+				continue
+			}
+			anyOf[i] = Compare{LHS: needle, RHS: elem, Op: CompareEq}
+		}
+
+		if err != nil {
+			return nil, CouldNotLowerTree{node, err}
+		}
+		if len(anyOf) == 1 {
+			return anyOf[0], nil
+		}
+		return anyOf, nil
+	}
+
+	if setting, isSetting := settingName(node.Right); isSetting {
+		if call, special := containsCalls[setting]; special {
+			return this.createCall(call, node.Left)
+		}
+		return this.createCall("setting_contains", ruleparser.StringLiteral(setting), node.Left)
+	}
+
+	return nil, CouldNotLowerTree{node, errors.New("invalid contains construction")}
+}
+
+// places keyword arguments using the callee's parameter names, trailing
+// parameters may be omitted and are left to the callee
+func (this lowering) positional(node *ruleparser.Call) ([]ruleparser.Tree, error) {
+	var args []ruleparser.Tree
+	var keywords []*ruleparser.Keyword
+	for _, arg := range node.Args {
+		if keyword, isKeyword := arg.(*ruleparser.Keyword); isKeyword {
+			keywords = append(keywords, keyword)
+			continue
+		}
+		if len(keywords) != 0 {
+			return nil, errors.New("positional argument follows keyword argument")
+		}
+		args = append(args, arg)
+	}
+
+	if len(keywords) == 0 {
+		return args, nil
+	}
+
+	var params []string
+	var known bool
+	if callee, isIdent := node.Callee.(*ruleparser.Identifier); isIdent {
+		if symbol := this.tbl.LookUpByName(callee.Value); symbol != nil {
+			params, known = this.tbl.Params(symbol)
+		}
+	}
+	if !known {
+		return nil, errors.New("keyword arguments require a known signature")
+	}
+	if len(args) > len(params) {
+		return nil, fmt.Errorf("expected at most %d arguments but received %d", len(params), len(args))
+	}
+
+	placed := make([]ruleparser.Tree, len(params))
+	copy(placed, args)
+	for _, keyword := range keywords {
+		i := slices.Index(params, keyword.Name)
+		if i == -1 {
+			return nil, fmt.Errorf("unexpected keyword argument %q", keyword.Name)
+		}
+		if placed[i] != nil {
+			return nil, fmt.Errorf("multiple values for argument %q", keyword.Name)
+		}
+		placed[i] = keyword.Value
+	}
+
+	end := len(placed)
+	for end > 0 && placed[end-1] == nil {
+		end--
+	}
+	for i := range end {
+		if placed[i] == nil {
+			return nil, fmt.Errorf("missing argument %q", params[i])
+		}
+	}
+	return placed[:end], nil
+}
+
+// settings may be named directly or as settings.name or world.settings.name
+func settingName(node ruleparser.Tree) (string, bool) {
+	switch node := node.(type) {
+	case *ruleparser.Identifier:
+		return node.Value, true
+	case *ruleparser.Attribute:
+		switch target := node.Target.(type) {
+		case *ruleparser.Identifier:
+			return node.Name, target.Value == "settings"
+		case *ruleparser.Attribute:
+			world, isIdent := target.Target.(*ruleparser.Identifier)
+			return node.Name, isIdent && world.Value == "world" && target.Name == "settings"
+		}
+	}
+	return "", false
+}
+
+func (this lowering) createCall(name string, args ...ruleparser.Tree) (Node, error) {
+	symbol := this.tbl.Declare(name, symbols.FUNCTION)
 	invoke := Invoke{
 		Target: IdentifierFrom(symbol),
 		Args:   make([]Node, len(args)),
@@ -165,7 +349,7 @@ func createCall(tbl *symbols.Table, name string, args ...ruleparser.Tree) (Node,
 	var err error
 	for i := range args {
 		var argErr error
-		invoke.Args[i], argErr = Lower(tbl, args[i])
+		invoke.Args[i], argErr = this.lower(args[i])
 		if argErr != nil {
 			err = errors.Join(err, argErr)
 		}
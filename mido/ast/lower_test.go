@@ -0,0 +1,63 @@
+package ast
+
+import (
+	"sudonters/libzootr/mido/symbols"
+	"testing"
+)
+
+func TestLowersEquivalentForms(t *testing.T) {
+	syms := symbols.NewTable()
+	syms.SetParams(syms.Declare("can_live_dmg", symbols.COMPILER_FUNCTION), []string{"hearts", "fairy", "nayrus"})
+
+	equivalent := map[string]string{
+		"damage_multiplier in ('ohko', 'quadruple')":      "damage_multiplier == 'ohko' or damage_multiplier == 'quadruple'",
+		"damage_multiplier in ['ohko', 'quadruple']":      "damage_multiplier == 'ohko' or damage_multiplier == 'quadruple'",
+		"damage_multiplier not in ('ohko',)":              "not damage_multiplier == 'ohko'",
+		"1 < bridge_tokens <= 3":                          "1 < bridge_tokens and not 3 < bridge_tokens",
+		"bridge_tokens > 1":                               "1 < bridge_tokens",
+		"bridge_tokens >= 1":                              "not bridge_tokens < 1",
+		"settings.bridge == 'open'":                       "bridge == 'open'",
+		"world.settings.bridge == 'open'":                 "bridge == 'open'",
+		"'Forest' in skipped_trials":                      "skipped_trials[Forest]",
+		"settings.skipped_trials['Forest']":               "skipped_trials[Forest]",
+		"'Deku Tree' in world.settings.dungeon_shortcuts": "'Deku Tree' in dungeon_shortcuts",
+		"'Deku Tree' not in dungeon_shortcuts":            "not region_has_shortcuts('Deku Tree')",
+		"'Forest Temple' in key_rings":                    "setting_contains('key_rings', 'Forest Temple')",
+		"can_live_dmg(0.5, nayrus=False, fairy=True)":     "can_live_dmg(0.5, True, False)",
+		"can_live_dmg(hearts=1, fairy=False)":             "can_live_dmg(1, False)",
+	}
+
+	for source, expected := range equivalent {
+		lowered, err := Parse(source, &syms, grammar)
+		if err != nil {
+			t.Errorf("could not lower %q: %s", source, err)
+			continue
+		}
+		want, err := Parse(expected, &syms, grammar)
+		if err != nil {
+			t.Fatalf("could not lower %q: %s", expected, err)
+		}
+		if Hash(lowered) != Hash(want) {
+			t.Errorf("expected %q to lower like %q", source, expected)
+		}
+	}
+}
+
+func TestRejectsInvalidConstructions(t *testing.T) {
+	syms := symbols.NewTable()
+	syms.SetParams(syms.Declare("can_live_dmg", symbols.COMPILER_FUNCTION), []string{"hearts", "fairy", "nayrus"})
+
+	for _, source := range []string{
+		"is_adult in 5",
+		"world.bridge == 'open'",
+		"can_live_dmg(fairy=False)",
+		"can_live_dmg(1, magic=False)",
+		"can_live_dmg(1, hearts=2)",
+		"can_live_dmg(hearts=1, False)",
+		"can_use(item=Bow)",
+	} {
+		if _, err := Parse(source, &syms, grammar); err == nil {
+			t.Errorf("expected %q to fail lowering", source)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sudonters/libzootr/mido/symbols"
+	"sudonters/libzootr/ruleparser"
+)
+
+var ErrUnprintable = errors.New("node cannot be written as rule source")
+
+// renders node as rule source, see Lift
+func Print(tbl *symbols.Table, node Node) (string, error) {
+	return PrintWith(ruleparser.Printer{}, tbl, node)
+}
+
+func PrintWith(printer ruleparser.Printer, tbl *symbols.Table, node Node) (string, error) {
+	tree, err := Lift(tbl, node)
+	if err != nil {
+		return "", err
+	}
+	return printer.Print(tree), nil
+}
+
+// raises node back into a parse tree that lowers into node again. Symbols
+// whose name isn't an identifier are written with their escaped alias if one
+// is declared, otherwise as a 'quoted' name which lowers to a String instead
+// of the original Identifier.
+func Lift(tbl *symbols.Table, node Node) (ruleparser.Tree, error) {
+	switch node := node.(type) {
+	case AnyOf:
+		return liftChain(tbl, node, ruleparser.BoolOpOr, false)
+	case Every:
+		return liftChain(tbl, node, ruleparser.BoolOpAnd, true)
+	case Boolean:
+		return ruleparser.BoolLiteral(bool(node)), nil
+	case Number:
+		if node < 0 || math.IsNaN(float64(node)) || math.IsInf(float64(node), 0) {
+			return nil, fmt.Errorf("%w: number %v", ErrUnprintable, float64(node))
+		}
+		return ruleparser.NumberLiteral(node), nil
+	case String:
+		return ruleparser.StringLiteral(string(node)), nil
+	case Identifier:
+		symbol := tbl.LookUpByIndex(node.AsIndex())
+		if ruleparser.IsIdentifier(symbol.Name) {
+			return ruleparser.Identify(symbol.Name), nil
+		}
+		alias := symbols.Escape(symbol.Name)
+		if aliased := tbl.LookUpByName(alias); aliased != nil && aliased.Index == symbol.Index && ruleparser.IsIdentifier(alias) {
+			return ruleparser.Identify(alias), nil
+		}
+		return Lift(tbl, String(symbol.Name))
+	case Compare:
+		lhs, lhsErr := Lift(tbl, node.LHS)
+		rhs, rhsErr := Lift(tbl, node.RHS)
+		if err := errors.Join(lhsErr, rhsErr); err != nil {
+			return nil, err
+		}
+		var op ruleparser.BinOpKind
+		switch node.Op {
+		case CompareEq:
+			op = ruleparser.BinOpEq
+		case CompareNq:
+			op = ruleparser.BinOpNotEq
+		case CompareLt:
+			op = ruleparser.BinOpLt
+		default:
+			return nil, fmt.Errorf("%w: %d", ErrUnknownOperator, node.Op)
+		}
+		return &ruleparser.BinOp{Left: lhs, Op: op, Right: rhs}, nil
+	case Invert:
+		inner, err := Lift(tbl, node.Inner)
+		if err != nil {
+			return nil, err
+		}
+		return &ruleparser.UnaryOp{Op: ruleparser.UnaryNot, Target: inner}, nil
+	case Invoke:
+		callee, err := Lift(tbl, node.Target)
+		args := make([]ruleparser.Tree, len(node.Args))
+		for i := range node.Args {
+			var argErr error
+			args[i], argErr = Lift(tbl, node.Args[i])
+			err = errors.Join(err, argErr)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return ruleparser.MakeCall(callee, args), nil
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnknownNode, node)
+	}
+}
+
+// lowering flattens chains so they're lifted as left leaning and/or chains
+func liftChain(tbl *symbols.Table, nodes []Node, op ruleparser.BoolOpKind, empty bool) (ruleparser.Tree, error) {
+	if len(nodes) == 0 {
+		return ruleparser.BoolLiteral(empty), nil
+	}
+
+	var errs []error
+	lifted := make([]ruleparser.Tree, len(nodes))
+	for i := range nodes {
+		var err error
+		lifted[i], err = Lift(tbl, nodes[i])
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	tree := lifted[0]
+	for _, right := range lifted[1:] {
+		tree = &ruleparser.BoolOp{Left: tree, Op: op, Right: right}
+	}
+	return tree, nil
+}
@@ -0,0 +1,98 @@
+package ast
+
+import (
+	"errors"
+	"sudonters/libzootr/mido/symbols"
+	"testing"
+)
+
+func TestPrintRoundTrips(t *testing.T) {
+	syms := symbols.NewTable()
+	syms.Declare("has", symbols.BUILT_IN_FUNCTION)
+	goronTunic := syms.Declare("Goron Tunic", symbols.TOKEN)
+	syms.Alias(goronTunic, "Goron_Tunic")
+
+	sources := []string{
+		"has(Goron_Tunic, 1) and not is_adult",
+		"is_adult and (can_use(Hover_Boots) or has('Goron Tunic', 2))",
+		"logic_forest_vines or skipped_trials[Forest] or 'Forest Temple' in dungeon_shortcuts",
+		"damage_multiplier != 'ohko' and (Bow, 2) or 0 < bridge_tokens",
+		"not (is_adult or at_night) == False",
+		"has('Dodongo\\'s Cavern Boss Key', 1) and 'Ganon\\'s Castle' in dungeon_shortcuts",
+	}
+
+	for _, source := range sources {
+		lowered, err := Parse(source, &syms, grammar)
+		if err != nil {
+			t.Fatalf("could not parse %q: %s", source, err)
+		}
+
+		printed, err := Print(&syms, lowered)
+		if err != nil {
+			t.Fatalf("could not print %q: %s", source, err)
+		}
+
+		reparsed, err := Parse(printed, &syms, grammar)
+		if err != nil {
+			t.Fatalf("could not parse printed %q: %s", printed, err)
+		}
+
+		if Hash(lowered) != Hash(reparsed) {
+			t.Errorf("printed %q lowered differently than %q", printed, source)
+		}
+	}
+}
+
+func TestPrintUsesAliases(t *testing.T) {
+	syms := symbols.NewTable()
+	has := syms.Declare("has", symbols.BUILT_IN_FUNCTION)
+	goronTunic := syms.Declare("Goron Tunic", symbols.TOKEN)
+	syms.Alias(goronTunic, "Goron_Tunic")
+	generated := syms.Declare("Token#Kokiri Forest#0", symbols.TOKEN)
+
+	node := AnyOf{
+		Invoke{IdentifierFrom(has), []Node{IdentifierFrom(goronTunic), Number(1)}},
+		Invoke{IdentifierFrom(has), []Node{IdentifierFrom(generated), Number(1)}},
+		Every{},
+	}
+
+	printed, err := Print(&syms, node)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "has(Goron_Tunic, 1) or has('Token#Kokiri Forest#0', 1) or True"
+	if printed != expected {
+		t.Fatalf("expected %q but printed %q", expected, printed)
+	}
+
+	if _, err := Print(&syms, Number(-1)); !errors.Is(err, ErrUnprintable) {
+		t.Fatalf("expected negative numbers to be unprintable, got %v", err)
+	}
+}
+
+func TestPrintQuotesApostrophesInSymbolNames(t *testing.T) {
+	syms := symbols.NewTable()
+	has := syms.Declare("has", symbols.BUILT_IN_FUNCTION)
+	bossKey := syms.Declare("Dodongo's Cavern Boss Key", symbols.TOKEN)
+
+	node := Invoke{IdentifierFrom(has), []Node{IdentifierFrom(bossKey), Number(1)}}
+	printed, err := Print(&syms, node)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `has('Dodongo\'s Cavern Boss Key', 1)`
+	if printed != expected {
+		t.Fatalf("expected %q but printed %q", expected, printed)
+	}
+
+	reparsed, err := Parse(printed, &syms, grammar)
+	if err != nil {
+		t.Fatalf("could not parse printed %q: %s", printed, err)
+	}
+	quoted := Invoke{IdentifierFrom(has), []Node{String("Dodongo's Cavern Boss Key"), Number(1)}}
+	if Hash(reparsed) != Hash(quoted) {
+		t.Errorf("printed %q did not lower to the quoted name", printed)
+	}
+}
@@ -179,7 +179,8 @@ func RewriteIdentifier(i Identifier, _ Rewriting) (Node, error) {
 }
 
 func RewriteInvert(invert Invert, rewrite Rewriting) (Node, error) {
-	return rewrite(invert.Inner)
+	inner, err := rewrite(invert.Inner)
+	return Invert{inner}, err
 }
 
 func RewriteInvoke(invoke Invoke, rewrite Rewriting) (Node, error) {
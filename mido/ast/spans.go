@@ -0,0 +1,108 @@
+package ast
+
+import (
+	"errors"
+	"slices"
+	"sudonters/libzootr/ruleparser"
+)
+
+// where nodes came from in their source, keyed by Hash. Nodes are plain
+// values that optimizers freely rebuild so they cannot carry their own span,
+// instead Carry passes a node's spans on to whatever it is rewritten into.
+// Structurally identical nodes collect every span they appear at.
+type Spans map[uint64][]ruleparser.Span
+
+func (this Spans) record(node Node, span ruleparser.Span) {
+	if span.IsZero() {
+		return
+	}
+	key := Hash(node)
+	if !slices.Contains(this[key], span) {
+		this[key] = append(this[key], span)
+	}
+}
+
+// a node found at several places is located at the span covering all of them
+func (this Spans) Of(node Node) (ruleparser.Span, bool) {
+	if this == nil || node == nil {
+		return ruleparser.Span{}, false
+	}
+	spans := this[Hash(node)]
+	if len(spans) == 0 {
+		return ruleparser.Span{}, false
+	}
+	span := spans[0]
+	for _, other := range spans[1:] {
+		span = span.Join(other)
+	}
+	return span, true
+}
+
+// a copy of rw where every node it rewrites passes its spans on to the node
+// it was rewritten into, unless that node already has spans of its own
+func (this Spans) Carry(rw Rewriter) Rewriter {
+	rw.fill()
+	rw.AnyOf = carry(this, rw.AnyOf)
+	rw.Boolean = carry(this, rw.Boolean)
+	rw.Compare = carry(this, rw.Compare)
+	rw.Every = carry(this, rw.Every)
+	rw.Identifier = carry(this, rw.Identifier)
+	rw.Invert = carry(this, rw.Invert)
+	rw.Invoke = carry(this, rw.Invoke)
+	rw.Number = carry(this, rw.Number)
+	rw.String = carry(this, rw.String)
+	return rw
+}
+
+func carry[N Node](spans Spans, rewrite RewriteFunc[N]) RewriteFunc[N] {
+	return func(node N, rewriting Rewriting) (Node, error) {
+		rewritten, err := rewrite(node, rewriting)
+		if rewritten == nil {
+			return rewritten, err
+		}
+		from, to := Hash(node), Hash(rewritten)
+		if _, has := spans[to]; !has && from != to {
+			if carried, found := spans[from]; found {
+				spans[to] = carried
+			}
+		}
+		return rewritten, err
+	}
+}
+
+// finds the most specific span for err, either a span carried by the error
+// itself or the span of the node a NodeError refers to
+func (this Spans) Locate(err error) (ruleparser.Span, bool) {
+	var nodeErr NodeError
+	if errors.As(err, &nodeErr) {
+		if span, found := this.Of(nodeErr.Node); found {
+			return span, true
+		}
+	}
+	return ruleparser.SpanOf(err)
+}
+
+// an error caused by a specific node
+type NodeError struct {
+	Node  Node
+	Cause error
+}
+
+func ErrorAt(node Node, cause error) error {
+	if cause == nil {
+		return nil
+	}
+	var already NodeError
+	if errors.As(cause, &already) {
+		return cause
+	}
+	return NodeError{node, cause}
+}
+
+func (this NodeError) Error() string {
+	return this.Cause.Error()
+}
+
+func (this NodeError) Unwrap() error {
+	return this.Cause
+}
@@ -0,0 +1,61 @@
+package ast
+
+import (
+	"sudonters/libzootr/mido/symbols"
+	"testing"
+)
+
+func TestSpansCoverEveryOccurrence(t *testing.T) {
+	syms := symbols.NewTable()
+	source := "is_adult or (is_child and is_adult)"
+	node, spans, err := ParseWithSpans(source, &syms, grammar)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adult := node.(AnyOf)[0]
+	span, found := spans.Of(adult)
+	if !found {
+		t.Fatal("expected is_adult to be located")
+	}
+	if located := source[span.Start:span.End]; located != source[:len(source)-1] {
+		t.Fatalf("expected both is_adult to be covered, found %q", located)
+	}
+
+	child := node.(AnyOf)[1].(Every)[0]
+	if span, _ := spans.Of(child); source[span.Start:span.End] != "is_child" {
+		t.Fatalf("expected is_child to be located, found %v", span)
+	}
+}
+
+func TestCarryLocatesRewrittenNodes(t *testing.T) {
+	syms := symbols.NewTable()
+	source := "is_adult and is_child"
+	node, spans, err := ParseWithSpans(source, &syms, grammar)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	child := syms.LookUpByName("is_child")
+	replaceChild := spans.Carry(Rewriter{
+		Identifier: func(node Identifier, _ Rewriting) (Node, error) {
+			if symbols.Index(node) == child.Index {
+				return Invoke{Target: node, Args: []Node{Number(7)}}, nil
+			}
+			return node, nil
+		},
+	})
+	rewritten, err := replaceChild.Rewrite(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invoke := rewritten.(Every)[1]
+	span, found := spans.Of(invoke)
+	if !found || source[span.Start:span.End] != "is_child" {
+		t.Fatalf("expected rewritten node at is_child, found %v", span)
+	}
+	if _, found := spans.Of(rewritten); !found {
+		t.Fatal("expected rewritten rule to keep its span")
+	}
+}
@@ -20,6 +20,15 @@ func (this dis) CopyU16(tape []byte) {
 	fmt.Fprintf(this, "0x%04X", ReadU16(tape))
 }
 
+func (this dis) CopyU32(tape []byte) {
+	fmt.Fprintf(this, "0x%08X", ReadU32(tape))
+}
+
+// jump operands point at the offset column of their target
+func (this dis) CopyTarget(tape []byte) {
+	fmt.Fprintf(this, "-> 0x%02X", ReadU16(tape))
+}
+
 func (this dis) CopyU8(tape []byte) {
 	this.writeu8(tape[0])
 }
@@ -54,7 +63,13 @@ func DisassembleInto(w io.Writer, tape Instructions) {
 			case 1:
 				dis.CopyU8(tape[offset:])
 			case 2:
-				dis.CopyU16(tape[offset:])
+				if def.Op.IsJump() {
+					dis.CopyTarget(tape[offset:])
+				} else {
+					dis.CopyU16(tape[offset:])
+				}
+			case 4:
+				dis.CopyU32(tape[offset:])
 			}
 			offset += width
 		}
@@ -3,26 +3,38 @@ package code
 import (
 	"encoding/binary"
 	"fmt"
+	"math"
+	"strings"
 )
 
 var definitions = map[Op]Defintion{
-	NOP:        {"NOP", NOP, nil},
-	ERR:        {"ERR", ERR, nil},
-	PUSH_T:     {"PUSH_T", PUSH_T, nil},
-	PUSH_F:     {"PUSH_F", PUSH_F, nil},
-	PUSH_CONST: {"PUSH_CONST", PUSH_CONST, []int{2}},
-	PUSH_PTR:   {"PUSH_PTR", PUSH_PTR, []int{2}},
-	PUSH_STR:   {"PUSH_STR", PUSH_STR, []int{2}},
-	PUSH_FUNC:  {"PUSH_FUNC", PUSH_FUNC, []int{2}},
-	INVERT:     {"INVERT", INVERT, nil},
-	NEED_ALL:   {"NEED_ALL", NEED_ALL, []int{2}},
-	NEED_ANY:   {"NEED_ANY", NEED_ANY, []int{2}},
-	CHK_QTY:    {"CHK_QTY", CHK_QTY, []int{2, 1}},
-	INVOKE:     {"INVOKE", INVOKE, []int{2}},
-	INVOKE_0:   {"INVOKE_0", INVOKE_0, []int{2}},
-	CMP_EQ:     {"CMP_EQ", CMP_EQ, nil},
-	CMP_NQ:     {"CMP_NQ", CMP_NQ, nil},
-	CMP_LT:     {"CMP_LT", CMP_LT, nil},
+	NOP:          {"NOP", NOP, nil},
+	ERR:          {"ERR", ERR, nil},
+	PUSH_T:       {"PUSH_T", PUSH_T, nil},
+	PUSH_F:       {"PUSH_F", PUSH_F, nil},
+	PUSH_CONST:   {"PUSH_CONST", PUSH_CONST, []int{2}},
+	PUSH_PTR:     {"PUSH_PTR", PUSH_PTR, []int{2}},
+	PUSH_STR:     {"PUSH_STR", PUSH_STR, []int{2}},
+	PUSH_FUNC:    {"PUSH_FUNC", PUSH_FUNC, []int{2}},
+	PUSH_CONST_W: {"PUSH_CONST_W", PUSH_CONST_W, []int{4}},
+	PUSH_PTR_W:   {"PUSH_PTR_W", PUSH_PTR_W, []int{4}},
+	PUSH_STR_W:   {"PUSH_STR_W", PUSH_STR_W, []int{4}},
+	PUSH_FUNC_W:  {"PUSH_FUNC_W", PUSH_FUNC_W, []int{4}},
+	INVERT:       {"INVERT", INVERT, nil},
+	NEED_ALL:     {"NEED_ALL", NEED_ALL, []int{2}},
+	NEED_ANY:     {"NEED_ANY", NEED_ANY, []int{2}},
+	CHK_QTY:      {"CHK_QTY", CHK_QTY, []int{2, 1}},
+	CHK_QTY_W:    {"CHK_QTY_W", CHK_QTY_W, []int{4, 1}},
+	INVOKE:       {"INVOKE", INVOKE, []int{2}},
+	INVOKE_0:     {"INVOKE_0", INVOKE_0, []int{2}},
+	INVOKE_0_W:   {"INVOKE_0_W", INVOKE_0_W, []int{4}},
+	CMP_EQ:       {"CMP_EQ", CMP_EQ, nil},
+	CMP_NQ:       {"CMP_NQ", CMP_NQ, nil},
+	CMP_LT:       {"CMP_LT", CMP_LT, nil},
+	CALL_SUB:     {"CALL_SUB", CALL_SUB, []int{2}},
+	JMP:          {"JMP", JMP, []int{2}},
+	JMP_IF_FALSE: {"JMP_IF_FALSE", JMP_IF_FALSE, []int{2}},
+	JMP_IF_TRUE:  {"JMP_IF_TRUE", JMP_IF_TRUE, []int{2}},
 }
 
 func Make(op Op, operands ...int) Instructions {
@@ -53,6 +65,8 @@ func Make(op Op, operands ...int) Instructions {
 			tape[offset] = byte(operand)
 		case 2:
 			binary.LittleEndian.PutUint16(tape[offset:], uint16(operand))
+		case 4:
+			binary.LittleEndian.PutUint32(tape[offset:], uint32(operand))
 		default:
 			panic(fmt.Errorf("unsupport operand length: %d", width))
 		}
@@ -71,17 +85,89 @@ const (
 	PUSH_PTR   Op = 0x24
 	PUSH_STR   Op = 0x25
 	PUSH_FUNC  Op = 0x26
-	INVERT     Op = 0x31
-	NEED_ALL   Op = 0x32
-	NEED_ANY   Op = 0x33
-	CHK_QTY    Op = 0x41
-	INVOKE     Op = 0x51
-	INVOKE_0   Op = 0x52
-	CMP_EQ     Op = 0x61
-	CMP_NQ     Op = 0x62
-	CMP_LT     Op = 0x63
+	// _W ops take a u32 object index for tables past math.MaxUint16
+	PUSH_CONST_W Op = 0x27
+	PUSH_PTR_W   Op = 0x28
+	PUSH_STR_W   Op = 0x29
+	PUSH_FUNC_W  Op = 0x2A
+	INVERT       Op = 0x31
+	NEED_ALL     Op = 0x32
+	NEED_ANY     Op = 0x33
+	CHK_QTY      Op = 0x41
+	CHK_QTY_W    Op = 0x42
+	INVOKE       Op = 0x51
+	INVOKE_0     Op = 0x52
+	INVOKE_0_W   Op = 0x53
+	CMP_EQ       Op = 0x61
+	CMP_NQ       Op = 0x62
+	CMP_LT       Op = 0x63
+	CALL_SUB     Op = 0x71
+	// operand is an absolute tape offset, conditional jumps leave the top of
+	// the stack in place when they jump and pop it otherwise
+	JMP          Op = 0x81
+	JMP_IF_FALSE Op = 0x82
+	JMP_IF_TRUE  Op = 0x83
 )
 
+// true for ops whose operand is a tape offset
+func (this Op) IsJump() bool {
+	return this == JMP || this == JMP_IF_FALSE || this == JMP_IF_TRUE
+}
+
+var wide = map[Op]Op{
+	PUSH_CONST: PUSH_CONST_W,
+	PUSH_PTR:   PUSH_PTR_W,
+	PUSH_STR:   PUSH_STR_W,
+	PUSH_FUNC:  PUSH_FUNC_W,
+	CHK_QTY:    CHK_QTY_W,
+	INVOKE_0:   INVOKE_0_W,
+}
+
+// op's wide variant when index does not fit its u16 operand
+func ForIndex(op Op, index int) Op {
+	if wider, exists := wide[op]; exists && index > math.MaxUint16 {
+		return wider
+	}
+	return op
+}
+
+// true for ops whose first operand indexes the object table
+func (this Op) HasIndex() bool {
+	switch this.Narrow() {
+	case PUSH_CONST, PUSH_PTR, PUSH_STR, PUSH_FUNC, CHK_QTY, INVOKE_0:
+		return true
+	default:
+		return false
+	}
+}
+
+func (this Op) IsWide() bool {
+	switch this {
+	case PUSH_CONST_W, PUSH_PTR_W, PUSH_STR_W, PUSH_FUNC_W, CHK_QTY_W, INVOKE_0_W:
+		return true
+	default:
+		return false
+	}
+}
+
+// the u16 variant of a wide op, any other op is returned as is
+func (this Op) Narrow() Op {
+	for narrow, wider := range wide {
+		if wider == this {
+			return narrow
+		}
+	}
+	return this
+}
+
+// the object index operand of op, which must be the first
+func ReadIndex(op Op, operands []byte) int {
+	if op.IsWide() {
+		return int(ReadU32(operands))
+	}
+	return int(ReadU16(operands))
+}
+
 type Instructions []byte
 type Op uint8
 
@@ -94,6 +180,16 @@ func LookUp(op Op) (Defintion, error) {
 	return def, err
 }
 
+// the op named name, case insensitive
+func LookUpName(name string) (Defintion, bool) {
+	for _, def := range definitions {
+		if strings.EqualFold(def.Name, name) {
+			return def, true
+		}
+	}
+	return Defintion{}, false
+}
+
 type Defintion struct {
 	Name     string
 	Op       Op
@@ -104,6 +200,10 @@ func ReadU16(program []byte) uint16 {
 	return binary.LittleEndian.Uint16(program)
 }
 
+func ReadU32(program []byte) uint32 {
+	return binary.LittleEndian.Uint32(program)
+}
+
 func ReadU8(program []byte) uint8 {
 	return program[0]
 }
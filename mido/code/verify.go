@@ -0,0 +1,283 @@
+package code
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sudonters/libzootr/mido/objects"
+)
+
+// the VM's stack size, tapes that need more fail verification
+const MaxStack = 256
+
+var ErrVerify = errors.New("bytecode failed verification")
+
+type Verified struct {
+	// deepest the stack grows
+	MaxStack int
+	// one past the highest subroutine CALL_SUB names
+	Subroutines int
+}
+
+// checks a tape is safe to execute without trusting whoever produced it:
+// every op is known and has all of its operands, constants index objects
+// with the type the op expects, invoked pointers are PtrFunc with the
+// arity funcs declares, jumps only move forward and land on instructions,
+// and every path through the tape keeps the stack within MaxStack and
+// leaves a single answer. funcs is indexed by the address of each PtrFunc.
+func Verify(tape Instructions, objs []objects.Object, funcs []objects.BuiltInFunctionDef) (Verified, error) {
+	var verified Verified
+	starts, err := decode(tape)
+	if err != nil {
+		return verified, err
+	}
+
+	verifier := verifier{
+		tape: tape, objs: objs, funcs: funcs, starts: starts,
+		states: make(map[int][]int),
+	}
+	verifier.visit(0, []int{})
+	for len(verifier.pending) > 0 && verifier.err == nil {
+		ip := verifier.pending[len(verifier.pending)-1]
+		verifier.pending = verifier.pending[:len(verifier.pending)-1]
+		verifier.run(ip)
+	}
+
+	verified.MaxStack, verified.Subroutines = verifier.maxStack, verifier.subroutines
+	return verified, verifier.err
+}
+
+// offsets every instruction starts at
+func decode(tape Instructions) (map[int]bool, error) {
+	starts := make(map[int]bool)
+	for ip := 0; ip < len(tape); {
+		def, err := LookUp(Op(tape[ip]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: 0x%02X: %w", ErrVerify, ip, err)
+		}
+		starts[ip] = true
+		width := 1
+		for _, operand := range def.Operands {
+			width += operand
+		}
+		if ip+width > len(tape) {
+			return nil, fmt.Errorf("%w: 0x%02X: %s needs %d bytes, only %d remain", ErrVerify, ip, def.Name, width, len(tape)-ip)
+		}
+		ip += width
+	}
+	return starts, nil
+}
+
+// the stack is tracked per slot as the object index a push placed there or
+// -1 when only known at run time
+type verifier struct {
+	tape   Instructions
+	objs   []objects.Object
+	funcs  []objects.BuiltInFunctionDef
+	starts map[int]bool
+
+	states      map[int][]int
+	pending     []int
+	maxStack    int
+	subroutines int
+	err         error
+}
+
+func (this *verifier) fail(ip int, tpl string, v ...any) {
+	if this.err == nil {
+		this.err = fmt.Errorf("%w: 0x%02X: %s", ErrVerify, ip, fmt.Sprintf(tpl, v...))
+	}
+}
+
+// merges stack into the state already seen at ip, queuing ip again if that
+// state changed. Every path must reach an offset with the same depth.
+func (this *verifier) visit(ip int, stack []int) {
+	seen, visited := this.states[ip]
+	if !visited {
+		this.states[ip] = slices.Clone(stack)
+		this.pending = append(this.pending, ip)
+		return
+	}
+	if len(seen) != len(stack) {
+		this.fail(ip, "reached with stack depths %d and %d", len(seen), len(stack))
+		return
+	}
+	changed := false
+	for i := range seen {
+		if seen[i] != stack[i] && seen[i] != -1 {
+			seen[i] = -1
+			changed = true
+		}
+	}
+	if changed {
+		this.pending = append(this.pending, ip)
+	}
+}
+
+// applies the instruction at ip to the stack it is reached with and visits
+// every offset it may continue at
+func (this *verifier) run(ip int) {
+	stack := slices.Clone(this.states[ip])
+	if ip == len(this.tape) {
+		if len(stack) != 1 {
+			this.fail(ip, "tape ends with %d values on the stack, expected 1", len(stack))
+		}
+		return
+	}
+
+	op := Op(this.tape[ip])
+	def, _ := LookUp(op)
+	operands := this.tape[ip+1:]
+	next := ip + 1
+	for _, width := range def.Operands {
+		next += width
+	}
+
+	pop := func(n int) bool {
+		if len(stack) < n {
+			this.fail(ip, "%s needs %d values, stack has %d", def.Name, n, len(stack))
+			return false
+		}
+		stack = stack[:len(stack)-n]
+		return true
+	}
+	top := func() int {
+		if len(stack) == 0 {
+			return -1
+		}
+		return stack[len(stack)-1]
+	}
+	push := func(slot int) bool {
+		stack = append(stack, slot)
+		this.maxStack = max(this.maxStack, len(stack))
+		if len(stack) > MaxStack {
+			this.fail(ip, "stack exceeds %d values", MaxStack)
+			return false
+		}
+		return true
+	}
+
+	switch op {
+	case NOP:
+	case ERR:
+		return
+	case PUSH_T, PUSH_F:
+		push(-1)
+	case PUSH_CONST, PUSH_STR, PUSH_PTR, PUSH_FUNC, PUSH_CONST_W, PUSH_STR_W, PUSH_PTR_W, PUSH_FUNC_W:
+		index := ReadIndex(op, operands)
+		if this.object(ip, def, index) {
+			push(index)
+		}
+	case INVERT:
+		if pop(1) {
+			push(-1)
+		}
+	case NEED_ALL, NEED_ANY:
+		if pop(int(ReadU16(operands))) {
+			push(-1)
+		}
+	case CHK_QTY, CHK_QTY_W:
+		if this.object(ip, def, ReadIndex(op, operands)) {
+			push(-1)
+		}
+	case INVOKE:
+		count := int(ReadU16(operands))
+		callee := top()
+		if pop(1) && this.invokes(ip, callee, count) && pop(count) {
+			push(-1)
+		}
+	case INVOKE_0, INVOKE_0_W:
+		if this.invokes(ip, ReadIndex(op, operands), 0) {
+			push(-1)
+		}
+	case CMP_EQ, CMP_NQ, CMP_LT:
+		if pop(2) {
+			push(-1)
+		}
+	case CALL_SUB:
+		this.subroutines = max(this.subroutines, int(ReadU16(operands))+1)
+		push(-1)
+	case JMP, JMP_IF_FALSE, JMP_IF_TRUE:
+		target := int(ReadU16(operands))
+		if target <= ip {
+			this.fail(ip, "%s jumps backward to 0x%02X", def.Name, target)
+			return
+		}
+		if target != len(this.tape) && !this.starts[target] {
+			this.fail(ip, "%s targets 0x%02X which is not an instruction", def.Name, target)
+			return
+		}
+		if op == JMP {
+			this.visit(target, stack)
+			return
+		}
+		// jumping keeps the value falling through pops
+		kept := top()
+		if pop(1) {
+			this.visit(target, append(slices.Clone(stack), kept))
+		}
+	}
+
+	if this.err == nil {
+		this.visit(next, stack)
+	}
+}
+
+// index must name an object of the kind op pushes
+func (this *verifier) object(ip int, def Defintion, index int) bool {
+	if index >= len(this.objs) {
+		this.fail(ip, "constant 0x%04X is outside the object table of %d", index, len(this.objs))
+		return false
+	}
+	obj := this.objs[index]
+	op := def.Op.Narrow()
+	var expected string
+	switch op {
+	case PUSH_STR:
+		expected = objects.STR_STR32
+	case PUSH_PTR, PUSH_FUNC, CHK_QTY:
+		expected = objects.STR_PTR32
+	default:
+		return true
+	}
+	if ty := obj.Type(); ty != expected {
+		this.fail(ip, "%s expects a %s constant, 0x%04X is %s", def.Name, expected, index, ty)
+		return false
+	}
+	if op == CHK_QTY && objects.UnpackPtr32(obj).Tag != objects.PtrToken {
+		this.fail(ip, "%s expects a token, 0x%04X is not", def.Name, index)
+		return false
+	}
+	if op == PUSH_FUNC && objects.UnpackPtr32(obj).Tag != objects.PtrFunc {
+		this.fail(ip, "%s expects a function, 0x%04X is not", def.Name, index)
+		return false
+	}
+	return true
+}
+
+// callee must be a constant PtrFunc that accepts count arguments
+func (this *verifier) invokes(ip int, callee, count int) bool {
+	if callee == -1 {
+		this.fail(ip, "callee is not a constant")
+		return false
+	}
+	if callee >= len(this.objs) {
+		this.fail(ip, "callee 0x%04X is outside the object table of %d", callee, len(this.objs))
+		return false
+	}
+	obj := this.objs[callee]
+	if obj.Type() != objects.STR_PTR32 || objects.UnpackPtr32(obj).Tag != objects.PtrFunc {
+		this.fail(ip, "callee 0x%04X is not a function", callee)
+		return false
+	}
+	addr := int(objects.UnpackPtr32(obj).Addr)
+	if addr >= len(this.funcs) {
+		this.fail(ip, "callee 0x%04X addresses function 0x%04X of %d", callee, addr, len(this.funcs))
+		return false
+	}
+	if def := this.funcs[addr]; def.Params != -1 && def.Params != count {
+		this.fail(ip, "%s expects %d arguments, invoked with %d", def.Name, def.Params, count)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package code
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sudonters/libzootr/mido/objects"
+	"testing"
+)
+
+func TestVerifies(t *testing.T) {
+	objs := []objects.Object{
+		objects.PackPtr32(objects.Ptr32{Tag: objects.PtrFunc, Addr: 0}),
+		objects.PackPtr32(objects.Ptr32{Tag: objects.PtrFunc, Addr: 1}),
+		objects.PackPtr32(objects.Ptr32{Tag: objects.PtrToken, Addr: 7}),
+		objects.PackF64(1),
+		objects.PackPtr32(objects.Ptr32{Tag: objects.PtrFunc, Addr: 9}),
+	}
+	funcs := []objects.BuiltInFunctionDef{
+		{Name: "is_adult", Params: 0},
+		{Name: "has", Params: 2},
+	}
+	tape := func(instructions ...Instructions) Instructions {
+		return slices.Concat(instructions...)
+	}
+
+	verified, err := Verify(tape(
+		Make(INVOKE_0, 0),
+		Make(JMP_IF_TRUE, 22),
+		Make(PUSH_PTR, 2),
+		Make(PUSH_CONST, 3),
+		Make(PUSH_FUNC, 1),
+		Make(INVOKE, 2),
+		Make(CALL_SUB, 4),
+		Make(CMP_EQ),
+	), objs, funcs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified.MaxStack != 3 || verified.Subroutines != 5 {
+		t.Fatalf("expected a stack of 3 and 5 subroutines, found %+v", verified)
+	}
+
+	rejects := map[string]struct {
+		tape   Instructions
+		reason string
+	}{
+		"unknown op":     {Instructions{0xEE}, "unknown op"},
+		"short operand":  {Make(PUSH_CONST, 3)[:2], "only 2 remain"},
+		"const index":    {Make(PUSH_CONST, 5), "outside the object table"},
+		"string":         {Make(PUSH_STR, 3), "expects a Str32"},
+		"func tag":       {Make(PUSH_FUNC, 2), "expects a function"},
+		"qty tag":        {Make(CHK_QTY, 0, 1), "expects a token"},
+		"arity":          {tape(Make(PUSH_PTR, 2), Make(PUSH_FUNC, 1), Make(INVOKE, 1)), "has expects 2 arguments"},
+		"invoke_0 arity": {Make(INVOKE_0, 1), "has expects 2 arguments"},
+		"missing func":   {Make(INVOKE_0, 4), "addresses function"},
+		"unknown callee": {tape(Make(PUSH_T), Make(INVOKE, 0)), "not a constant"},
+		"underflow":      {tape(Make(PUSH_T), Make(CMP_EQ)), "needs 2 values"},
+		"leftovers":      {tape(Make(PUSH_T), Make(PUSH_F)), "2 values on the stack"},
+		"jump target":    {tape(Make(PUSH_T), Make(JMP, 2)), "not an instruction"},
+		"jump backward":  {tape(Make(PUSH_T), Make(JMP, 1)), "backward"},
+		"depths":         {tape(Make(PUSH_T), Make(JMP_IF_TRUE, 7), Make(PUSH_T), Make(PUSH_T), Make(PUSH_T)), "stack depths"},
+		"overflow":       {slices.Repeat(Make(PUSH_T), MaxStack+1), "exceeds"},
+	}
+	for name, reject := range rejects {
+		_, err := Verify(reject.tape, objs, funcs)
+		if !errors.Is(err, ErrVerify) || !strings.Contains(err.Error(), reject.reason) {
+			t.Errorf("%s: expected %q, found %v", name, reject.reason, err)
+		}
+	}
+}
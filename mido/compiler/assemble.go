@@ -0,0 +1,277 @@
+package compiler
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sudonters/libzootr/mido/code"
+	"sudonters/libzootr/mido/objects"
+	"sudonters/libzootr/mido/symbols"
+)
+
+// turns a listing back into bytecode. Each line is an instruction with its
+// fields separated by | the way code.DisassembleInto writes them
+//
+//	0x00 | 0x52 | INVOKE_0     | 0x0000
+//
+// the offset and opcode columns may be left off and are checked against
+// what is assembled when they are not. A line ending in : labels the next
+// instruction and jumps may target labels instead of offsets
+//
+//	INVOKE_0 | is_adult
+//	JMP_IF_FALSE | -> end
+//	CHK_QTY | Bow | 2
+//	end:
+//
+// Object index operands are a raw index, a symbol associated with a
+// pointer, a quoted string or a # prefixed number. Strings and numbers are
+// interned into objs and ops are widened when an index needs it. ; starts a
+// comment and everything after the CONSTANTS section VM.Dis writes is
+// ignored. The bytecode is not verified, see code.Verify.
+func Assemble(listing string, syms *symbols.Table, objs *objects.Builder) (Bytecode, error) {
+	asm := assembler{
+		syms:   syms,
+		objs:   objs,
+		labels: make(map[string]int),
+		consts: make(map[objects.Index]bool),
+		names:  make(map[objects.Index]string),
+	}
+	asm.bytecode.Names = make(map[objects.Index]string)
+
+	for n, line := range strings.Split(listing, "\n") {
+		fields, err := fields(line)
+		if err == nil && len(fields) == 1 && fields[0] == "CONSTANTS" {
+			break
+		}
+		if err == nil && len(fields) > 0 {
+			asm.lineNo = n + 1
+			err = asm.line(fields)
+		}
+		if err != nil {
+			return asm.bytecode, fmt.Errorf("line %d: %w", n+1, err)
+		}
+	}
+
+	for _, fixup := range asm.fixups {
+		target, defined := asm.labels[fixup.label]
+		if !defined {
+			return asm.bytecode, fmt.Errorf("line %d: no label named %q", fixup.line, fixup.label)
+		}
+		if target > math.MaxUint16 {
+			return asm.bytecode, fmt.Errorf("line %d: label %q at 0x%X is beyond the end of addressable tape", fixup.line, fixup.label, target)
+		}
+		binary.LittleEndian.PutUint16(asm.bytecode.Tape[fixup.at:], uint16(target))
+	}
+	return asm.bytecode, nil
+}
+
+type assembler struct {
+	syms     *symbols.Table
+	objs     *objects.Builder
+	bytecode Bytecode
+	labels   map[string]int
+	fixups   []fixup
+	consts   map[objects.Index]bool
+	// pointer names by index, filled the first time a raw index is named
+	names  map[objects.Index]string
+	lineNo int
+}
+
+// a jump operand waiting on its label
+type fixup struct {
+	at    int
+	label string
+	line  int
+}
+
+func (this *assembler) line(fields []string) error {
+	offset := len(this.bytecode.Tape)
+	if label, labels := strings.CutSuffix(fields[0], ":"); labels && len(fields) == 1 {
+		if !isLabel(label) {
+			return fmt.Errorf("%q is not a label", label)
+		}
+		if _, exists := this.labels[label]; exists {
+			return fmt.Errorf("label %q is already defined", label)
+		}
+		this.labels[label] = offset
+		return nil
+	}
+
+	listed, opcode := -1, -1
+	if len(fields) >= 3 {
+		at, atErr := parseInt(fields[0])
+		op, opErr := parseInt(fields[1])
+		if atErr == nil && opErr == nil {
+			listed, opcode, fields = at, op, fields[2:]
+		}
+	}
+	def, exists := code.LookUpName(fields[0])
+	if !exists {
+		return fmt.Errorf("unknown op %q", fields[0])
+	}
+	if opcode != -1 && opcode != int(def.Op) {
+		return fmt.Errorf("%s is 0x%02X, not 0x%02X", def.Name, def.Op, opcode)
+	}
+	if listed != -1 && listed != offset {
+		return fmt.Errorf("%s is listed at 0x%02X but assembles at 0x%02X", def.Name, listed, offset)
+	}
+	if operands := fields[1:]; len(operands) != len(def.Operands) {
+		return fmt.Errorf("%s expects %d operands, found %d", def.Name, len(def.Operands), len(operands))
+	}
+
+	op := def.Op
+	operands := make([]int, len(def.Operands))
+	for i, field := range fields[1:] {
+		var err error
+		switch {
+		case op.IsJump():
+			operands[i], err = this.target(field, offset+1)
+		case i == 0 && op.HasIndex():
+			var index objects.Index
+			index, err = this.index(field)
+			operands[i] = int(index)
+		default:
+			operands[i], err = parseInt(field)
+		}
+		if err != nil {
+			return fmt.Errorf("%s operand %d: %w", def.Name, i+1, err)
+		}
+		if i == 0 && op.HasIndex() {
+			op = code.ForIndex(op, operands[i])
+			continue
+		}
+		if limit := 1<<(8*def.Operands[i]) - 1; operands[i] < 0 || operands[i] > limit {
+			return fmt.Errorf("%s operand %d: %d does not fit %d bytes", def.Name, i+1, operands[i], def.Operands[i])
+		}
+	}
+
+	if op.HasIndex() {
+		index := objects.Index(operands[0])
+		if !this.consts[index] {
+			this.consts[index] = true
+			this.bytecode.Consts = append(this.bytecode.Consts, index)
+		}
+		if name, named := this.nameOf(index); named {
+			this.bytecode.Names[index] = name
+		}
+	}
+	this.bytecode.Tape = append(this.bytecode.Tape, code.Make(op, operands...)...)
+	return nil
+}
+
+// an offset or a label, the listing writes targets as -> 0x13
+func (this *assembler) target(field string, at int) (int, error) {
+	field = strings.TrimSpace(strings.TrimPrefix(field, "->"))
+	if target, err := parseInt(field); err == nil {
+		return target, nil
+	}
+	if !isLabel(field) {
+		return 0, fmt.Errorf("%q is not an offset or label", field)
+	}
+	if target, defined := this.labels[field]; defined {
+		return target, nil
+	}
+	this.fixups = append(this.fixups, fixup{at, field, this.lineNo})
+	return 0, nil
+}
+
+func (this *assembler) index(field string) (objects.Index, error) {
+	switch {
+	case strings.HasPrefix(field, `"`):
+		str, err := strconv.Unquote(field)
+		if err != nil {
+			return 0, fmt.Errorf("%s is not a string: %w", field, err)
+		}
+		return this.objs.InternStr(str), nil
+	case strings.HasPrefix(field, "#"):
+		number, err := strconv.ParseFloat(field[1:], 64)
+		if err != nil {
+			return 0, fmt.Errorf("%s is not a number: %w", field, err)
+		}
+		return this.objs.InternNumber(number), nil
+	}
+
+	if index, err := parseInt(field); err == nil {
+		if index < 0 || index > math.MaxUint32 {
+			return 0, fmt.Errorf("%d is not an object index", index)
+		}
+		return objects.Index(index), nil
+	}
+	symbol := this.syms.LookUpByName(field)
+	if symbol == nil {
+		return 0, fmt.Errorf("no symbol named %q", field)
+	}
+	index, exists := this.objs.LookUpPtr(symbol)
+	if !exists {
+		return 0, fmt.Errorf("%q is not associated with a pointer", field)
+	}
+	this.names[index] = symbol.Name
+	return index, nil
+}
+
+// raw indices of pointers are named like the compiler names them
+func (this *assembler) nameOf(index objects.Index) (string, bool) {
+	if name, named := this.names[index]; named {
+		return name, true
+	}
+	if values := this.objs.Values(); int(index) >= len(values) || values[index].Type() != objects.STR_PTR32 {
+		return "", false
+	}
+	for symbol, ptr := range this.objs.Pointers {
+		if ptr == index {
+			this.names[index] = this.syms.LookUpByIndex(symbol).Name
+			return this.names[index], true
+		}
+	}
+	return "", false
+}
+
+// | separated and trimmed, quoted strings may hold | and ; which otherwise
+// starts a comment
+func fields(line string) ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	var quoted, escaped bool
+scan:
+	for _, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case !quoted && r == ';':
+			break scan
+		case !quoted && r == '|':
+			fields = append(fields, strings.TrimSpace(field.String()))
+			field.Reset()
+			continue
+		}
+		field.WriteRune(r)
+	}
+	if quoted {
+		return nil, errors.New("unterminated string")
+	}
+	if last := strings.TrimSpace(field.String()); last != "" || len(fields) > 0 {
+		fields = append(fields, last)
+	}
+	return fields, nil
+}
+
+func parseInt(field string) (int, error) {
+	n, err := strconv.ParseInt(field, 0, 64)
+	return int(n), err
+}
+
+func isLabel(field string) bool {
+	for i, r := range field {
+		if r != '_' && !('a' <= r && r <= 'z') && !('A' <= r && r <= 'Z') && (i == 0 || !('0' <= r && r <= '9')) {
+			return false
+		}
+	}
+	return field != ""
+}
@@ -0,0 +1,150 @@
+package compiler
+
+import (
+	"maps"
+	"slices"
+	"sudonters/libzootr/mido/ast"
+	"sudonters/libzootr/mido/code"
+	"sudonters/libzootr/mido/objects"
+	"sudonters/libzootr/mido/symbols"
+	"sudonters/libzootr/ruleparser"
+	"testing"
+)
+
+func assemblerFixture() (symbols.Table, objects.Builder) {
+	syms := symbols.NewTable()
+	objs := objects.NewTableBuilder()
+	for i, name := range []string{"is_adult", "is_child", "has"} {
+		symbol := syms.Declare(name, symbols.BUILT_IN_FUNCTION)
+		objs.DefineFunction(symbol, objects.PackPtr32(objects.Ptr32{Tag: objects.PtrFunc, Addr: objects.Addr32(i)}),
+			objects.BuiltInFunctionDef{Name: name, Params: i / 2 * 2})
+	}
+	objs.AssociateSymbol(syms.Declare("Bow", symbols.TOKEN), objects.PackPtr32(objects.Ptr32{Tag: objects.PtrToken}))
+	return syms, objs
+}
+
+func TestAssemblesDisassembly(t *testing.T) {
+	syms, objs := assemblerFixture()
+	node, err := ast.Parse("is_adult() and (is_child() or has(Bow, 2)) and 'dins' != 'farores'", &syms, ruleparser.NewRulesGrammar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	compiled, err := Compile(node, &syms, &objs, objs.FunctionTable())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assembled, err := Assemble(code.DisassembleToString(compiled.Tape), &syms, &objs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dis, expected := disassemble(assembled), disassemble(compiled); dis != expected {
+		t.Fatalf("expected\n%s\nfound\n%s", expected, dis)
+	}
+	if consts, expected := slices.Sorted(slices.Values(assembled.Consts)), slices.Sorted(slices.Values(compiled.Consts)); !slices.Equal(consts, expected) {
+		t.Fatalf("expected consts %v, found %v", expected, consts)
+	}
+	if !maps.Equal(assembled.Names, compiled.Names) {
+		t.Fatalf("expected names %v, found %v", compiled.Names, assembled.Names)
+	}
+}
+
+func TestAssemblesListing(t *testing.T) {
+	syms, objs := assemblerFixture()
+	listing := `
+; is_adult() and (has(Bow, 2) or 'a|b;c' == "a|b;c") and 1.5 < 2
+	INVOKE_0     | is_adult
+	JMP_IF_FALSE | -> end
+	CHK_QTY      | Bow | 2
+	JMP_IF_TRUE  | -> strs
+	PUSH_STR     | "a|b;c" ; interned once
+	PUSH_STR     | "a|b;c"
+	CMP_EQ
+strs:
+	JMP_IF_FALSE | -> end
+0x17 | 0x23 | PUSH_CONST | #2
+	PUSH_CONST   | #1.5
+	CMP_LT
+end:
+
+CONSTANTS
+0x0000:	0x00000000
+`
+	bytecode, err := Assemble(listing, &syms, &objs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `0x00 | 0x52 | INVOKE_0     | 0x0000
+0x03 | 0x82 | JMP_IF_FALSE | -> 0x1E
+0x06 | 0x41 | CHK_QTY      | 0x0003 | 0x02
+0x0A | 0x83 | JMP_IF_TRUE  | -> 0x14
+0x0D | 0x25 | PUSH_STR     | 0x0004
+0x10 | 0x25 | PUSH_STR     | 0x0004
+0x13 | 0x61 | CMP_EQ
+0x14 | 0x82 | JMP_IF_FALSE | -> 0x1E
+0x17 | 0x23 | PUSH_CONST   | 0x0005
+0x1A | 0x23 | PUSH_CONST   | 0x0006
+0x1D | 0x63 | CMP_LT
+`
+	if dis := disassemble(bytecode); dis != expected {
+		t.Fatalf("expected\n%s\nfound\n%s", expected, dis)
+	}
+	if consts := len(bytecode.Consts); consts != 5 {
+		t.Fatalf("expected 5 consts, found %d: %v", consts, bytecode.Consts)
+	}
+	if !maps.Equal(bytecode.Names, map[objects.Index]string{0: "is_adult", 3: "Bow"}) {
+		t.Fatalf("expected is_adult and Bow to be named, found %v", bytecode.Names)
+	}
+	if _, err := code.Verify(bytecode.Tape, objs.Values(), objs.FunctionTable()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAssemblerWidensIndices(t *testing.T) {
+	syms, objs := assemblerFixture()
+	bytecode, err := Assemble("PUSH_CONST | 0x10000\nCHK_QTY | 0x10001 | 1\nINVOKE_0 | 0x0000", &syms, &objs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `0x00 | 0x27 | PUSH_CONST_W | 0x00010000
+0x05 | 0x42 | CHK_QTY_W    | 0x00010001 | 0x01
+0x0B | 0x52 | INVOKE_0     | 0x0000
+`
+	if dis := disassemble(bytecode); dis != expected {
+		t.Fatalf("expected\n%s\nfound\n%s", expected, dis)
+	}
+	if name := bytecode.Names[0]; name != "is_adult" {
+		t.Fatalf("expected raw index 0 to be named is_adult, found %q", name)
+	}
+}
+
+func TestAssemblerRejects(t *testing.T) {
+	syms, objs := assemblerFixture()
+	rejects := map[string]struct{ listing, err string }{
+		"unknown op":        {"PUSH_MAYBE", `line 1: unknown op "PUSH_MAYBE"`},
+		"operand count":     {"CHK_QTY | Bow", "line 1: CHK_QTY expects 2 operands, found 1"},
+		"offset mismatch":   {"PUSH_T\n0x00 | 0x21 | PUSH_T", "line 2: PUSH_T is listed at 0x00 but assembles at 0x01"},
+		"opcode mismatch":   {"0x00 | 0x22 | PUSH_T", "line 1: PUSH_T is 0x21, not 0x22"},
+		"unknown symbol":    {"PUSH_PTR | Hookshot", `line 1: PUSH_PTR operand 1: no symbol named "Hookshot"`},
+		"undefined label":   {"PUSH_T\n\nJMP_IF_TRUE | -> end", `line 3: no label named "end"`},
+		"duplicate label":   {"end:\nPUSH_T\nend:", `line 3: label "end" is already defined`},
+		"unterminated":      {`PUSH_STR | "dins`, "line 1: unterminated string"},
+		"operand range":     {"NEED_ALL | 0x10000", "line 1: NEED_ALL operand 1: 65536 does not fit 2 bytes"},
+		"not a jump target": {"JMP | -> 0end", `line 1: JMP operand 1: "0end" is not an offset or label`},
+		"not an index":      {"PUSH_CONST | -1", "line 1: PUSH_CONST operand 1: -1 is not an object index"},
+		"not a label":       {"2nd:", `line 1: "2nd" is not a label`},
+		"extra operand":     {"PUSH_PTR | is_adult | 1", "line 1: PUSH_PTR expects 1 operands, found 2"},
+	}
+
+	for name, reject := range rejects {
+		t.Run(name, func(t *testing.T) {
+			_, err := Assemble(reject.listing, &syms, &objs)
+			if err == nil {
+				t.Fatalf("expected %q", reject.err)
+			}
+			if err.Error() != reject.err {
+				t.Fatalf("expected %q, found %q", reject.err, err)
+			}
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package compiler
 
 import (
+	"encoding/binary"
 	"fmt"
 	"maps"
+	"math"
 	"slices"
 	"sudonters/libzootr/mido/ast"
 	"sudonters/libzootr/mido/code"
@@ -22,40 +24,115 @@ func (this *Bytecode) concat(tape code.Instructions) int {
 	return written
 }
 
-func Compile(nodes ast.Node, symbols *symbols.Table, objs *objects.Builder) (Bytecode, error) {
+// funcs is the builtin table bytecode is verified against, see
+// objects.Builder.FunctionTable
+func Compile(nodes ast.Node, symbols *symbols.Table, objs *objects.Builder, funcs []objects.BuiltInFunctionDef) (Bytecode, error) {
+	return newCompiler(symbols, objs, funcs, nil).compile(nodes)
+}
+
+// lowers and/or into NEED_ALL/NEED_ANY which evaluate every operand before
+// reducing them instead of jumping past the rest once the result is known
+func CompileReducing(nodes ast.Node, symbols *symbols.Table, objs *objects.Builder, funcs []objects.BuiltInFunctionDef) (Bytecode, error) {
+	compiler := newCompiler(symbols, objs, funcs, nil)
+	compiler.reduce = true
+	return compiler.compile(nodes)
+}
+
+// compiles node calling into subs for every subtree it counted more than
+// once, see Subroutines.Count
+func CompileShared(nodes ast.Node, symbols *symbols.Table, objs *objects.Builder, funcs []objects.BuiltInFunctionDef, subs *Subroutines) (Bytecode, error) {
+	compiler := newCompiler(symbols, objs, funcs, subs)
+	bytecode, err := compiler.compile(nodes)
+	if err == nil {
+		subs.sharing.Bytes += len(bytecode.Tape)
+		subs.sharing.InlinedBytes += compiler.expanded
+	}
+	return bytecode, err
+}
+
+func newCompiler(symbols *symbols.Table, objs *objects.Builder, funcs []objects.BuiltInFunctionDef, subs *Subroutines) *compiler {
 	var compiler compiler
-	var bytecode Bytecode
 	compiler.symbols = symbols
 	compiler.objects = objs
-	compiler.code = &bytecode
+	compiler.funcs = funcs
+	compiler.code = &Bytecode{}
 	compiler.consts = map[objects.Index]struct{}{}
 	compiler.names = map[objects.Index]string{}
+	compiler.subs = subs
+	return &compiler
+}
 
-	visiting := &compiler
+func (this *compiler) compile(nodes ast.Node) (Bytecode, error) {
 	visitor := ast.Visitor{
-		AnyOf:      visiting.AnyOf,
-		Boolean:    visiting.Boolean,
-		Compare:    visiting.Compare,
-		Every:      visiting.Every,
-		Identifier: visiting.Identifier,
-		Invert:     visiting.Invert,
-		Invoke:     visiting.Invoke,
-		Number:     visiting.Number,
-		String:     visiting.String,
+		AnyOf:      shared(this, this.AnyOf),
+		Boolean:    this.Boolean,
+		Compare:    shared(this, this.Compare),
+		Every:      shared(this, this.Every),
+		Identifier: this.Identifier,
+		Invert:     shared(this, this.Invert),
+		Invoke:     shared(this, this.Invoke),
+		Number:     this.Number,
+		String:     this.String,
 	}
 	err := visitor.Visit(nodes)
-	bytecode.Consts = slices.Collect(maps.Keys(compiler.consts))
-	bytecode.Names = compiler.names
-	return bytecode, err
+	bytecode := this.code
+	bytecode.Consts = slices.Collect(maps.Keys(this.consts))
+	bytecode.Names = this.names
+	if err != nil {
+		return *bytecode, err
+	}
+	emitted := len(bytecode.Tape)
+	optimized, err := Peephole(*bytecode, this.objects.Values())
+	if err != nil {
+		return optimized, err
+	}
+	this.expanded -= emitted - len(optimized.Tape)
+	verified, err := code.Verify(optimized.Tape, this.objects.Values(), this.funcs)
+	// bodies are compiled before the first CALL_SUB to them is emitted
+	if bodies := this.subroutines(); err == nil && verified.Subroutines > bodies {
+		err = fmt.Errorf("%w: calls subroutine 0x%04X of %d", code.ErrVerify, verified.Subroutines-1, bodies)
+	}
+	return optimized, err
+}
+
+func (this *compiler) subroutines() int {
+	if this.subs == nil {
+		return 0
+	}
+	return len(this.subs.Bodies)
+}
+
+func shared[N ast.Node](this *compiler, compile ast.VisitFunc[N]) ast.VisitFunc[N] {
+	if this.subs == nil {
+		return compile
+	}
+	return func(node N, visit ast.Visiting) error {
+		// a subroutine's own body
+		if this.unshared {
+			this.unshared = false
+			return compile(node, visit)
+		}
+		if called, err := this.subs.call(this, node); called || err != nil {
+			return err
+		}
+		return compile(node, visit)
+	}
 }
 
 type compiler struct {
 	tapePtr int
 	symbols *symbols.Table
 	objects *objects.Builder
+	funcs   []objects.BuiltInFunctionDef
 	code    *Bytecode
 	consts  map[objects.Index]struct{}
 	names   map[objects.Index]string
+
+	subs     *Subroutines
+	unshared bool
+	reduce   bool
+	// tape length with every CALL_SUB inlined
+	expanded int
 }
 
 func (this *compiler) emit(op code.Op, operands ...int) int {
@@ -65,10 +142,14 @@ func (this *compiler) emit(op code.Op, operands ...int) int {
 func (this *compiler) join(emitted code.Instructions) int {
 	startOfInstruction := this.tapePtr
 	this.tapePtr += this.code.concat(emitted)
+	this.expanded += len(emitted)
 	return startOfInstruction
 }
 
 func (this *compiler) AnyOf(node ast.AnyOf, visit ast.Visiting) error {
+	if !this.reduce {
+		return this.shortCircuit(node, code.JMP_IF_TRUE, code.PUSH_F, visit)
+	}
 	err := visit.All(node)
 	if err != nil {
 		return err
@@ -77,6 +158,33 @@ func (this *compiler) AnyOf(node ast.AnyOf, visit ast.Visiting) error {
 	return nil
 }
 
+// every operand but the last is followed by a jump to the end that is taken
+// once its value decides the result, an empty chain is its identity
+func (this *compiler) shortCircuit(nodes []ast.Node, jump, identity code.Op, visit ast.Visiting) error {
+	if len(nodes) == 0 {
+		this.emit(identity)
+		return nil
+	}
+
+	jumps := make([]int, 0, len(nodes)-1)
+	for i := range nodes {
+		if err := visit(nodes[i]); err != nil {
+			return err
+		}
+		if i < len(nodes)-1 {
+			jumps = append(jumps, this.emit(jump, 0))
+		}
+	}
+
+	if this.tapePtr > math.MaxUint16 {
+		return fmt.Errorf("jump target 0x%X is beyond the end of addressable tape", this.tapePtr)
+	}
+	for _, at := range jumps {
+		binary.LittleEndian.PutUint16(this.code.Tape[at+1:], uint16(this.tapePtr))
+	}
+	return nil
+}
+
 func (this *compiler) Boolean(node ast.Boolean, visit ast.Visiting) error {
 	if node {
 		this.emit(code.PUSH_T)
@@ -98,13 +206,16 @@ func (this *compiler) Compare(node ast.Compare, visit ast.Visiting) error {
 	case ast.CompareLt:
 		this.emit(code.CMP_LT)
 	default:
-		return fmt.Errorf("uncompilable comparison op: %v", node.Op)
+		return ast.ErrorAt(node, fmt.Errorf("uncompilable comparison op: %v", node.Op))
 	}
 
 	return nil
 }
 
 func (this *compiler) Every(node ast.Every, visit ast.Visiting) error {
+	if !this.reduce {
+		return this.shortCircuit(node, code.JMP_IF_FALSE, code.PUSH_T, visit)
+	}
 	err := visit.All(node)
 	if err != nil {
 		return err
@@ -122,7 +233,7 @@ func (this *compiler) Identifier(node ast.Identifier, visit ast.Visiting) error
 	case symbols.TOKEN, symbols.SETTING:
 		this.pushPtr(code.PUSH_PTR, ptr, symbol.Name)
 	default:
-		return fmt.Errorf("uncompilable identifier: %s", symbol)
+		return ast.ErrorAt(node, fmt.Errorf("uncompilable identifier: %s", symbol))
 	}
 	return nil
 }
@@ -138,12 +249,12 @@ func (this *compiler) Invert(node ast.Invert, visit ast.Visiting) error {
 func (this *compiler) Invoke(node ast.Invoke, visit ast.Visiting) error {
 	callee := ast.LookUpNodeInTable(this.symbols, node.Target)
 	if callee == nil {
-		return fmt.Errorf("can only invoke functions, not %s", node.Target.Kind())
+		return ast.ErrorAt(node, fmt.Errorf("can only invoke functions, not %s", node.Target.Kind()))
 	}
 
 	def := this.objects.FunctionDefinition(callee)
 	if argCount := len(node.Args); def.Params > -1 && def.Params != argCount {
-		return fmt.Errorf("%q expects %d arguments but received %d", def.Name, def.Params, argCount)
+		return ast.ErrorAt(node, fmt.Errorf("%q expects %d arguments but received %d", def.Name, def.Params, argCount))
 	}
 
 	if this.trySpecializeInvoke(node, callee, def) {
@@ -165,21 +276,21 @@ func (this *compiler) Invoke(node ast.Invoke, visit ast.Visiting) error {
 func (this *compiler) Number(node ast.Number, visit ast.Visiting) error {
 	idx := this.objects.InternNumber(float64(node))
 	this.consts[idx] = struct{}{}
-	this.emit(code.PUSH_CONST, int(idx))
+	this.emit(code.ForIndex(code.PUSH_CONST, int(idx)), int(idx))
 	return nil
 }
 
 func (this *compiler) String(node ast.String, visit ast.Visiting) error {
 	idx := this.objects.InternStr(string(node))
 	this.consts[idx] = struct{}{}
-	this.emit(code.PUSH_STR, int(idx))
+	this.emit(code.ForIndex(code.PUSH_STR, int(idx)), int(idx))
 	return nil
 }
 
 func (this *compiler) pushPtr(op code.Op, idx objects.Index, name string) {
 	this.consts[idx] = struct{}{}
 	this.names[idx] = name
-	this.emit(op, int(idx))
+	this.emit(code.ForIndex(op, int(idx)), int(idx))
 }
 
 func (this *compiler) trySpecializeInvoke(node ast.Invoke, callee *symbols.Sym, def objects.BuiltInFunctionDef) bool {
@@ -191,7 +302,7 @@ func (this *compiler) trySpecializeInvoke(node ast.Invoke, callee *symbols.Sym,
 			ptr := this.objects.PtrFor(what)
 			this.consts[ptr] = struct{}{}
 			this.names[ptr] = what.Name
-			this.emit(code.CHK_QTY, int(ptr), int(uint8(qty.(ast.Number))))
+			this.emit(code.ForIndex(code.CHK_QTY, int(ptr)), int(ptr), int(uint8(qty.(ast.Number))))
 			return true
 		}
 	}
@@ -200,7 +311,7 @@ func (this *compiler) trySpecializeInvoke(node ast.Invoke, callee *symbols.Sym,
 		ptr := this.objects.PtrFor(callee)
 		this.consts[ptr] = struct{}{}
 		this.names[ptr] = callee.Name
-		this.emit(code.INVOKE_0, int(ptr))
+		this.emit(code.ForIndex(code.INVOKE_0, int(ptr)), int(ptr))
 		return true
 	}
 
@@ -0,0 +1,141 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sudonters/libzootr/mido/ast"
+	"sudonters/libzootr/mido/code"
+	"sudonters/libzootr/mido/objects"
+	"sudonters/libzootr/mido/symbols"
+	"sudonters/libzootr/ruleparser"
+	"testing"
+)
+
+func TestLowersAndOrIntoJumps(t *testing.T) {
+	grammar := ruleparser.NewRulesGrammar()
+	syms := symbols.NewTable()
+	objs := objects.NewTableBuilder()
+	for i, name := range []string{"is_adult", "is_child", "has_bottle"} {
+		symbol := syms.Declare(name, symbols.BUILT_IN_FUNCTION)
+		objs.DefineFunction(symbol, objects.PackPtr32(objects.Ptr32{Tag: objects.PtrFunc, Addr: objects.Addr32(i)}),
+			objects.BuiltInFunctionDef{Name: name})
+	}
+
+	node, err := ast.Parse("is_adult() and (is_child() or has_bottle()) and True", &syms, grammar)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bytecode, err := Compile(node, &syms, &objs, objs.FunctionTable())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `0x00 | 0x52 | INVOKE_0     | 0x0000
+0x03 | 0x82 | JMP_IF_FALSE | -> 0x13
+0x06 | 0x52 | INVOKE_0     | 0x0001
+0x09 | 0x83 | JMP_IF_TRUE  | -> 0x0F
+0x0C | 0x52 | INVOKE_0     | 0x0002
+0x0F | 0x82 | JMP_IF_FALSE | -> 0x13
+0x12 | 0x21 | PUSH_T
+`
+	if dis := disassemble(bytecode); dis != expected {
+		t.Fatalf("expected\n%s\nfound\n%s", expected, dis)
+	}
+
+	reduced, err := CompileReducing(node, &syms, &objs, objs.FunctionTable())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `0x00 | 0x52 | INVOKE_0     | 0x0000
+0x03 | 0x52 | INVOKE_0     | 0x0001
+0x06 | 0x52 | INVOKE_0     | 0x0002
+0x09 | 0x33 | NEED_ANY     | 0x0002
+0x0C | 0x32 | NEED_ALL     | 0x0002
+`
+	if dis := disassemble(reduced); dis != expected {
+		t.Fatalf("expected\n%s\nfound\n%s", expected, dis)
+	}
+}
+
+// without the padding ops without operands are written with
+func disassemble(bytecode Bytecode) string {
+	lines := strings.SplitAfter(code.DisassembleToString(bytecode.Tape), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " \n")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestWidensIndicesPastU16(t *testing.T) {
+	grammar := ruleparser.NewRulesGrammar()
+	syms := symbols.NewTable()
+	objs := objects.NewTableBuilder()
+	for n := range math.MaxUint16 + 1 {
+		objs.InternNumber(float64(n) + 0.5)
+	}
+	for i, name := range []string{"is_adult", "has"} {
+		symbol := syms.Declare(name, symbols.BUILT_IN_FUNCTION)
+		objs.DefineFunction(symbol, objects.PackPtr32(objects.Ptr32{Tag: objects.PtrFunc, Addr: objects.Addr32(i)}),
+			objects.BuiltInFunctionDef{Name: name, Params: i * 2})
+	}
+	objs.AssociateSymbol(syms.Declare("Bow", symbols.TOKEN), objects.PackPtr32(objects.Ptr32{Tag: objects.PtrToken}))
+
+	long := strings.Repeat("long", 100)
+	node, err := ast.Parse(fmt.Sprintf("is_adult() and has(Bow, 2) and '%s' == 'short'", long), &syms, grammar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bytecode, err := Compile(node, &syms, &objs, objs.FunctionTable())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `0x00 | 0x53 | INVOKE_0_W   | 0x00010000
+0x05 | 0x82 | JMP_IF_FALSE | -> 0x1C
+0x08 | 0x42 | CHK_QTY_W    | 0x00010002 | 0x02
+0x0E | 0x82 | JMP_IF_FALSE | -> 0x1C
+0x11 | 0x29 | PUSH_STR_W   | 0x00010003
+0x16 | 0x29 | PUSH_STR_W   | 0x00010004
+0x1B | 0x61 | CMP_EQ
+`
+	if dis := disassemble(bytecode); dis != expected {
+		t.Fatalf("expected\n%s\nfound\n%s", expected, dis)
+	}
+
+	tbl := objects.TableFrom(&objs)
+	if str := tbl.DerefString(tbl.AtIndex(0x10004)); str != long {
+		t.Fatalf("expected long string to survive interning, found %q", str)
+	}
+	if str := tbl.DerefString(tbl.AtIndex(0x10003)); str != "short" {
+		t.Fatalf("expected short string to survive interning, found %q", str)
+	}
+}
+
+func TestRejectsCallsPastCompiledSubroutines(t *testing.T) {
+	grammar := ruleparser.NewRulesGrammar()
+	syms := symbols.NewTable()
+	objs := objects.NewTableBuilder()
+	for i, name := range []string{"is_adult", "is_child"} {
+		symbol := syms.Declare(name, symbols.BUILT_IN_FUNCTION)
+		objs.DefineFunction(symbol, objects.PackPtr32(objects.Ptr32{Tag: objects.PtrFunc, Addr: objects.Addr32(i)}),
+			objects.BuiltInFunctionDef{Name: name})
+	}
+
+	node, err := ast.Parse("(is_adult() or is_child()) and (is_adult() or is_child())", &syms, grammar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subs := NewSubroutines()
+	subs.Count(node)
+	funcs := objs.FunctionTable()
+	if _, err := CompileShared(node, &syms, &objs, funcs, &subs); err != nil {
+		t.Fatal(err)
+	}
+
+	// the call is still emitted but its body is gone
+	subs.Bodies = nil
+	if _, err := CompileShared(node, &syms, &objs, funcs, &subs); !errors.Is(err, code.ErrVerify) {
+		t.Fatalf("expected %v, found %v", code.ErrVerify, err)
+	}
+}
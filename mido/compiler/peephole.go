@@ -0,0 +1,309 @@
+package compiler
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sudonters/libzootr/mido/code"
+	"sudonters/libzootr/mido/objects"
+)
+
+// rewrites sequences codegen emits into shorter ones until none remain:
+//
+//	PUSH_T operands of NEED_ALL are dropped
+//	INVERT INVERT is dropped
+//	NEED_ALL 1 and NEED_ANY 1 are dropped when their operand is a bool
+//	PUSH_PTR tok PUSH_CONST n PUSH_FUNC has INVOKE 2 becomes CHK_QTY tok n,
+//	the wide variants of each of these are matched too
+//
+// Jumps are retargeted and Consts and Names are trimmed to what the
+// rewritten tape still references. Only instructions no jump lands on are
+// removed from the middle of a sequence. objs is indexed by the tape's
+// constant operands.
+func Peephole(bytecode Bytecode, objs []objects.Object) (Bytecode, error) {
+	for {
+		tape, err := decode(bytecode.Tape)
+		if err != nil {
+			return bytecode, err
+		}
+		rewriter := peephole{tape, bytecode.Names, objs}
+		windows := rewriter.windows()
+		operands := rewriter.needAllOperands()
+		if !windows && !operands {
+			break
+		}
+		bytecode.Tape = tape.encode()
+	}
+
+	referenced := referencedConsts(bytecode.Tape)
+	if len(bytecode.Consts) > 0 {
+		bytecode.Consts = slices.DeleteFunc(slices.Clone(bytecode.Consts), func(index objects.Index) bool {
+			return !referenced[index]
+		})
+	}
+	if bytecode.Names != nil {
+		bytecode.Names = maps.Clone(bytecode.Names)
+		maps.DeleteFunc(bytecode.Names, func(index objects.Index, _ string) bool {
+			return !referenced[index]
+		})
+	}
+	return bytecode, nil
+}
+
+type instruction struct {
+	// offset in the tape it was decoded from
+	at       int
+	op       code.Op
+	operands []int
+	// a jump lands on it
+	target  bool
+	dropped bool
+}
+
+type instructions []instruction
+
+func decode(tape code.Instructions) (instructions, error) {
+	var decoded instructions
+	targets := make(map[int]bool)
+	for ip := 0; ip < len(tape); {
+		def, err := code.LookUp(code.Op(tape[ip]))
+		if err != nil {
+			return nil, fmt.Errorf("0x%02X: %w", ip, err)
+		}
+		decoding := instruction{at: ip, op: def.Op}
+		ip++
+		for _, width := range def.Operands {
+			if ip+width > len(tape) {
+				return nil, fmt.Errorf("0x%02X: %s is missing operands", decoding.at, def.Name)
+			}
+			switch width {
+			case 1:
+				decoding.operands = append(decoding.operands, int(code.ReadU8(tape[ip:])))
+			case 2:
+				decoding.operands = append(decoding.operands, int(code.ReadU16(tape[ip:])))
+			case 4:
+				decoding.operands = append(decoding.operands, int(code.ReadU32(tape[ip:])))
+			}
+			ip += width
+		}
+		if def.Op.IsJump() {
+			targets[decoding.operands[0]] = true
+		}
+		decoded = append(decoded, decoding)
+	}
+	for i := range decoded {
+		decoded[i].target = targets[decoded[i].at]
+	}
+	return decoded, nil
+}
+
+// jumps to a dropped instruction land on the next one kept
+func (this instructions) encode() code.Instructions {
+	moved := make(map[int]int, len(this))
+	var end int
+	for _, kept := range this {
+		if !kept.dropped {
+			moved[kept.at] = end
+			end += len(code.Make(kept.op, kept.operands...))
+		}
+	}
+	retarget := func(target int) int {
+		for _, kept := range this {
+			if !kept.dropped && kept.at >= target {
+				return moved[kept.at]
+			}
+		}
+		return end
+	}
+
+	tape := make(code.Instructions, 0, end)
+	for _, kept := range this {
+		if kept.dropped {
+			continue
+		}
+		operands := kept.operands
+		if kept.op.IsJump() {
+			operands = []int{retarget(operands[0])}
+		}
+		tape = append(tape, code.Make(kept.op, operands...)...)
+	}
+	return tape
+}
+
+type peephole struct {
+	tape  instructions
+	names map[objects.Index]string
+	objs  []objects.Object
+}
+
+// fixed sequences of adjacent instructions
+func (this peephole) windows() bool {
+	var rewritten bool
+	tape := this.tape
+	for i := 0; i < len(tape); i++ {
+		switch {
+		case tape[i].op == code.INVERT && i+1 < len(tape) && tape[i+1].op == code.INVERT && !tape[i+1].target:
+			tape[i].dropped, tape[i+1].dropped = true, true
+			i++
+		case (tape[i].op == code.NEED_ALL || tape[i].op == code.NEED_ANY) && tape[i].operands[0] == 1 && this.pushesBool(i):
+			tape[i].dropped = true
+		case this.chkqty(i):
+			token := tape[i].operands[0]
+			tape[i].op, tape[i].operands = code.ForIndex(code.CHK_QTY, token), []int{token, int(objects.UnpackF64(this.objs[tape[i+1].operands[0]]))}
+			tape[i+1].dropped, tape[i+2].dropped, tape[i+3].dropped = true, true, true
+			i += 3
+		default:
+			continue
+		}
+		rewritten = true
+	}
+	return rewritten
+}
+
+// NEED_* 1 also coerces its operand to bool so it may only be dropped after
+// an instruction that leaves one, and only if no jump lands on it with
+// whatever another path left
+func (this peephole) pushesBool(i int) bool {
+	if this.tape[i].target {
+		return false
+	}
+	for j := i - 1; j >= 0; j-- {
+		if this.tape[j].dropped {
+			// jumps here are retargeted onto the NEED_*
+			if this.tape[j].target {
+				return false
+			}
+			continue
+		}
+		switch this.tape[j].op.Narrow() {
+		case code.PUSH_T, code.PUSH_F, code.CMP_EQ, code.CMP_NQ, code.CMP_LT, code.INVERT, code.CHK_QTY, code.NEED_ALL, code.NEED_ANY:
+			return true
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// has(tok, n) that wasn't specialized because codegen didn't see constants
+func (this peephole) chkqty(i int) bool {
+	if i+3 >= len(this.tape) {
+		return false
+	}
+	window := this.tape[i : i+4]
+	for j, op := range []code.Op{code.PUSH_PTR, code.PUSH_CONST, code.PUSH_FUNC, code.INVOKE} {
+		if window[j].op.Narrow() != op || (j > 0 && window[j].target) {
+			return false
+		}
+	}
+	if window[3].operands[0] != 2 || this.names[objects.Index(window[2].operands[0])] != "has" {
+		return false
+	}
+	token, qty := window[0].operands[0], window[1].operands[0]
+	if token >= len(this.objs) || qty >= len(this.objs) {
+		return false
+	}
+	if ptr := this.objs[token]; ptr.Type() != objects.STR_PTR32 || objects.UnpackPtr32(ptr).Tag != objects.PtrToken {
+		return false
+	}
+	if n := this.objs[qty]; n.Type() != objects.STR_F64 || objects.UnpackF64(n) != float64(uint8(objects.UnpackF64(n))) {
+		return false
+	}
+	return true
+}
+
+// tracks which stack slots PUSH_T filled so NEED_ALL can drop them wherever
+// they are among its operands. Slots are forgotten at jumps and their
+// targets, where the stack may have been filled along another path.
+func (this peephole) needAllOperands() bool {
+	var rewritten bool
+	var slots []int
+	pop := func(n int) ([]int, bool) {
+		if len(slots) < n {
+			return nil, false
+		}
+		popped := slots[len(slots)-n:]
+		slots = slots[:len(slots)-n]
+		return popped, true
+	}
+	forget := func() {
+		for i := range slots {
+			slots[i] = -1
+		}
+	}
+
+	for i := range this.tape {
+		instr := &this.tape[i]
+		if instr.target {
+			forget()
+		}
+		if instr.dropped {
+			continue
+		}
+
+		popping, pushes := 0, 1
+		switch instr.op {
+		case code.NOP:
+			continue
+		case code.PUSH_T:
+			slots = append(slots, i)
+			continue
+		case code.NEED_ALL:
+			operands, ok := pop(instr.operands[0])
+			if !ok {
+				return rewritten
+			}
+			kept := len(operands)
+			for _, pushed := range operands {
+				if pushed >= 0 {
+					this.tape[pushed].dropped = true
+					kept--
+				}
+			}
+			if kept == len(operands) {
+				slots = append(slots, -1)
+				continue
+			}
+			rewritten = true
+			if kept == 0 {
+				instr.op, instr.operands = code.PUSH_T, nil
+				slots = append(slots, i)
+			} else {
+				instr.operands[0] = kept
+				slots = append(slots, -1)
+			}
+			continue
+		case code.NEED_ANY:
+			popping = instr.operands[0]
+		case code.INVOKE:
+			popping = instr.operands[0] + 1
+		case code.INVERT:
+			popping = 1
+		case code.CMP_EQ, code.CMP_NQ, code.CMP_LT:
+			popping = 2
+		case code.JMP_IF_FALSE, code.JMP_IF_TRUE:
+			forget()
+			popping, pushes = 1, 0
+		case code.JMP, code.ERR:
+			return rewritten
+		}
+		if _, ok := pop(popping); !ok {
+			return rewritten
+		}
+		for range pushes {
+			slots = append(slots, -1)
+		}
+	}
+	return rewritten
+}
+
+func referencedConsts(tape code.Instructions) map[objects.Index]bool {
+	referenced := make(map[objects.Index]bool)
+	decoded, _ := decode(tape)
+	for _, instr := range decoded {
+		if instr.op.HasIndex() {
+			referenced[objects.Index(instr.operands[0])] = true
+		}
+	}
+	return referenced
+}
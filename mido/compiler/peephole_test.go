@@ -0,0 +1,178 @@
+package compiler
+
+import (
+	"maps"
+	"slices"
+	"sudonters/libzootr/mido/code"
+	"sudonters/libzootr/mido/objects"
+	"testing"
+)
+
+func TestPeephole(t *testing.T) {
+	objs := []objects.Object{
+		objects.PackPtr32(objects.Ptr32{Tag: objects.PtrFunc, Addr: 0}),
+		objects.PackPtr32(objects.Ptr32{Tag: objects.PtrToken, Addr: 7}),
+		objects.PackF64(2),
+		objects.PackF64(1.5),
+		objects.PackPtr32(objects.Ptr32{Tag: objects.PtrFunc, Addr: 1}),
+	}
+	names := map[objects.Index]string{0: "has", 1: "Bow", 4: "is_adult"}
+	tape := func(instructions ...code.Instructions) code.Instructions {
+		return slices.Concat(instructions...)
+	}
+	has := func(qty int) code.Instructions {
+		return tape(code.Make(code.PUSH_PTR, 1), code.Make(code.PUSH_CONST, qty), code.Make(code.PUSH_FUNC, 0), code.Make(code.INVOKE, 2))
+	}
+
+	rewrites := map[string]struct {
+		tape          code.Instructions
+		before, after string
+	}{
+		"need all true operands": {
+			tape(code.Make(code.PUSH_T), code.Make(code.INVOKE_0, 4), code.Make(code.PUSH_T), code.Make(code.NEED_ALL, 3)),
+			`0x00 | 0x21 | PUSH_T
+0x01 | 0x52 | INVOKE_0     | 0x0004
+0x04 | 0x21 | PUSH_T
+0x05 | 0x32 | NEED_ALL     | 0x0003
+`,
+			`0x00 | 0x52 | INVOKE_0     | 0x0004
+0x03 | 0x32 | NEED_ALL     | 0x0001
+`,
+		},
+		"need all only true": {
+			tape(code.Make(code.INVOKE_0, 4), code.Make(code.PUSH_T), code.Make(code.PUSH_T), code.Make(code.NEED_ALL, 2), code.Make(code.NEED_ALL, 2)),
+			`0x00 | 0x52 | INVOKE_0     | 0x0004
+0x03 | 0x21 | PUSH_T
+0x04 | 0x21 | PUSH_T
+0x05 | 0x32 | NEED_ALL     | 0x0002
+0x08 | 0x32 | NEED_ALL     | 0x0002
+`,
+			`0x00 | 0x52 | INVOKE_0     | 0x0004
+0x03 | 0x32 | NEED_ALL     | 0x0001
+`,
+		},
+		"double invert": {
+			tape(code.Make(code.INVOKE_0, 4), code.Make(code.INVERT), code.Make(code.INVERT), code.Make(code.INVERT)),
+			`0x00 | 0x52 | INVOKE_0     | 0x0004
+0x03 | 0x31 | INVERT
+0x04 | 0x31 | INVERT
+0x05 | 0x31 | INVERT
+`,
+			`0x00 | 0x52 | INVOKE_0     | 0x0004
+0x03 | 0x31 | INVERT
+`,
+		},
+		"need any one": {
+			tape(code.Make(code.CHK_QTY, 1, 2), code.Make(code.NEED_ANY, 1)),
+			`0x00 | 0x41 | CHK_QTY      | 0x0001 | 0x02
+0x04 | 0x33 | NEED_ANY     | 0x0001
+`,
+			`0x00 | 0x41 | CHK_QTY      | 0x0001 | 0x02
+`,
+		},
+		"need all one number": {
+			tape(code.Make(code.PUSH_CONST, 2), code.Make(code.NEED_ALL, 1)),
+			`0x00 | 0x23 | PUSH_CONST   | 0x0002
+0x03 | 0x32 | NEED_ALL     | 0x0001
+`,
+			`0x00 | 0x23 | PUSH_CONST   | 0x0002
+0x03 | 0x32 | NEED_ALL     | 0x0001
+`,
+		},
+		"has constant quantity": {
+			has(2),
+			`0x00 | 0x24 | PUSH_PTR     | 0x0001
+0x03 | 0x23 | PUSH_CONST   | 0x0002
+0x06 | 0x26 | PUSH_FUNC    | 0x0000
+0x09 | 0x51 | INVOKE       | 0x0002
+`,
+			`0x00 | 0x41 | CHK_QTY      | 0x0001 | 0x02
+`,
+		},
+		"has fractional quantity": {
+			has(3),
+			`0x00 | 0x24 | PUSH_PTR     | 0x0001
+0x03 | 0x23 | PUSH_CONST   | 0x0003
+0x06 | 0x26 | PUSH_FUNC    | 0x0000
+0x09 | 0x51 | INVOKE       | 0x0002
+`,
+			`0x00 | 0x24 | PUSH_PTR     | 0x0001
+0x03 | 0x23 | PUSH_CONST   | 0x0003
+0x06 | 0x26 | PUSH_FUNC    | 0x0000
+0x09 | 0x51 | INVOKE       | 0x0002
+`,
+		},
+		"retargets jumps": {
+			tape(code.Make(code.INVOKE_0, 4), code.Make(code.JMP_IF_FALSE, 20), has(2), code.Make(code.INVERT), code.Make(code.INVERT)),
+			`0x00 | 0x52 | INVOKE_0     | 0x0004
+0x03 | 0x82 | JMP_IF_FALSE | -> 0x14
+0x06 | 0x24 | PUSH_PTR     | 0x0001
+0x09 | 0x23 | PUSH_CONST   | 0x0002
+0x0C | 0x26 | PUSH_FUNC    | 0x0000
+0x0F | 0x51 | INVOKE       | 0x0002
+0x12 | 0x31 | INVERT
+0x13 | 0x31 | INVERT
+`,
+			`0x00 | 0x52 | INVOKE_0     | 0x0004
+0x03 | 0x82 | JMP_IF_FALSE | -> 0x0A
+0x06 | 0x41 | CHK_QTY      | 0x0001 | 0x02
+`,
+		},
+		"keeps jump targets": {
+			tape(code.Make(code.INVOKE_0, 4), code.Make(code.JMP_IF_TRUE, 10), code.Make(code.INVOKE_0, 4), code.Make(code.INVERT), code.Make(code.INVERT), code.Make(code.PUSH_T), code.Make(code.NEED_ALL, 2)),
+			`0x00 | 0x52 | INVOKE_0     | 0x0004
+0x03 | 0x83 | JMP_IF_TRUE  | -> 0x0A
+0x06 | 0x52 | INVOKE_0     | 0x0004
+0x09 | 0x31 | INVERT
+0x0A | 0x31 | INVERT
+0x0B | 0x21 | PUSH_T
+0x0C | 0x32 | NEED_ALL     | 0x0002
+`,
+			`0x00 | 0x52 | INVOKE_0     | 0x0004
+0x03 | 0x83 | JMP_IF_TRUE  | -> 0x0A
+0x06 | 0x52 | INVOKE_0     | 0x0004
+0x09 | 0x31 | INVERT
+0x0A | 0x31 | INVERT
+`,
+		},
+	}
+
+	for name, rewrite := range rewrites {
+		bytecode := Bytecode{Tape: rewrite.tape, Consts: []objects.Index{0, 1, 2, 3, 4}, Names: names}
+		if dis := disassemble(bytecode); dis != rewrite.before {
+			t.Errorf("%s: expected before\n%s\nfound\n%s", name, rewrite.before, dis)
+			continue
+		}
+		optimized, err := Peephole(bytecode, objs)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if dis := disassemble(optimized); dis != rewrite.after {
+			t.Errorf("%s: expected after\n%s\nfound\n%s", name, rewrite.after, dis)
+		}
+	}
+}
+
+func TestPeepholeTrimsConsts(t *testing.T) {
+	objs := []objects.Object{
+		objects.PackPtr32(objects.Ptr32{Tag: objects.PtrFunc, Addr: 0}),
+		objects.PackPtr32(objects.Ptr32{Tag: objects.PtrToken, Addr: 7}),
+		objects.PackF64(2),
+	}
+	bytecode := Bytecode{
+		Tape:   slices.Concat(code.Make(code.PUSH_PTR, 1), code.Make(code.PUSH_CONST, 2), code.Make(code.PUSH_FUNC, 0), code.Make(code.INVOKE, 2)),
+		Consts: []objects.Index{0, 1, 2},
+		Names:  map[objects.Index]string{0: "has", 1: "Bow"},
+	}
+	optimized, err := Peephole(bytecode, objs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(optimized.Consts, []objects.Index{1}) || !maps.Equal(optimized.Names, map[objects.Index]string{1: "Bow"}) {
+		t.Errorf("expected only Bow to remain, found %v %v", optimized.Consts, optimized.Names)
+	}
+	if len(bytecode.Names) != 2 {
+		t.Errorf("expected original names to be left alone, found %v", bytecode.Names)
+	}
+}
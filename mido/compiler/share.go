@@ -0,0 +1,121 @@
+package compiler
+
+import (
+	"sudonters/libzootr/mido/ast"
+	"sudonters/libzootr/mido/code"
+)
+
+// subtrees that repeat across rules, each is compiled once and called with
+// CALL_SUB so the VM can memoize its result
+type Subroutines struct {
+	Bodies []Bytecode
+
+	uses     map[uint64]int
+	index    map[uint64]int
+	expanded []int
+	sharing  Sharing
+}
+
+type Sharing struct {
+	Subroutines int
+	Calls       int
+	// tape length of every rule and subroutine
+	Bytes int
+	// tape length if every call were inlined
+	InlinedBytes int
+}
+
+// how many times larger the tapes would be without sharing
+func (this Sharing) Ratio() float64 {
+	if this.Bytes == 0 {
+		return 1
+	}
+	return float64(this.InlinedBytes) / float64(this.Bytes)
+}
+
+func NewSubroutines() Subroutines {
+	return Subroutines{
+		uses:  make(map[uint64]int),
+		index: make(map[uint64]int),
+	}
+}
+
+// records every shareable subtree of node. The children of a subtree already
+// seen are not counted again, they are only shared if they also appear
+// outside of it.
+func (this *Subroutines) Count(node ast.Node) {
+	if !shareable(node) {
+		return
+	}
+	hash := ast.Hash(node)
+	this.uses[hash]++
+	if this.uses[hash] > 1 {
+		return
+	}
+
+	switch node := node.(type) {
+	case ast.AnyOf:
+		this.countAll(node)
+	case ast.Every:
+		this.countAll(node)
+	case ast.Invert:
+		this.Count(node.Inner)
+	case ast.Invoke:
+		this.countAll(node.Args)
+	case ast.Compare:
+		this.Count(node.LHS)
+		this.Count(node.RHS)
+	}
+}
+
+func (this *Subroutines) countAll(nodes []ast.Node) {
+	for i := range nodes {
+		this.Count(nodes[i])
+	}
+}
+
+func (this *Subroutines) Sharing() Sharing {
+	return this.sharing
+}
+
+// emits CALL_SUB for node if it was counted more than once, compiling its
+// body on first use
+func (this *Subroutines) call(outer *compiler, node ast.Node) (bool, error) {
+	if !shareable(node) {
+		return false, nil
+	}
+	hash := ast.Hash(node)
+	if this.uses[hash] < 2 {
+		return false, nil
+	}
+
+	index, compiled := this.index[hash]
+	if !compiled {
+		body := newCompiler(outer.symbols, outer.objects, outer.funcs, this)
+		body.unshared = true
+		bytecode, err := body.compile(node)
+		if err != nil {
+			return true, err
+		}
+		index = len(this.Bodies)
+		this.Bodies = append(this.Bodies, bytecode)
+		this.expanded = append(this.expanded, body.expanded)
+		this.index[hash] = index
+		this.sharing.Subroutines++
+		this.sharing.Bytes += len(bytecode.Tape)
+	}
+
+	called := outer.emit(code.CALL_SUB, index)
+	outer.expanded += this.expanded[index] - (outer.tapePtr - called)
+	this.sharing.Calls++
+	return true, nil
+}
+
+func shareable(node ast.Node) bool {
+	switch node.(type) {
+	case ast.AnyOf, ast.Every, ast.Invert, ast.Invoke, ast.Compare:
+		return true
+	default:
+		return false
+	}
+}
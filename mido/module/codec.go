@@ -0,0 +1,118 @@
+package module
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"sudonters/libzootr/mido/code"
+	"sudonters/libzootr/mido/compiler"
+	"sudonters/libzootr/mido/objects"
+)
+
+type writer struct {
+	bytes.Buffer
+}
+
+func (this *writer) u16(n uint16) {
+	this.Write(binary.LittleEndian.AppendUint16(nil, n))
+}
+
+func (this *writer) u32(n int) {
+	this.Write(binary.LittleEndian.AppendUint32(nil, uint32(n)))
+}
+
+func (this *writer) u64(n uint64) {
+	this.Write(binary.LittleEndian.AppendUint64(nil, n))
+}
+
+func (this *writer) str(s string) {
+	this.u16(uint16(len(s)))
+	this.WriteString(s)
+}
+
+func (this *writer) bytecode(bytecode compiler.Bytecode) {
+	this.u32(len(bytecode.Tape))
+	this.Write(bytecode.Tape)
+	this.u32(len(bytecode.Consts))
+	for _, index := range bytecode.Consts {
+		this.u32(int(index))
+	}
+}
+
+// the first failure sticks, every read after it returns zeros
+type reader struct {
+	raw    []byte
+	offset int
+	err    error
+}
+
+func (this *reader) fail(tpl string, v ...any) {
+	if this.err == nil {
+		this.err = fmt.Errorf("%w: %s", ErrCorrupt, fmt.Sprintf(tpl, v...))
+	}
+}
+
+func (this *reader) bytes(n int) []byte {
+	if this.err != nil {
+		return nil
+	}
+	if n > len(this.raw)-this.offset {
+		this.fail("wanted %d bytes at 0x%X, only %d remain", n, this.offset, len(this.raw)-this.offset)
+		return nil
+	}
+	read := this.raw[this.offset : this.offset+n]
+	this.offset += n
+	return read
+}
+
+func (this *reader) u16() uint16 {
+	if read := this.bytes(2); read != nil {
+		return binary.LittleEndian.Uint16(read)
+	}
+	return 0
+}
+
+func (this *reader) u32() uint32 {
+	if read := this.bytes(4); read != nil {
+		return binary.LittleEndian.Uint32(read)
+	}
+	return 0
+}
+
+func (this *reader) u64() uint64 {
+	if read := this.bytes(8); read != nil {
+		return binary.LittleEndian.Uint64(read)
+	}
+	return 0
+}
+
+// a u32 count of items at least size bytes long, refusing counts the
+// remaining bytes cannot hold so corrupt files do not allocate wildly
+func (this *reader) count(size int) int {
+	n := int(this.u32())
+	if remaining := len(this.raw) - this.offset; n > remaining/size {
+		this.fail("%d items of %d bytes at 0x%X, only %d bytes remain", n, size, this.offset, remaining)
+		return 0
+	}
+	return n
+}
+
+func (this *reader) str() string {
+	return string(this.bytes(int(this.u16())))
+}
+
+func (this *reader) bytecode(names map[objects.Index]string) compiler.Bytecode {
+	var bytecode compiler.Bytecode
+	bytecode.Tape = code.Instructions(slices.Clone(this.bytes(int(this.u32()))))
+	for n := this.count(4); n > 0; n-- {
+		bytecode.Consts = append(bytecode.Consts, objects.Index(this.u32()))
+	}
+	bytecode.Names = make(map[objects.Index]string)
+	for _, index := range bytecode.Consts {
+		if name, named := names[index]; named {
+			bytecode.Names[index] = name
+		}
+	}
+	return bytecode
+}
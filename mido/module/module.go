@@ -0,0 +1,245 @@
+// compiled logic written to and read back from a single binary file.
+//
+// Every integer is little endian, a module is laid out as
+//
+//	magic       [4]byte  "MIDO"
+//	version     u16      Version
+//	settings    u64      settings.Fingerprint the rules were compiled with
+//	data        u64      hash of the logic and data files compiled
+//	objects     u32 n    n × u64 objects.Object
+//	strings     u32 n    n bytes of string heap Str32 objects address, see
+//	                     objects.Table.Span for long strings
+//	names       u32 n    n × (u32 object index, u16 n, n bytes)
+//	subroutines u32 n    n × bytecode
+//	rules       u32 n    n × (u16 n, n bytes of rule name, bytecode)
+//
+// and bytecode is
+//
+//	tape        u32 n    n bytes
+//	consts      u32 n    n × u32 object index
+//
+// Bytecode.Names is not written per bytecode, every name lands in the names
+// section and is restored for each bytecode's consts when read.
+package module
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"sudonters/libzootr/internal/settings"
+	"sudonters/libzootr/mido/code"
+	"sudonters/libzootr/mido/compiler"
+	"sudonters/libzootr/mido/objects"
+)
+
+const Version uint16 = 2
+
+var magic = [4]byte{'M', 'I', 'D', 'O'}
+
+var (
+	ErrNotModule = errors.New("not a compiled module")
+	ErrVersion   = errors.New("unsupported module version")
+	ErrCorrupt   = errors.New("corrupt module")
+	ErrStale     = errors.New("stale module")
+)
+
+type Module struct {
+	Settings    settings.Fingerprint
+	Data        uint64
+	Objects     objects.Table
+	Subroutines []compiler.Bytecode
+	Rules       map[string]compiler.Bytecode
+}
+
+// ErrStale if the module was compiled from different settings or data
+func (this Module) Check(fingerprint settings.Fingerprint, data uint64) error {
+	if this.Settings != fingerprint {
+		return fmt.Errorf("%w: compiled for settings %016x, not %016x", ErrStale, uint64(this.Settings), uint64(fingerprint))
+	}
+	if this.Data != data {
+		return fmt.Errorf("%w: compiled from data %016x, not %016x", ErrStale, this.Data, data)
+	}
+	return nil
+}
+
+// runs code.Verify over every bytecode, funcs is indexed by the address of
+// each PtrFunc
+func (this Module) Verify(funcs []objects.BuiltInFunctionDef) error {
+	verify := func(what string, bytecode compiler.Bytecode) error {
+		verified, err := code.Verify(bytecode.Tape, this.Objects.Values(), funcs)
+		if err == nil && verified.Subroutines > len(this.Subroutines) {
+			err = fmt.Errorf("%w: calls subroutine 0x%04X of %d", code.ErrVerify, verified.Subroutines-1, len(this.Subroutines))
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrCorrupt, what, err)
+		}
+		return nil
+	}
+
+	for i, bytecode := range this.Subroutines {
+		if err := verify(fmt.Sprintf("subroutine 0x%04X", i), bytecode); err != nil {
+			return err
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(this.Rules)) {
+		if err := verify(fmt.Sprintf("%q", name), this.Rules[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// every object index any bytecode names
+func (this Module) Names() map[objects.Index]string {
+	names := make(map[objects.Index]string)
+	for _, bytecode := range this.bytecodes() {
+		maps.Copy(names, bytecode.Names)
+	}
+	return names
+}
+
+func (this Module) bytecodes() []compiler.Bytecode {
+	all := slices.Clone(this.Subroutines)
+	for _, name := range slices.Sorted(maps.Keys(this.Rules)) {
+		all = append(all, this.Rules[name])
+	}
+	return all
+}
+
+func Write(w io.Writer, module Module) error {
+	if err := module.validate(); err != nil {
+		return err
+	}
+
+	var out writer
+	out.Write(magic[:])
+	out.u16(Version)
+	out.u64(uint64(module.Settings))
+	out.u64(module.Data)
+
+	values := module.Objects.Values()
+	out.u32(len(values))
+	for _, value := range values {
+		out.u64(uint64(value))
+	}
+	out.u32(len(module.Objects.Strings()))
+	out.Write(module.Objects.Strings())
+
+	names := module.Names()
+	out.u32(len(names))
+	for _, index := range slices.Sorted(maps.Keys(names)) {
+		out.u32(int(index))
+		out.str(names[index])
+	}
+
+	out.u32(len(module.Subroutines))
+	for _, bytecode := range module.Subroutines {
+		out.bytecode(bytecode)
+	}
+	out.u32(len(module.Rules))
+	for _, name := range slices.Sorted(maps.Keys(module.Rules)) {
+		out.str(name)
+		out.bytecode(module.Rules[name])
+	}
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+func Read(r io.Reader) (Module, error) {
+	var module Module
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return module, err
+	}
+	in := reader{raw: raw}
+
+	if !bytes.Equal(in.bytes(len(magic)), magic[:]) {
+		return module, ErrNotModule
+	}
+	if version := in.u16(); version != Version {
+		return module, fmt.Errorf("%w: %d, expected %d", ErrVersion, version, Version)
+	}
+	module.Settings = settings.Fingerprint(in.u64())
+	module.Data = in.u64()
+
+	values := make([]objects.Object, in.count(8))
+	for i := range values {
+		values[i] = objects.Object(in.u64())
+	}
+	strings := slices.Clone(in.bytes(in.count(1)))
+	module.Objects = objects.TableOf(values, strings)
+
+	count := in.count(6)
+	names := make(map[objects.Index]string, count)
+	for range count {
+		index := objects.Index(in.u32())
+		names[index] = in.str()
+	}
+
+	module.Subroutines = make([]compiler.Bytecode, in.count(8))
+	for i := range module.Subroutines {
+		module.Subroutines[i] = in.bytecode(names)
+	}
+	count = in.count(10)
+	module.Rules = make(map[string]compiler.Bytecode, count)
+	for range count {
+		name := in.str()
+		module.Rules[name] = in.bytecode(names)
+	}
+
+	if in.err == nil && in.offset != len(raw) {
+		in.fail("%d trailing bytes", len(raw)-in.offset)
+	}
+	if in.err != nil {
+		return module, in.err
+	}
+	return module, module.validate()
+}
+
+// every const must index the object table, every string must lie within the
+// string heap, every name must name a const and every length must fit its
+// field
+func (this Module) validate() error {
+	values, heap := this.Objects.Values(), this.Objects.Strings()
+	for i, value := range values {
+		if value.Type() != objects.STR_STR32 {
+			continue
+		}
+		if _, end := this.Objects.Span(objects.UnpackStr32(value)); end > len(heap) {
+			return fmt.Errorf("%w: string object 0x%04X is outside the string heap", ErrCorrupt, i)
+		}
+	}
+
+	for name := range this.Rules {
+		if len(name) > math.MaxUint16 {
+			return fmt.Errorf("%w: rule name is %d bytes long", ErrCorrupt, len(name))
+		}
+	}
+	for _, name := range this.Names() {
+		if len(name) > math.MaxUint16 {
+			return fmt.Errorf("%w: name is %d bytes long", ErrCorrupt, len(name))
+		}
+	}
+
+	for _, bytecode := range this.bytecodes() {
+		if len(bytecode.Tape) > math.MaxUint32 || len(bytecode.Consts) > math.MaxUint32 {
+			return fmt.Errorf("%w: bytecode with %d bytes and %d consts does not fit", ErrCorrupt, len(bytecode.Tape), len(bytecode.Consts))
+		}
+		for _, index := range bytecode.Consts {
+			if int(index) >= len(values) {
+				return fmt.Errorf("%w: const 0x%04X is outside the object table", ErrCorrupt, index)
+			}
+		}
+		for index, name := range bytecode.Names {
+			if !slices.Contains(bytecode.Consts, index) {
+				return fmt.Errorf("%w: %q names 0x%04X which is not a const", ErrCorrupt, name, index)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package module
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strings"
+	"sudonters/libzootr/internal/settings"
+	"sudonters/libzootr/mido/ast"
+	"sudonters/libzootr/mido/code"
+	"sudonters/libzootr/mido/compiler"
+	"sudonters/libzootr/mido/objects"
+	"sudonters/libzootr/mido/symbols"
+	"sudonters/libzootr/ruleparser"
+	"testing"
+)
+
+func compiled(t *testing.T) Module {
+	t.Helper()
+	grammar := ruleparser.NewRulesGrammar()
+	syms := symbols.NewTable()
+	objs := objects.NewTableBuilder()
+	objs.DefineFunction(syms.Declare("has", symbols.BUILT_IN_FUNCTION),
+		objects.PackPtr32(objects.Ptr32{Tag: objects.PtrFunc}), objects.BuiltInFunctionDef{Name: "has", Params: 2})
+	for i, name := range []string{"Bow", "Hookshot"} {
+		objs.AssociateSymbol(syms.Declare(name, symbols.TOKEN), objects.PackPtr32(objects.Ptr32{
+			Tag: objects.PtrToken, Addr: objects.Addr32(i),
+		}))
+	}
+
+	subs := compiler.NewSubroutines()
+	sources := map[string]string{
+		"Root -> Field":  "has(Bow, 1) or 'open' == 'closed'",
+		"Field -> House": "has(Hookshot, 1) and has(Bow, 1) or 'open' == 'closed'",
+		// past the length Str32 holds itself
+		"House -> Attic": "'" + strings.Repeat("long", 100) + "' != 'closed'",
+	}
+	nodes := make(map[string]ast.Node, len(sources))
+	for name, source := range sources {
+		node, err := ast.Parse(source, &syms, grammar)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes[name] = node
+		subs.Count(node)
+	}
+
+	module := Module{
+		Settings: settings.Fingerprint(0xC0FFEE),
+		Data:     0xBEEF,
+		Rules:    make(map[string]compiler.Bytecode, len(nodes)),
+	}
+	funcs := objs.FunctionTable()
+	for name, node := range nodes {
+		bytecode, err := compiler.CompileShared(node, &syms, &objs, funcs, &subs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		module.Rules[name] = bytecode
+	}
+	module.Objects = objects.TableFrom(&objs)
+	module.Subroutines = subs.Bodies
+	return module
+}
+
+func written(t *testing.T, module Module) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	if err := Write(&buffer, module); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestRoundTrips(t *testing.T) {
+	module := compiled(t)
+	if len(module.Subroutines) == 0 {
+		t.Fatal("expected fixture to share a subroutine")
+	}
+	raw := written(t, module)
+
+	read, err := Read(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, module) {
+		t.Fatalf("expected\n%#v\nfound\n%#v", module, read)
+	}
+	if again := written(t, read); !bytes.Equal(again, raw) {
+		t.Fatal("expected writing a read module to reproduce it")
+	}
+}
+
+func TestRejectsInvalidModules(t *testing.T) {
+	raw := written(t, compiled(t))
+	mutated := func(mutate func([]byte) []byte) []byte {
+		return mutate(bytes.Clone(raw))
+	}
+
+	rejects := map[string]struct {
+		raw      []byte
+		expected error
+	}{
+		"empty":     {nil, ErrNotModule},
+		"magic":     {mutated(func(b []byte) []byte { b[0] = 'N'; return b }), ErrNotModule},
+		"version":   {mutated(func(b []byte) []byte { binary.LittleEndian.PutUint16(b[4:], Version+1); return b }), ErrVersion},
+		"truncated": {raw[:len(raw)-1], ErrCorrupt},
+		"trailing":  {append(bytes.Clone(raw), 0), ErrCorrupt},
+		// object count far larger than the file
+		"objects": {mutated(func(b []byte) []byte { binary.LittleEndian.PutUint32(b[22:], 1<<30); return b }), ErrCorrupt},
+		// a long string's length prefix running past the heap
+		"long string": {mutated(func(b []byte) []byte {
+			binary.LittleEndian.PutUint32(b[bytes.Index(b, []byte("longlong"))-4:], 1<<20)
+			return b
+		}), ErrCorrupt},
+		// the first object count is one short so every const may be out of range
+		"consts": {mutated(func(b []byte) []byte {
+			count := binary.LittleEndian.Uint32(b[22:])
+			binary.LittleEndian.PutUint32(b[22:], count-1)
+			return append(b[:26+8*(count-1)], b[26+8*count:]...)
+		}), ErrCorrupt},
+	}
+
+	for name, reject := range rejects {
+		_, err := Read(bytes.NewReader(reject.raw))
+		if !errors.Is(err, reject.expected) {
+			t.Errorf("%s: expected %v, found %v", name, reject.expected, err)
+		}
+	}
+}
+
+func TestDetectsStaleModules(t *testing.T) {
+	module := compiled(t)
+	if err := module.Check(module.Settings, module.Data); err != nil {
+		t.Fatal(err)
+	}
+	if err := module.Check(module.Settings+1, module.Data); !errors.Is(err, ErrStale) {
+		t.Errorf("expected other settings to be stale, found %v", err)
+	}
+	if err := module.Check(module.Settings, module.Data+1); !errors.Is(err, ErrStale) {
+		t.Errorf("expected other data to be stale, found %v", err)
+	}
+}
+
+func TestVerifiesLoadedBytecode(t *testing.T) {
+	module := compiled(t)
+	funcs := []objects.BuiltInFunctionDef{{Name: "has", Params: 2}}
+	if err := module.Verify(funcs); err != nil {
+		t.Fatal(err)
+	}
+
+	module.Subroutines = module.Subroutines[:1]
+	if err := module.Verify(funcs); !errors.Is(err, ErrCorrupt) || !errors.Is(err, code.ErrVerify) {
+		t.Errorf("expected missing subroutine to fail verification, found %v", err)
+	}
+}
@@ -1,8 +1,41 @@
 package objects
 
+import "fmt"
+
+// what a builtin expects an argument to be, ArgAny is never checked
+type ArgKind uint8
+
+const (
+	ArgAny ArgKind = iota
+	ArgBool
+	ArgNumber
+	ArgString
+	ArgToken
+)
+
+func (this ArgKind) String() string {
+	switch this {
+	case ArgAny:
+		return "any"
+	case ArgBool:
+		return "bool"
+	case ArgNumber:
+		return "number"
+	case ArgString:
+		return "string"
+	case ArgToken:
+		return "token"
+	default:
+		return fmt.Sprintf("ArgKind(%d)", uint8(this))
+	}
+}
+
+// Params is -1 for variadic functions, their single Args kind applies to
+// every argument
 type BuiltInFunctionDef struct {
 	Name   string
 	Params int
+	Args   []ArgKind
 }
 type BuiltInFunction func(*Table, []Object) (Object, error)
 type BuiltInFunctions []BuiltInFunction
@@ -16,6 +16,7 @@ type Ptr32 struct {
 }
 
 type Str32 struct {
+	// LONG_STR when the heap holds the length, see Table.Span
 	Len  uint8
 	Addr Addr32
 }
@@ -153,7 +154,7 @@ func UnpackBool(obj Object) bool {
 	if field&MASK_BOOL != MASK_BOOL {
 		panic("not a boolean")
 	}
-	return field.GetU8() == 1
+	return obj == PackedTrue
 }
 
 const (
@@ -1,16 +1,21 @@
 package objects
 
 import (
+	"encoding/binary"
 	"fmt"
 	"math"
 	"slices"
 	"sudonters/libzootr/mido/symbols"
 )
 
-const MAX_STR_SIZE = math.MaxUint8
+// strings up to MAX_SHORT_STR_SIZE keep their length in Str32.Len, longer
+// strings set Len to LONG_STR and are prefixed in the heap with a u32 length
+const MAX_SHORT_STR_SIZE = math.MaxUint8 - 1
+const LONG_STR uint8 = math.MaxUint8
+const MAX_STR_SIZE = math.MaxUint32
 const TOTAL_STR_SIZE = math.MaxUint32
 
-type Index uint16
+type Index uint32
 
 func TableFrom(builder *Builder) Table {
 	var tbl Table
@@ -26,13 +31,39 @@ type Table struct {
 	values  []Object
 }
 
+// adopts values and strings without copying them
+func TableOf(values []Object, strings []byte) Table {
+	return Table{strings: strings, values: values}
+}
+
+func (this Table) Values() []Object {
+	return this.values
+}
+
+// the heap Str32 objects address
+func (this Table) Strings() []byte {
+	return this.strings
+}
+
 func (this Table) DerefString(obj Object) string {
 	if !obj.Is(MASK_STR32) {
 		panic("non-string dereference")
 	}
 
-	str32 := UnpackStr32(obj)
-	return string(this.strings[str32.Addr : uint32(str32.Addr)+uint32(str32.Len)])
+	start, end := this.Span(UnpackStr32(obj))
+	return string(this.strings[start:end])
+}
+
+// where str's bytes lie in the heap, corrupt strings end beyond it
+func (this Table) Span(str Str32) (start, end int) {
+	start = int(str.Addr)
+	if str.Len != LONG_STR {
+		return start, start + int(str.Len)
+	}
+	if start+4 > len(this.strings) {
+		return start, start + 4
+	}
+	return start + 4, start + 4 + int(binary.LittleEndian.Uint32(this.strings[start:]))
 }
 
 func (this Table) AtIndex(idx Index) Object {
@@ -75,6 +106,32 @@ func (this *Builder) DefineFunction(symbol *symbols.Sym, ptr Object, def BuiltIn
 	this.defs[symbol.Index] = def
 }
 
+func (this *Builder) FunctionDefinitions(yield func(symbols.Index, BuiltInFunctionDef) bool) {
+	for index, def := range this.defs {
+		if !yield(index, def) {
+			return
+		}
+	}
+}
+
+// every definition indexed by the address of its PtrFunc
+func (this *Builder) FunctionTable() []BuiltInFunctionDef {
+	var table []BuiltInFunctionDef
+	for symbol, def := range this.defs {
+		addr := int(UnpackPtr32(this.values[this.ptrs[symbol]]).Addr)
+		if addr >= len(table) {
+			table = append(table, make([]BuiltInFunctionDef, addr-len(table)+1)...)
+		}
+		table[addr] = def
+	}
+	return table
+}
+
+// every object interned so far, not a copy
+func (this *Builder) Values() []Object {
+	return this.values
+}
+
 func (this *Builder) FunctionDefinition(symbol *symbols.Sym) BuiltInFunctionDef {
 	def, exists := this.defs[symbol.Index]
 	if !exists {
@@ -98,6 +155,15 @@ func (this *Builder) AssociateSymbol(symbol *symbols.Sym, ptr Object) Index {
 	return idx
 }
 
+// every symbol associated with a pointer and the pointer's index
+func (this *Builder) Pointers(yield func(symbols.Index, Index) bool) {
+	for symbol, index := range this.ptrs {
+		if !yield(symbol, index) {
+			return
+		}
+	}
+}
+
 func (this *Builder) PtrFor(symbol *symbols.Sym) Index {
 	index, exists := this.ptrs[symbol.Index]
 	if !exists {
@@ -107,6 +173,11 @@ func (this *Builder) PtrFor(symbol *symbols.Sym) Index {
 	return index
 }
 
+func (this *Builder) LookUpPtr(symbol *symbols.Sym) (Index, bool) {
+	index, exists := this.ptrs[symbol.Index]
+	return index, exists
+}
+
 func (this *Builder) InternStr(str string) Index {
 	if index, exists := this.strs[str]; exists {
 		return index
@@ -117,12 +188,17 @@ func (this *Builder) InternStr(str string) Index {
 		panic(fmt.Errorf("%d is longest string size, %q is too long", MAX_STR_SIZE, str))
 	}
 	ptr := len(this.strings)
-	if ptr > TOTAL_STR_SIZE {
+	length := uint8(len(bytes))
+	if len(bytes) > MAX_SHORT_STR_SIZE {
+		length = LONG_STR
+		bytes = slices.Concat(binary.LittleEndian.AppendUint32(nil, uint32(len(bytes))), bytes)
+	}
+	if ptr+len(bytes) > TOTAL_STR_SIZE {
 		panic("string heap overflow")
 	}
 	this.strings = slices.Concat(this.strings, bytes)
 	idx := this.insert(PackStr32(Str32{
-		Len:  uint8(len(bytes)),
+		Len:  length,
 		Addr: Addr32(ptr),
 	}))
 	this.strs[str] = idx
@@ -130,6 +206,9 @@ func (this *Builder) InternStr(str string) Index {
 }
 
 func (this *Builder) insert(v Object) Index {
+	if len(this.values) > math.MaxUint32 {
+		panic("object table overflow")
+	}
 	idx := Index(len(this.values))
 	this.values = append(this.values, v)
 	return idx
@@ -32,5 +32,6 @@ func (this CompilerFunctions) Invoke(node ast.Invoke, rewrite ast.Rewriting) (as
 	if !exists {
 		return node, nil
 	}
-	return fn(node.Args, rewrite)
+	rewritten, err := fn(node.Args, rewrite)
+	return rewritten, ast.ErrorAt(node, err)
 }
@@ -1,6 +1,7 @@
 package optimizer
 
 import (
+	"errors"
 	"sudonters/libzootr/mido/ast"
 	"sudonters/libzootr/mido/symbols"
 )
@@ -44,6 +45,9 @@ func (this fold) Compare(node ast.Compare, rewrite ast.Rewriting) (ast.Node, err
 	case ast.KindBool:
 		return ast.Boolean(this.compare_bool(node)), nil
 	case ast.KindIdentifier:
+		if node.Op == ast.CompareLt {
+			return nil, ast.ErrorAt(node, errors.New("ptrs do not support ordering"))
+		}
 		return ast.Boolean(this.compare_ptr(node)), nil
 	default:
 		return rewrite.Compare(node)
@@ -89,7 +93,7 @@ func (this fold) compare_bool(node ast.Compare) bool {
 	case ast.CompareNq:
 		return lhs != rhs
 	case ast.CompareLt:
-		panic("booleans do not support ordering")
+		return !bool(lhs) && bool(rhs)
 	default:
 		panic("unsupported cmp op")
 	}
@@ -23,7 +23,7 @@ func BuildScriptedFuncTable(symbolTable *symbols.Table, grammar peruse.Grammar[r
 		var decl ScriptedFunction
 		head, declErr := ast.Parse(header, symbolTable, grammar)
 		if declErr != nil {
-			panic(declErr)
+			return funcTable, ruleparser.Diagnose(ruleparser.Origin{Name: header, Source: header}, "parse", declErr)
 		}
 
 		switch head := head.(type) {
@@ -36,12 +36,15 @@ func BuildScriptedFuncTable(symbolTable *symbols.Table, grammar peruse.Grammar[r
 				panic(fmt.Errorf("did not find entry for %#v", head))
 			}
 			decl.Params = make([]ast.Identifier, len(head.Args))
+			names := make([]string, len(head.Args))
 			for i := range decl.Params {
 				param := head.Args[i].(ast.Identifier)
 				symbol := symbolTable.LookUpByIndex(param.AsIndex())
 				symbol.SetKind(symbols.LOCAL)
 				decl.Params[i] = param
+				names[i] = symbol.Name
 			}
+			symbolTable.SetParams(decl.Symbol, names)
 		case ast.Identifier:
 			decl.Symbol = symbolTable.LookUpByIndex(head.AsIndex())
 			if decl.Symbol.Kind == symbols.BUILT_IN_FUNCTION {
@@ -61,7 +64,7 @@ func BuildScriptedFuncTable(symbolTable *symbols.Table, grammar peruse.Grammar[r
 		body := bodies[name]
 		nodes, bodyErr := ast.Parse(body, symbolTable, grammar)
 		if bodyErr != nil {
-			panic(bodyErr)
+			return funcTable, ruleparser.Diagnose(ruleparser.Origin{Name: name, Source: body}, "parse", bodyErr)
 		}
 		compiling.Body = nodes
 		funcTable.tbl[name] = compiling
@@ -0,0 +1,228 @@
+package optimizer
+
+import (
+	"slices"
+	"sudonters/libzootr/mido/ast"
+	"sudonters/libzootr/mido/symbols"
+)
+
+// minimizes and/or trees: nested nodes are flattened, duplicates, absorbed
+// and subsumed operands are removed and double negations are cancelled
+func Simplify(tbl *symbols.Table) ast.Rewriter {
+	simplify := simplify{
+		tbl:      tbl,
+		has:      tbl.Declare("has", symbols.FUNCTION),
+		hasEvery: tbl.Declare("has_every", symbols.FUNCTION),
+		hasAnyOf: tbl.Declare("has_anyof", symbols.FUNCTION),
+	}
+	return ast.Rewriter{
+		Every:  simplify.Every,
+		AnyOf:  simplify.AnyOf,
+		Invert: simplify.Invert,
+	}
+}
+
+type simplify struct {
+	tbl                     *symbols.Table
+	has, hasEvery, hasAnyOf *symbols.Sym
+}
+
+func (this simplify) Invert(node ast.Invert, rewrite ast.Rewriting) (ast.Node, error) {
+	inner, err := rewrite(node.Inner)
+	if err != nil {
+		return nil, err
+	}
+
+	switch inner := inner.(type) {
+	case ast.Invert:
+		return inner.Inner, nil
+	case ast.Boolean:
+		return !inner, nil
+	default:
+		return ast.Invert{Inner: inner}, nil
+	}
+}
+
+func (this simplify) Every(node ast.Every, rewrite ast.Rewriting) (ast.Node, error) {
+	items, err := rewrite.All(node)
+	if err != nil {
+		return nil, err
+	}
+
+	reduced := ast.Every(items).Flatten().Reduce()
+	every, isEvery := reduced.(ast.Every)
+	if !isEvery {
+		return reduced, nil
+	}
+	if this.complemented(every) {
+		return ast.Boolean(false), nil
+	}
+
+	// a and (a or b) is a, has(x, 2) and has(x, 1) is has(x, 2)
+	kept := this.minimize(every, func(which, other ast.Node) bool {
+		return this.eachImpliesSome(disjuncts(other), disjuncts(which))
+	})
+	return ast.Every(kept).Reduce(), nil
+}
+
+func (this simplify) AnyOf(node ast.AnyOf, rewrite ast.Rewriting) (ast.Node, error) {
+	items, err := rewrite.All(node)
+	if err != nil {
+		return nil, err
+	}
+
+	reduced := ast.AnyOf(items).Flatten().Reduce()
+	anyOf, isAnyOf := reduced.(ast.AnyOf)
+	if !isAnyOf {
+		return reduced, nil
+	}
+	if this.complemented(anyOf) {
+		return ast.Boolean(true), nil
+	}
+
+	// a or (a and b) is a, has(x, 2) or has(x, 1) is has(x, 1)
+	kept := this.minimize(anyOf, func(which, other ast.Node) bool {
+		return this.eachImpliedBySome(conjuncts(other), conjuncts(which))
+	})
+	return ast.AnyOf(kept).Reduce(), nil
+}
+
+// drops every operand made redundant by another operand, when two operands
+// make each other redundant the first is kept
+func (this simplify) minimize(nodes []ast.Node, redundant func(which, other ast.Node) bool) []ast.Node {
+	dropped := make([]bool, len(nodes))
+	for i := range nodes {
+		for j := range nodes {
+			if i == j || dropped[j] || !redundant(nodes[i], nodes[j]) {
+				continue
+			}
+			if j > i && redundant(nodes[j], nodes[i]) {
+				continue
+			}
+			dropped[i] = true
+			break
+		}
+	}
+
+	kept := make([]ast.Node, 0, len(nodes))
+	for i := range nodes {
+		if !dropped[i] {
+			kept = append(kept, nodes[i])
+		}
+	}
+	return kept
+}
+
+// true if nodes contains both a and not a
+func (this simplify) complemented(nodes []ast.Node) bool {
+	hashes := make(map[uint64]bool, len(nodes))
+	for _, node := range nodes {
+		hashes[ast.Hash(node)] = true
+	}
+	for _, node := range nodes {
+		if invert, isInvert := node.(ast.Invert); isInvert && hashes[ast.Hash(invert.Inner)] {
+			return true
+		}
+	}
+	return false
+}
+
+// true if every premise implies at least one of the conclusions
+func (this simplify) eachImpliesSome(premises, conclusions []ast.Node) bool {
+	for _, premise := range premises {
+		if !slices.ContainsFunc(conclusions, func(conclusion ast.Node) bool {
+			return this.implies(premise, conclusion)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// true if every conclusion is implied by at least one of the premises
+func (this simplify) eachImpliedBySome(conclusions, premises []ast.Node) bool {
+	for _, conclusion := range conclusions {
+		if !slices.ContainsFunc(premises, func(premise ast.Node) bool {
+			return this.implies(premise, conclusion)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+func (this simplify) implies(a, b ast.Node) bool {
+	if ast.Hash(a) == ast.Hash(b) {
+		return true
+	}
+
+	needs, needsQty, isHas := this.hasQty(b)
+	if isHas {
+		for what, qty := range this.holds(a) {
+			if what == needs && qty >= needsQty {
+				return true
+			}
+		}
+		return false
+	}
+
+	// has(x, 1) implies has_anyof(..., x, ...)
+	if invoke, isInvoke := b.(ast.Invoke); isInvoke && this.calls(invoke, this.hasAnyOf) {
+		for what, qty := range this.holds(a) {
+			for _, arg := range invoke.Args {
+				if qty >= 1 && ast.Hash(arg) == what {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// the tokens and quantities a has or has_every call requires
+func (this simplify) holds(node ast.Node) map[uint64]float64 {
+	if what, qty, isHas := this.hasQty(node); isHas {
+		return map[uint64]float64{what: qty}
+	}
+
+	invoke, isInvoke := node.(ast.Invoke)
+	if !isInvoke || !this.calls(invoke, this.hasEvery) {
+		return nil
+	}
+	holds := make(map[uint64]float64, len(invoke.Args))
+	for _, arg := range invoke.Args {
+		holds[ast.Hash(arg)] = 1
+	}
+	return holds
+}
+
+func (this simplify) hasQty(node ast.Node) (uint64, float64, bool) {
+	invoke, isInvoke := node.(ast.Invoke)
+	if !isInvoke || !this.calls(invoke, this.has) || len(invoke.Args) != 2 {
+		return 0, 0, false
+	}
+	qty, isNumber := invoke.Args[1].(ast.Number)
+	if !isNumber {
+		return 0, 0, false
+	}
+	return ast.Hash(invoke.Args[0]), float64(qty), true
+}
+
+func (this simplify) calls(invoke ast.Invoke, fn *symbols.Sym) bool {
+	symbol := ast.LookUpNodeInTable(this.tbl, invoke.Target)
+	return symbol != nil && symbol.Eq(fn)
+}
+
+func conjuncts(node ast.Node) []ast.Node {
+	if every, isEvery := node.(ast.Every); isEvery {
+		return every
+	}
+	return []ast.Node{node}
+}
+
+func disjuncts(node ast.Node) []ast.Node {
+	if anyOf, isAnyOf := node.(ast.AnyOf); isAnyOf {
+		return anyOf
+	}
+	return []ast.Node{node}
+}
@@ -0,0 +1,57 @@
+package optimizer
+
+import (
+	"sudonters/libzootr/mido/ast"
+	"sudonters/libzootr/mido/symbols"
+	"sudonters/libzootr/ruleparser"
+	"testing"
+)
+
+func TestSimplify(t *testing.T) {
+	grammar := ruleparser.NewRulesGrammar()
+	syms := symbols.NewTable()
+	syms.Declare("has", symbols.BUILT_IN_FUNCTION)
+	syms.DeclareMany(symbols.TOKEN, []string{"Bow", "Hookshot", "Bombs"})
+	simplify := Simplify(&syms)
+
+	expected := map[string]string{
+		"a and a and b":                             "a and b",
+		"a or (b or (a or c))":                      "a or b or c",
+		"a or (a and b)":                            "a",
+		"(a and b) or a":                            "a",
+		"a and (b or a)":                            "a",
+		"(a and b) or (b and a and c)":              "a and b",
+		"has(Bow, 1) and has(Bow, 2)":               "has(Bow, 2)",
+		"has(Bow, 1) or has(Bow, 2)":                "has(Bow, 1)",
+		"has(Bow, 3) or (has(Bow, 1) and Hookshot)": "has(Bow, 3) or has(Bow, 1) and Hookshot",
+		"has(Bow, 2) and (has(Bow, 1) or Hookshot)": "has(Bow, 2)",
+		"has_every(Bow, Bombs) and has(Bow, 1)":     "has_every(Bow, Bombs)",
+		"has(Bow, 1) or has_anyof(Bow, Hookshot)":   "has_anyof(Bow, Hookshot)",
+		"not not a":                                 "a",
+		"not (not (not a))":                         "not a",
+		"a and not a and b":                         "False",
+		"a or b or not a":                           "True",
+		"(a or b) and (b or a)":                     "a or b",
+		"x and (a or (a and b)) and not not x":      "x and a",
+	}
+
+	for source, want := range expected {
+		parsed, err := ast.Parse(source, &syms, grammar)
+		if err != nil {
+			t.Fatalf("could not parse %q: %s", source, err)
+		}
+		simplified, err := simplify.Rewrite(parsed)
+		if err != nil {
+			t.Fatalf("could not simplify %q: %s", source, err)
+		}
+		wanted, err := ast.Parse(want, &syms, grammar)
+		if err != nil {
+			t.Fatalf("could not parse %q: %s", want, err)
+		}
+
+		if ast.Hash(simplified) != ast.Hash(wanted) {
+			printed, _ := ast.Print(&syms, simplified)
+			t.Errorf("expected %q to simplify to %q but found %q", source, want, printed)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package mido
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"sudonters/libzootr/ruleparser"
 	"sudonters/libzootr/internal/settings"
 	"sudonters/libzootr/mido/ast"
@@ -10,6 +11,7 @@ import (
 	"sudonters/libzootr/mido/objects"
 	"sudonters/libzootr/mido/optimizer"
 	"sudonters/libzootr/mido/symbols"
+	"sudonters/libzootr/mido/typecheck"
 
 	"github.com/etc-sudonters/substrate/peruse"
 )
@@ -82,7 +84,7 @@ func WithCompilerFunctions(create func(*CompileEnv) optimizer.CompilerFunctionTa
 		}
 
 		compiler := optimizer.NewCompilerFuncs(env.Symbols, funcs)
-		env.Optimize.AddOptimizer(func(*CompileEnv) ast.Rewriter {
+		env.Optimize.AddOptimizer("CompilerFunctions", func(*CompileEnv) ast.Rewriter {
 			return compiler
 		})
 	}
@@ -102,6 +104,23 @@ func WithBuiltInFunctionDefs(create func(*CompileEnv) []objects.BuiltInFunctionD
 	}
 }
 
+// caps how many optimization passes a single rule may take
+func WithMaxOptimizePasses(passes int) ConfigureCompiler {
+	return func(env *CompileEnv) {
+		env.Optimize.MaxPasses = passes
+	}
+}
+
+// drops the named optimizer, must be applied after whatever added it
+func WithoutOptimizer(name string) ConfigureCompiler {
+	return func(env *CompileEnv) {
+		if i := slices.Index(env.Optimize.names, name); i >= 0 {
+			env.Optimize.Optimiziers = slices.Delete(env.Optimize.Optimiziers, i, i+1)
+			env.Optimize.names = slices.Delete(env.Optimize.names, i, i+1)
+		}
+	}
+}
+
 func CompilerWithTokens(names []string) ConfigureCompiler {
 	return func(env *CompileEnv) {
 		env.Symbols.DeclareMany(symbols.TOKEN, names)
@@ -110,29 +129,51 @@ func CompilerWithTokens(names []string) ConfigureCompiler {
 
 func CompilerDefaults() ConfigureCompiler {
 	return func(env *CompileEnv) {
-		env.Optimize.Passes = 10
+		env.Optimize.MaxPasses = DefaultMaxOptimizePasses
 
 		env.Symbols.DeclareMany(symbols.GLOBAL, GlobalNames())
 		env.Symbols.DeclareMany(symbols.SETTING, settings.Names())
 
-		env.Optimize.AddOptimizer(func(env *CompileEnv) ast.Rewriter {
+		env.Optimize.AddOptimizer("InlineCalls", func(env *CompileEnv) ast.Rewriter {
 			return optimizer.InlineCalls(env.Optimize.Context, env.Symbols, env.ScriptedFuncs)
 		})
-		env.Optimize.AddOptimizer(func(env *CompileEnv) ast.Rewriter {
+		env.Optimize.AddOptimizer("FoldConstants", func(env *CompileEnv) ast.Rewriter {
 			return optimizer.FoldConstants(env.Symbols)
 		})
-		env.Optimize.AddOptimizer(func(env *CompileEnv) ast.Rewriter {
+		env.Optimize.AddOptimizer("InvokeBareFuncs", func(env *CompileEnv) ast.Rewriter {
 			return optimizer.InvokeBareFuncs(env.Symbols, env.ScriptedFuncs)
 		})
-		env.Optimize.AddOptimizer(func(env *CompileEnv) ast.Rewriter {
+		env.Optimize.AddOptimizer("Simplify", func(env *CompileEnv) ast.Rewriter {
+			return optimizer.Simplify(env.Symbols)
+		})
+		env.Optimize.AddOptimizer("CollapseHas", func(env *CompileEnv) ast.Rewriter {
 			return optimizer.CollapseHas(env.Symbols)
 		})
-		env.Optimize.AddOptimizer(func(env *CompileEnv) ast.Rewriter {
+		env.Optimize.AddOptimizer("PromoteTokens", func(env *CompileEnv) ast.Rewriter {
 			return optimizer.PromoteTokens(env.Symbols)
 		})
+
+		env.Analysis.PreOptimize(TypeCheck)
+		env.Analysis.PostOptimize(TypeCheck)
 	}
 }
 
+// checks invokes against builtin definitions, scripted function declarations
+// and signatures declared with Analysis.DeclareSignature
+func TypeCheck(env *CompileEnv) ast.Visitor {
+	sigs := make(typecheck.Signatures, len(env.Analysis.signatures))
+	for index, sig := range env.Analysis.signatures {
+		sigs[index] = sig
+	}
+	for index, def := range env.Objects.FunctionDefinitions {
+		sigs[index] = typecheck.FromBuiltIn(def)
+	}
+	if env.ScriptedFuncs != nil {
+		sigs.InferScripted(env.Symbols, env.ScriptedFuncs)
+	}
+	return typecheck.Checker(env.Symbols, sigs)
+}
+
 func NewCompileEnv(configure ...ConfigureCompiler) CompileEnv {
 	var env CompileEnv
 	env.Grammar = ruleparser.NewRulesGrammar()
@@ -167,8 +208,18 @@ func (this *CompileEnv) OnSourceLoad(f SourceLoaded) {
 }
 
 type Analysis struct {
-	pre  []Analyzer
-	post []Analyzer
+	pre        []Analyzer
+	post       []Analyzer
+	signatures typecheck.Signatures
+}
+
+// compiler functions are otherwise unchecked, builtin and scripted function
+// signatures come from their definitions
+func (this *Analysis) DeclareSignature(symbol *symbols.Sym, sig typecheck.Signature) {
+	if this.signatures == nil {
+		this.signatures = make(typecheck.Signatures)
+	}
+	this.signatures[symbol.Index] = sig
 }
 
 func (this *Analysis) PreOptimize(v Analyzer) {
@@ -179,14 +230,23 @@ func (this *Analysis) PostOptimize(v Analyzer) {
 	this.post = append(this.post, v)
 }
 
+const DefaultMaxOptimizePasses = 64
+
+// every optimizer runs in order once per pass, passes repeat until a pass
+// leaves the rule's ast.Hash unchanged or MaxPasses have run
 type Optimize struct {
 	Context     *optimizer.Context
 	Optimiziers []Optimizer
-	Passes      int
+	MaxPasses   int
+	// opt-in, see Tracing
+	Tracing *Tracing
+	names   []string
 }
 
-func (this *Optimize) AddOptimizer(o Optimizer) {
+// name identifies the optimizer in traces
+func (this *Optimize) AddOptimizer(name string, o Optimizer) {
 	this.Optimiziers = append(this.Optimiziers, o)
+	this.names = append(this.names, name)
 }
 
 func (this *CompileEnv) BuildScriptedFuncs(declarations map[string]string) error {
@@ -209,6 +269,8 @@ func Compiler(env *CompileEnv) CodeGen {
 		rewriters:     make([]ast.Rewriter, len(optimizers)),
 		preanalyzers:  make([]ast.Visitor, len(analysis.pre)),
 		postanalyzers: make([]ast.Visitor, len(analysis.post)),
+		subs:          ptr(compiler.NewSubroutines()),
+		funcs:         env.Objects.FunctionTable(),
 	}
 	for i := range codegen.rewriters {
 		codegen.rewriters[i] = optimizers[i](env)
@@ -228,28 +290,205 @@ type CodeGen struct {
 	rewriters     []ast.Rewriter
 	preanalyzers  []ast.Visitor
 	postanalyzers []ast.Visitor
+	subs          *compiler.Subroutines
+	// builtins are all defined by the time the env is configured
+	funcs []objects.BuiltInFunctionDef
 }
 
 func (this CodeGen) Parse(source string) (ast.Node, error) {
 	ast, err := ast.Parse(source, this.env.Symbols, this.env.Grammar)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrParse, err)
+	}
 	return ast, err
 }
 
+// same as Parse but also records where each node came from, pass the spans
+// along to OptimizeRule and Diagnose
+func (this CodeGen) ParseWithSpans(source string) (ast.Node, ast.Spans, error) {
+	node, spans, err := ast.ParseWithSpans(source, this.env.Symbols, this.env.Grammar)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrParse, err)
+	}
+	return node, spans, err
+}
+
+// renders an error from Parse, Optimize or Compile as diagnostics pointing
+// into the origin's source, a parse error produces one diagnostic per syntax
+// error. Later errors are located with the spans ParseWithSpans recorded for
+// the rule, without them they point at the whole source
+func (this CodeGen) Diagnose(origin ruleparser.Origin, spans ast.Spans, err error) ruleparser.Diagnostics {
+	var stage string
+	var lowering ast.CouldNotLowerTree
+	var syntax ruleparser.SyntaxErrors
+	switch {
+	case errors.As(err, &lowering):
+		stage = "lower"
+	case errors.Is(err, ErrParse):
+		stage = "parse"
+	case errors.Is(err, ErrAnalysis):
+		stage = "check"
+	case errors.Is(err, ErrOptimization):
+		stage = "optimize"
+	case errors.Is(err, ErrCompile):
+		stage = "compile"
+	}
+
+	if stage == "parse" && errors.As(err, &syntax) {
+		diagnostics := make(ruleparser.Diagnostics, len(syntax))
+		for i := range syntax {
+			diagnostics[i] = ruleparser.Diagnose(origin, stage, syntax[i])
+		}
+		return diagnostics
+	}
+
+	diagnostic := ruleparser.Diagnose(origin, stage, err)
+	if stage == "check" || stage == "optimize" || stage == "compile" {
+		if span, found := spans.Locate(err); found {
+			diagnostic.At = span
+		}
+	}
+
+	return ruleparser.Diagnostics{diagnostic}
+}
+
+// runs every PreOptimize analyzer over node
+func (this CodeGen) PreAnalyze(node ast.Node) error {
+	return analyze(this.preanalyzers, node)
+}
+
+// runs every PostOptimize analyzer over node
+func (this CodeGen) PostAnalyze(node ast.Node) error {
+	return analyze(this.postanalyzers, node)
+}
+
+func analyze(analyzers []ast.Visitor, node ast.Node) error {
+	var errs []error
+	for i := range analyzers {
+		errs = append(errs, analyzers[i].Visit(node))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w: %w", ErrAnalysis, err)
+	}
+	return nil
+}
+
 func (this CodeGen) Optimize(node ast.Node) (ast.Node, error) {
-	var rewriteErr error
-	for range this.env.Optimize.Passes {
-		node, rewriteErr = ast.RewriteWithEvery(node, this.rewriters)
+	optimized, err := this.OptimizeUntilStable(node)
+	return optimized.Node, err
+}
+
+// Converged is false if the rule was still changing when MaxPasses ran out
+type Optimized struct {
+	Node      ast.Node
+	Passes    int
+	Converged bool
+}
+
+func (this CodeGen) OptimizeUntilStable(node ast.Node) (Optimized, error) {
+	return this.optimize(node, this.rewriters, nil)
+}
+
+// same as OptimizeUntilStable but records every rewriter's effect if tracing
+// is enabled and selects the rule. Rewritten nodes inherit spans from the
+// nodes they replace so Diagnose can still locate them
+func (this CodeGen) OptimizeRule(name string, node ast.Node, spans ast.Spans) (Optimized, error) {
+	rewriters := this.rewriters
+	if spans != nil {
+		rewriters = make([]ast.Rewriter, len(this.rewriters))
+		for i := range this.rewriters {
+			rewriters[i] = spans.Carry(this.rewriters[i])
+		}
+	}
+	tracing := this.env.Optimize.Tracing
+	if tracing == nil || !tracing.selects(name) {
+		return this.optimize(node, rewriters, nil)
+	}
+	trace := OptimizeTrace{Rule: name}
+	optimized, err := this.optimize(node, rewriters, &trace)
+	tracing.Traces = append(tracing.Traces, trace)
+	return optimized, err
+}
+
+func (this CodeGen) optimize(node ast.Node, rewriters []ast.Rewriter, trace *OptimizeTrace) (Optimized, error) {
+	optimized := Optimized{Node: node}
+	hash := ast.Hash(node)
+	for optimized.Passes < this.env.Optimize.MaxPasses {
+		var rewriteErr error
+		optimized.Passes++
+		if trace == nil {
+			optimized.Node, rewriteErr = ast.RewriteWithEvery(optimized.Node, rewriters)
+		} else {
+			optimized.Node, rewriteErr = this.tracePass(optimized.Node, rewriters, optimized.Passes, trace)
+		}
 		if rewriteErr != nil {
-			rewriteErr = fmt.Errorf("%w: %w", ErrOptimization, rewriteErr)
+			return optimized, fmt.Errorf("%w: %w", ErrOptimization, rewriteErr)
+		}
+
+		rewritten := ast.Hash(optimized.Node)
+		if rewritten == hash {
+			optimized.Converged = true
+			break
+		}
+		hash = rewritten
+	}
+
+	return optimized, nil
+}
+
+// runs one pass like ast.RewriteWithEvery, one step per rewriter
+func (this CodeGen) tracePass(node ast.Node, rewriters []ast.Rewriter, pass int, trace *OptimizeTrace) (ast.Node, error) {
+	var err error
+	width := this.env.Optimize.Tracing.Width
+	for i := range rewriters {
+		before := node
+		node, err = rewriters[i].Rewrite(node)
+		step := OptimizeStep{
+			Pass:      pass,
+			Optimizer: this.env.Optimize.names[i],
+			Before:    this.describe(before, width),
+		}
+		if node != nil {
+			step.After = this.describe(node, width)
+			step.Changed = ast.Hash(before) != ast.Hash(node)
+		}
+		if err != nil {
+			step.Error = err.Error()
+		}
+		trace.Steps = append(trace.Steps, step)
+		if node == nil || err != nil {
 			break
 		}
 	}
+	return node, err
+}
 
-	return node, rewriteErr
+// rule source when possible, ast.Render otherwise
+func (this CodeGen) describe(node ast.Node, width int) string {
+	printer := ruleparser.Printer{Width: width}
+	if printed, err := ast.PrintWith(printer, this.env.Symbols, node); err == nil {
+		return printed
+	}
+	return ast.Render(node)
+}
+
+// counts node's subtrees so Compile shares the ones repeated across every
+// shared node, all rules should be shared before any are compiled
+func (this CodeGen) Share(node ast.Node) {
+	this.subs.Count(node)
+}
+
+// bodies CALL_SUB refers to, see vm.VM.Subroutines
+func (this CodeGen) Subroutines() []compiler.Bytecode {
+	return this.subs.Bodies
+}
+
+func (this CodeGen) Sharing() compiler.Sharing {
+	return this.subs.Sharing()
 }
 
 func (this CodeGen) Compile(node ast.Node) (compiler.Bytecode, error) {
-	bytecode, compileErr := compiler.Compile(node, this.env.Symbols, this.env.Objects)
+	bytecode, compileErr := compiler.CompileShared(node, this.env.Symbols, this.env.Objects, this.funcs, this.subs)
 	if compileErr != nil {
 		compileErr = fmt.Errorf("%w: %w", ErrCompile, compileErr)
 	}
@@ -271,34 +510,27 @@ func (this CodeGen) CompileSource(src *CompilationSource) (compiler.Bytecode, er
 		}
 	}
 
-	for i := range this.env.Analysis.pre {
-		this.preanalyzers[i].Visit(src.Ast)
+	if analysisErr := this.PreAnalyze(src.Ast); analysisErr != nil {
+		return bytecode, analysisErr
 	}
 
-	src.Optimized = src.Ast
-	for range this.env.Optimize.Passes {
-		var rewriteErr error
-		src.Optimized, rewriteErr = ast.RewriteWithEvery(src.Optimized, this.rewriters)
-		if rewriteErr != nil {
-			return bytecode, fmt.Errorf("%w: %w", ErrOptimization, rewriteErr)
-		}
+	optimized, rewriteErr := this.OptimizeUntilStable(src.Ast)
+	if rewriteErr != nil {
+		return bytecode, rewriteErr
 	}
+	src.Optimized = optimized.Node
 
-	for i := range this.env.Analysis.post {
-		this.postanalyzers[i].Visit(src.Ast)
+	if analysisErr := this.PostAnalyze(src.Optimized); analysisErr != nil {
+		return bytecode, analysisErr
 	}
 
-	var compileErr error
-	bytecode, compileErr = compiler.Compile(src.Ast, this.env.Symbols, this.env.Objects)
-	if compileErr != nil {
-		compileErr = fmt.Errorf("%w: %w", ErrCompile, compileErr)
-	}
-	return bytecode, compileErr
+	return this.Compile(src.Optimized)
 }
 
 var (
 	ErrSourceLoad   = errors.New("source load")
 	ErrParse        = errors.New("parsing")
+	ErrAnalysis     = errors.New("analysis")
 	ErrOptimization = errors.New("optimization")
 	ErrCompile      = errors.New("compile")
 )
@@ -0,0 +1,164 @@
+package mido
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sudonters/libzootr/mido/ast"
+	"sudonters/libzootr/mido/objects"
+	"sudonters/libzootr/ruleparser"
+	"testing"
+)
+
+// counts numbers down by one each pass
+func countdown(*CompileEnv) ast.Rewriter {
+	return ast.Rewriter{
+		Number: func(node ast.Number, _ ast.Rewriting) (ast.Node, error) {
+			return max(node-1, 0), nil
+		},
+	}
+}
+
+func TestOptimizeRunsUntilStable(t *testing.T) {
+	env := NewCompileEnv(WithMaxOptimizePasses(10), func(env *CompileEnv) {
+		env.Optimize.AddOptimizer("countdown", countdown)
+	})
+	codegen := Compiler(&env)
+
+	optimized, err := codegen.OptimizeUntilStable(ast.Every{ast.Number(3), ast.Number(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// three passes to reach zero and one to see nothing changed
+	if !optimized.Converged || optimized.Passes != 4 {
+		t.Fatalf("expected to converge after 4 passes, found %+v", optimized)
+	}
+
+	optimized, err = codegen.OptimizeUntilStable(ast.Number(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !optimized.Converged || optimized.Passes != 1 {
+		t.Fatalf("expected to converge after 1 pass, found %+v", optimized)
+	}
+}
+
+func TestOptimizeStopsAtCap(t *testing.T) {
+	env := NewCompileEnv(WithMaxOptimizePasses(2), func(env *CompileEnv) {
+		env.Optimize.AddOptimizer("countdown", countdown)
+	})
+	codegen := Compiler(&env)
+
+	optimized, err := codegen.OptimizeUntilStable(ast.Number(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if optimized.Converged || optimized.Passes != 2 || optimized.Node != ast.Number(3) {
+		t.Fatalf("expected to stop at the cap with 3, found %+v", optimized)
+	}
+}
+
+func TestOptimizeRuleTracesSelectedRules(t *testing.T) {
+	tracing := TraceRule("traced")
+	env := NewCompileEnv(WithMaxOptimizePasses(10), WithOptimizeTracing(tracing), func(env *CompileEnv) {
+		env.Optimize.AddOptimizer("countdown", countdown)
+		env.Optimize.AddOptimizer("noop", func(*CompileEnv) ast.Rewriter { return ast.Rewriter{} })
+	})
+	codegen := Compiler(&env)
+
+	if _, err := codegen.OptimizeRule("untraced", ast.Number(2), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := codegen.OptimizeRule("traced", ast.Number(2), nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(tracing.Traces) != 1 || tracing.Traces[0].Rule != "traced" {
+		t.Fatalf("expected only the selected rule to be traced, found %+v", tracing.Traces)
+	}
+
+	steps := tracing.Traces[0].Steps
+	// two rewriters over three passes
+	if len(steps) != 6 {
+		t.Fatalf("expected 6 steps, found %+v", steps)
+	}
+	first := OptimizeStep{Pass: 1, Optimizer: "countdown", Before: "2", After: "1", Changed: true}
+	if steps[0] != first {
+		t.Fatalf("expected %+v, found %+v", first, steps[0])
+	}
+	if steps[1].Optimizer != "noop" || steps[1].Changed {
+		t.Fatalf("expected noop not to change the rule, found %+v", steps[1])
+	}
+
+	diff := tracing.Traces[0].Diff()
+	if !strings.Contains(diff, "pass 2: countdown") || strings.Contains(diff, "noop") {
+		t.Fatalf("expected only changing steps in diff, found\n%s", diff)
+	}
+}
+
+func TestDiffLinesAlignsCommonLines(t *testing.T) {
+	lines := diffLines([]string{"a", "b", "c"}, []string{"a", "x", "c", "d"})
+	expected := []diffLine{
+		{"a", "a", ' '},
+		{"b", "x", '|'},
+		{"c", "c", ' '},
+		{"", "d", '>'},
+	}
+	if !slices.Equal(lines, expected) {
+		t.Fatalf("expected %+v, found %+v", expected, lines)
+	}
+}
+
+func TestTypeCheckDiagnosesInvoke(t *testing.T) {
+	env := NewCompileEnv(CompilerDefaults(), WithBuiltInFunctionDefs(func(*CompileEnv) []objects.BuiltInFunctionDef {
+		return []objects.BuiltInFunctionDef{{Name: "has", Params: 2, Args: []objects.ArgKind{objects.ArgToken, objects.ArgNumber}}}
+	}), CompilerWithTokens([]string{"Bow"}))
+	codegen := Compiler(&env)
+
+	origin := ruleparser.Origin{Name: "rule", Source: "is_adult or has(Bow)"}
+	node, spans, err := codegen.ParseWithSpans(origin.Source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = codegen.PreAnalyze(node)
+	if !errors.Is(err, ErrAnalysis) {
+		t.Fatalf("expected analysis error, found %v", err)
+	}
+
+	diagnostics := codegen.Diagnose(origin, spans, err)
+	if len(diagnostics) != 1 || diagnostics[0].Stage != "check" {
+		t.Fatalf("expected one check diagnostic, found %v", diagnostics)
+	}
+	if at := diagnostics[0].At; origin.Source[at.Start:at.End] != "has(Bow)" {
+		t.Fatalf("expected diagnostic at has(Bow), found %v", at)
+	}
+}
+
+func TestWithoutOptimizerDropsIt(t *testing.T) {
+	env := NewCompileEnv(CompilerDefaults(), WithoutOptimizer("Simplify"))
+	if slices.Contains(env.Optimize.names, "Simplify") || len(env.Optimize.names) != len(env.Optimize.Optimiziers) {
+		t.Fatalf("expected Simplify to be dropped, found %v", env.Optimize.names)
+	}
+}
+
+func TestCompileSourceCompilesOptimizedRule(t *testing.T) {
+	env := NewCompileEnv(WithMaxOptimizePasses(10), func(env *CompileEnv) {
+		env.Optimize.AddOptimizer("countdown", countdown)
+	})
+	codegen := Compiler(&env)
+
+	src := CompilationSource{String: "3"}
+	bytecode, err := codegen.CompileSource(&src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.Optimized != ast.Number(0) {
+		t.Fatalf("expected rule to optimize to 0, found %#v", src.Optimized)
+	}
+	expected, err := codegen.Compile(ast.Number(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(bytecode.Tape, expected.Tape) {
+		t.Fatalf("expected optimized tape %v, found %v", expected.Tape, bytecode.Tape)
+	}
+}
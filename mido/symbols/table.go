@@ -2,12 +2,15 @@ package symbols
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 )
 
 func NewTable() Table {
 	return Table{
-		names: make(map[string]int),
-		syms:  nil,
+		names:  make(map[string]int),
+		syms:   nil,
+		params: make(map[Index][]string),
 	}
 }
 
@@ -15,6 +18,7 @@ type Table struct {
 	names   map[string]int
 	syms    []Sym
 	aliased int
+	params  map[Index][]string
 }
 
 func (tbl *Table) RawAll(f func(*Sym) bool) {
@@ -87,6 +91,17 @@ func (tbl *Table) Alias(symbol *Sym, alias string) {
 	tbl.aliased += 1
 }
 
+// records the parameter names of a function so keyword arguments can be
+// placed positionally
+func (tbl *Table) SetParams(symbol *Sym, params []string) {
+	tbl.params[symbol.Index] = params
+}
+
+func (tbl *Table) Params(symbol *Sym) ([]string, bool) {
+	params, exists := tbl.params[symbol.Index]
+	return params, exists
+}
+
 func (tbl *Table) Size() int {
 	return len(tbl.syms) - tbl.aliased
 }
@@ -153,3 +168,12 @@ const (
 	TOKEN             Kind = "TOKEN"
 	TRANSIT           Kind = "TRANSIT"
 )
+
+var escaping = regexp.MustCompile(`['()[\]-]`)
+
+// the identifier rules use to refer to a token name, e.g. "Goron Tunic" is
+// aliased as Goron_Tunic
+func Escape(name string) string {
+	name = escaping.ReplaceAllLiteralString(name, "")
+	return strings.ReplaceAll(name, " ", "_")
+}
@@ -0,0 +1,128 @@
+package mido
+
+import (
+	"fmt"
+	"strings"
+)
+
+// collects an OptimizeTrace for every rule passed to CodeGen.OptimizeRule
+// that Selects accepts
+type Tracing struct {
+	Selects func(rule string) bool
+	// rules are printed broken across lines at this many columns, 0 never
+	// wraps
+	Width  int
+	Traces []OptimizeTrace
+}
+
+func TraceRule(name string) *Tracing {
+	return &Tracing{Selects: func(rule string) bool { return rule == name }}
+}
+
+func TraceAll() *Tracing {
+	return &Tracing{Selects: func(string) bool { return true }}
+}
+
+func WithOptimizeTracing(tracing *Tracing) ConfigureCompiler {
+	return func(env *CompileEnv) {
+		env.Optimize.Tracing = tracing
+	}
+}
+
+func (this *Tracing) selects(rule string) bool {
+	return this.Selects == nil || this.Selects(rule)
+}
+
+type OptimizeTrace struct {
+	Rule  string         `json:"rule"`
+	Steps []OptimizeStep `json:"steps"`
+}
+
+// one rewriter applied during one pass, Before and After are rule source if
+// the node can be printed and ast.Render otherwise
+type OptimizeStep struct {
+	Pass      int    `json:"pass"`
+	Optimizer string `json:"optimizer"`
+	Before    string `json:"before"`
+	After     string `json:"after"`
+	Changed   bool   `json:"changed"`
+	Error     string `json:"error,omitempty"`
+}
+
+// side by side line diff of every step that changed the rule, lines only in
+// before are marked <, only in after >
+func (this OptimizeTrace) Diff() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "rule: %s\n", this.Rule)
+	for _, step := range this.Steps {
+		if !step.Changed && step.Error == "" {
+			continue
+		}
+		fmt.Fprintf(&sb, "\npass %d: %s\n", step.Pass, step.Optimizer)
+		if step.Error != "" {
+			fmt.Fprintf(&sb, "error: %s\n", step.Error)
+			continue
+		}
+		before, after := strings.Split(step.Before, "\n"), strings.Split(step.After, "\n")
+		width := 0
+		for _, line := range before {
+			width = max(width, len(line))
+		}
+		for _, line := range diffLines(before, after) {
+			fmt.Fprintf(&sb, "%-*s %c %s\n", width, line.before, line.mark, line.after)
+		}
+	}
+	return sb.String()
+}
+
+type diffLine struct {
+	before, after string
+	mark          rune
+}
+
+// aligns the longest common subsequence of lines, unmatched lines on both
+// sides are paired up with |
+func diffLines(before, after []string) []diffLine {
+	common := make([][]int, len(before)+1)
+	for i := range common {
+		common[i] = make([]int, len(after)+1)
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		for j := len(after) - 1; j >= 0; j-- {
+			if before[i] == after[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var lines, removed, added []diffLine
+	flush := func() {
+		for len(removed) > 0 && len(added) > 0 {
+			lines = append(lines, diffLine{removed[0].before, added[0].after, '|'})
+			removed, added = removed[1:], added[1:]
+		}
+		lines = append(lines, removed...)
+		lines = append(lines, added...)
+		removed, added = nil, nil
+	}
+
+	i, j := 0, 0
+	for i < len(before) || j < len(after) {
+		switch {
+		case i < len(before) && j < len(after) && before[i] == after[j]:
+			flush()
+			lines = append(lines, diffLine{before[i], after[j], ' '})
+			i, j = i+1, j+1
+		case j == len(after) || (i < len(before) && common[i+1][j] >= common[i][j+1]):
+			removed = append(removed, diffLine{before: before[i], mark: '<'})
+			i++
+		default:
+			added = append(added, diffLine{after: after[j], mark: '>'})
+			j++
+		}
+	}
+	flush()
+	return lines
+}
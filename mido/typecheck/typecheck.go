@@ -0,0 +1,209 @@
+package typecheck
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sudonters/libzootr/mido/ast"
+	"sudonters/libzootr/mido/objects"
+	"sudonters/libzootr/mido/optimizer"
+	"sudonters/libzootr/mido/symbols"
+)
+
+// Params beyond Required are optional, a Variadic signature repeats its last
+// param
+type Signature struct {
+	Params   []objects.ArgKind
+	Required int
+	Variadic bool
+}
+
+func Fixed(params ...objects.ArgKind) Signature {
+	return Signature{Params: params, Required: len(params)}
+}
+
+func Variadic(kind objects.ArgKind) Signature {
+	return Signature{Params: []objects.ArgKind{kind}, Variadic: true}
+}
+
+func FromBuiltIn(def objects.BuiltInFunctionDef) Signature {
+	if def.Params < 0 {
+		kind := objects.ArgAny
+		if len(def.Args) > 0 {
+			kind = def.Args[0]
+		}
+		return Variadic(kind)
+	}
+	params := make([]objects.ArgKind, def.Params)
+	copy(params, def.Args)
+	return Fixed(params...)
+}
+
+func (this Signature) param(i int) objects.ArgKind {
+	switch {
+	case i < len(this.Params):
+		return this.Params[i]
+	case this.Variadic && len(this.Params) > 0:
+		return this.Params[len(this.Params)-1]
+	default:
+		return objects.ArgAny
+	}
+}
+
+func (this Signature) arity(args int) error {
+	switch {
+	case this.Variadic:
+		if args < this.Required {
+			return fmt.Errorf("expects at least %d arguments, found %d", this.Required, args)
+		}
+	case this.Required == len(this.Params):
+		if args != this.Required {
+			return fmt.Errorf("expects %d arguments, found %d", this.Required, args)
+		}
+	case args < this.Required || args > len(this.Params):
+		return fmt.Errorf("expects between %d and %d arguments, found %d", this.Required, len(this.Params), args)
+	}
+	return nil
+}
+
+// functions without a signature are not checked
+type Signatures map[symbols.Index]Signature
+
+// parameters are required and their kinds are inferred from how each body
+// passes them to other signatures, including other scripted functions
+func (this Signatures) InferScripted(tbl *symbols.Table, funcs *optimizer.ScriptedFunctions) {
+	for _, fn := range funcs.All {
+		this[fn.Symbol.Index] = Fixed(make([]objects.ArgKind, len(fn.Params))...)
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, fn := range funcs.All {
+			if this.inferParams(tbl, fn) {
+				changed = true
+			}
+		}
+	}
+}
+
+func (this Signatures) inferParams(tbl *symbols.Table, fn optimizer.ScriptedFunction) bool {
+	params := this[fn.Symbol.Index].Params
+	positions := make(map[symbols.Index]int, len(fn.Params))
+	for i, param := range fn.Params {
+		positions[param.AsIndex()] = i
+	}
+
+	var changed bool
+	infer := ast.Visitor{
+		Invoke: func(node ast.Invoke, visit ast.Visiting) error {
+			sig, exists := this.of(tbl, node)
+			for i, arg := range node.Args {
+				visit(arg)
+				ident, isIdent := arg.(ast.Identifier)
+				if !exists || !isIdent {
+					continue
+				}
+				position, isParam := positions[ident.AsIndex()]
+				if isParam && params[position] == objects.ArgAny && sig.param(i) != objects.ArgAny {
+					params[position] = sig.param(i)
+					changed = true
+				}
+			}
+			return nil
+		},
+	}
+	if fn.Body != nil {
+		infer.Visit(fn.Body)
+	}
+	return changed
+}
+
+func (this Signatures) of(tbl *symbols.Table, node ast.Invoke) (Signature, bool) {
+	symbol := ast.LookUpNodeInTable(tbl, node.Target)
+	if symbol == nil {
+		return Signature{}, false
+	}
+	sig, exists := this[symbol.Index]
+	return sig, exists
+}
+
+// checks the arity and argument kinds of every invoke with a signature,
+// errors are located at the offending invoke
+func Checker(tbl *symbols.Table, sigs Signatures) ast.Visitor {
+	check := checker{tbl, sigs}
+	return ast.Visitor{Invoke: check.Invoke}
+}
+
+type checker struct {
+	tbl  *symbols.Table
+	sigs Signatures
+}
+
+func (this checker) Invoke(node ast.Invoke, visit ast.Visiting) error {
+	var errs []error
+	for _, arg := range node.Args {
+		errs = append(errs, visit(arg))
+	}
+
+	sig, exists := this.sigs.of(this.tbl, node)
+	if !exists {
+		return errors.Join(errs...)
+	}
+	name := ast.LookUpNodeInTable(this.tbl, node.Target).Name
+	if err := sig.arity(len(node.Args)); err != nil {
+		errs = append(errs, ast.ErrorAt(node, fmt.Errorf("%s %w", name, err)))
+	}
+	for i, arg := range node.Args {
+		expected := sig.param(i)
+		if found := KindOf(this.tbl, arg); !accepts(this.tbl, expected, found, arg) {
+			errs = append(errs, ast.ErrorAt(node, fmt.Errorf(
+				"argument %d of %s expects %s, found %s", i+1, name, expected, found)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func accepts(tbl *symbols.Table, expected, found objects.ArgKind, arg ast.Node) bool {
+	if expected == objects.ArgAny || found == objects.ArgAny || expected == found {
+		return true
+	}
+	// 'Token Name' is written for tokens whose names aren't identifiers
+	if str, isStr := arg.(ast.String); isStr && expected == objects.ArgToken {
+		return token(tbl, string(str)) != nil
+	}
+	return false
+}
+
+// the kind node produces, ArgAny when only known at runtime
+func KindOf(tbl *symbols.Table, node ast.Node) objects.ArgKind {
+	switch node := node.(type) {
+	case ast.Boolean, ast.Compare, ast.Invert, ast.Every, ast.AnyOf, ast.Invoke:
+		return objects.ArgBool
+	case ast.Number:
+		return objects.ArgNumber
+	case ast.String:
+		return objects.ArgString
+	case ast.Identifier:
+		symbol := tbl.LookUpByIndex(node.AsIndex())
+		switch symbol.Kind {
+		case symbols.TOKEN:
+			return objects.ArgToken
+		case symbols.FUNCTION, symbols.BUILT_IN_FUNCTION, symbols.COMPILER_FUNCTION, symbols.SCRIPTED_FUNC:
+			return objects.ArgBool
+		}
+		if token(tbl, symbol.Name) != nil {
+			return objects.ArgToken
+		}
+	}
+	return objects.ArgAny
+}
+
+// Nayrus_Love is written for Nayrus Love, see optimizer.PromoteTokens
+func token(tbl *symbols.Table, name string) *symbols.Sym {
+	for _, candidate := range []string{name, strings.ReplaceAll(name, "_", " ")} {
+		if symbol := tbl.LookUpByName(candidate); symbol != nil && symbol.Kind == symbols.TOKEN {
+			return symbol
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package typecheck
+
+import (
+	"errors"
+	"sudonters/libzootr/mido/ast"
+	"sudonters/libzootr/mido/objects"
+	"sudonters/libzootr/mido/optimizer"
+	"sudonters/libzootr/mido/symbols"
+	"sudonters/libzootr/ruleparser"
+	"testing"
+)
+
+func TestChecksInvokes(t *testing.T) {
+	grammar := ruleparser.NewRulesGrammar()
+	syms := symbols.NewTable()
+	syms.DeclareMany(symbols.TOKEN, []string{"Bow", "Nayrus Love"})
+	syms.Declare("bridge_tokens", symbols.SETTING)
+
+	token, number, boolean := objects.ArgToken, objects.ArgNumber, objects.ArgBool
+	sigs := Signatures{
+		syms.Declare("has", symbols.BUILT_IN_FUNCTION).Index:       Fixed(token, number),
+		syms.Declare("has_every", symbols.BUILT_IN_FUNCTION).Index: Variadic(token),
+		syms.Declare("is_adult", symbols.BUILT_IN_FUNCTION).Index:  Fixed(),
+		syms.Declare("can_live_dmg", symbols.COMPILER_FUNCTION).Index: {
+			Params: []objects.ArgKind{number, boolean, boolean}, Required: 1,
+		},
+	}
+	check := Checker(&syms, sigs)
+
+	valid := []string{
+		"has(Bow, 2)",
+		"has(Nayrus_Love, 1)",
+		"has('Nayrus Love', 1)",
+		"has(Bow, bridge_tokens)",
+		"has_every(Bow, Nayrus_Love)",
+		"has_every()",
+		"is_adult()",
+		"can_live_dmg(1) and can_live_dmg(0.5, True, not is_adult())",
+		"unchecked(1, 'two', Bow)",
+	}
+	for _, source := range valid {
+		node, err := ast.Parse(source, &syms, grammar)
+		if err != nil {
+			t.Fatalf("could not parse %q: %s", source, err)
+		}
+		if err := check.Visit(node); err != nil {
+			t.Errorf("expected %q to check: %s", source, err)
+		}
+	}
+
+	invalid := map[string]string{
+		"has(Bow)":                       "has expects 2 arguments, found 1",
+		"is_adult(Bow)":                  "is_adult expects 0 arguments, found 1",
+		"can_live_dmg(1, True, True, 1)": "can_live_dmg expects between 1 and 3 arguments, found 4",
+		"has(1, Bow)":                    "argument 1 of has expects token, found number\nargument 2 of has expects number, found token",
+		"has('Bombs', 1)":                "argument 1 of has expects token, found string",
+		"has_every(Bow, 'Bow', True)":    "argument 3 of has_every expects token, found bool",
+		"x or (Bow and has(Bow, 'one'))": "argument 2 of has expects number, found string",
+	}
+	for source, message := range invalid {
+		node, err := ast.Parse(source, &syms, grammar)
+		if err != nil {
+			t.Fatalf("could not parse %q: %s", source, err)
+		}
+		err = check.Visit(node)
+		if err == nil {
+			t.Errorf("expected %q to fail checking", source)
+			continue
+		}
+		if err.Error() != message {
+			t.Errorf("expected %q to fail with %q, found %q", source, message, err)
+		}
+		var located ast.NodeError
+		if !errors.As(err, &located) || located.Node.Kind() != ast.KindInvoke {
+			t.Errorf("expected %q to be located at an invoke, found %#v", source, err)
+		}
+	}
+}
+
+func TestInfersScriptedParams(t *testing.T) {
+	grammar := ruleparser.NewRulesGrammar()
+	syms := symbols.NewTable()
+	syms.Declare("Bow", symbols.TOKEN)
+	has := syms.Declare("has", symbols.BUILT_IN_FUNCTION)
+	sigs := Signatures{has.Index: Fixed(objects.ArgToken, objects.ArgNumber)}
+
+	funcs, err := optimizer.BuildScriptedFuncTable(&syms, grammar, map[string]string{
+		"can_use(item)":             "has(item, 1)",
+		"can_use_twice(item, more)": "can_use(item) and more",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigs.InferScripted(&syms, &funcs)
+
+	canUseTwice := sigs[syms.LookUpByName("can_use_twice").Index]
+	expected := Fixed(objects.ArgToken, objects.ArgAny)
+	if canUseTwice.Required != 2 || canUseTwice.Params[0] != expected.Params[0] || canUseTwice.Params[1] != expected.Params[1] {
+		t.Fatalf("expected %+v, found %+v", expected, canUseTwice)
+	}
+
+	node, err := ast.Parse("can_use_twice(2, True)", &syms, grammar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := Checker(&syms, sigs)
+	if err := check.Visit(node); err == nil {
+		t.Fatal("expected number passed as token to fail checking")
+	}
+}
@@ -1,5 +1,15 @@
 package mido
 
-import "sudonters/libzootr/mido/vm"
+import (
+	"sudonters/libzootr/mido/objects"
+	"sudonters/libzootr/mido/vm"
+)
 
 type VM = vm.VM
+type Closures = vm.Closures
+type Evaluator = vm.Evaluator
+type Profile = vm.Profile
+
+func NewProfile(defs []objects.BuiltInFunctionDef) *Profile {
+	return vm.NewProfile(defs)
+}
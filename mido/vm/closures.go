@@ -0,0 +1,374 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sudonters/libzootr/mido/code"
+	"sudonters/libzootr/mido/compiler"
+	"sudonters/libzootr/mido/objects"
+)
+
+// evaluates rules by compiling each tape into a tree of closures the first
+// time it is executed. Operands are decoded, constants are loaded and callees
+// are resolved once so evaluating a rule is only calls between closures.
+type Closures struct {
+	Objects *objects.Table
+	Funcs   objects.BuiltInFunctions
+	// CHK_QTY calls this directly instead of packing arguments for has
+	Has func(token objects.Addr32, qty float64) bool
+	// shared bodies called with CALL_SUB, see compiler.Subroutines
+	Subroutines []compiler.Bytecode
+	// subroutine results are memoized until State changes, nil disables
+	// memoization
+	State func() uint64
+
+	rules map[*byte]closure
+	subs  []closure
+	memo  *memo
+}
+
+var _ Evaluator = (*Closures)(nil)
+
+type closure func() (objects.Object, error)
+
+func (this *Closures) Execute(bytecode compiler.Bytecode) (objects.Object, error) {
+	if len(bytecode.Tape) == 0 {
+		return objects.Null, nil
+	}
+	if this.rules == nil {
+		this.rules = make(map[*byte]closure)
+	}
+	key := &bytecode.Tape[0]
+	rule, compiled := this.rules[key]
+	if !compiled {
+		var err error
+		if rule, err = this.compile(bytecode.Tape); err != nil {
+			return objects.Null, err
+		}
+		this.rules[key] = rule
+	}
+	answer, err := rule()
+	if err != nil {
+		return objects.Null, err
+	}
+	return answer, nil
+}
+
+func (this *Closures) Truthy(obj objects.Object) bool {
+	return truthy(obj)
+}
+
+// a value on the compile time stack, constants are kept so invokes can
+// resolve their callee and prepack their arguments
+type operand struct {
+	eval     closure
+	constant bool
+	value    objects.Object
+}
+
+func constant(value objects.Object) operand {
+	return operand{
+		eval:     func() (objects.Object, error) { return value, nil },
+		constant: true,
+		value:    value,
+	}
+}
+
+func dynamic(eval closure) operand {
+	return operand{eval: eval}
+}
+
+// conditional jumps to the same target, the compiler only emits these as
+// every or anyof operands jumping past the last operand
+type group struct {
+	jump     code.Op
+	target   int
+	depth    int
+	operands []closure
+}
+
+// tapes are decompiled back into their expression tree which only works
+// for the structured jumps compiler.Compile emits
+func (this *Closures) compile(tape code.Instructions) (closure, error) {
+	var stack []operand
+	var groups []group
+
+	pop := func(n int) ([]operand, error) {
+		if len(stack) < n {
+			return nil, fmt.Errorf("stack underflow")
+		}
+		popped := slices.Clone(stack[len(stack)-n:])
+		stack = stack[:len(stack)-n]
+		return popped, nil
+	}
+
+	for ip := 0; ; {
+		for len(groups) > 0 && groups[len(groups)-1].target == ip {
+			closing := groups[len(groups)-1]
+			groups = groups[:len(groups)-1]
+			if len(stack) != closing.depth+1 {
+				return nil, fmt.Errorf("0x%02X: unstructured jump", ip)
+			}
+			last, _ := pop(1)
+			stack = append(stack, dynamic(shortCircuit(closing.jump, append(closing.operands, last[0].eval))))
+		}
+		if ip >= len(tape) {
+			break
+		}
+
+		op := code.Op(tape[ip])
+		def, err := code.LookUp(op)
+		if err != nil {
+			return nil, fmt.Errorf("0x%02X: %w", ip, err)
+		}
+		operands := tape[ip+1:]
+		at := ip
+		ip++
+		for _, width := range def.Operands {
+			ip += width
+		}
+		if ip > len(tape) {
+			return nil, fmt.Errorf("0x%02X: %s is missing operands", at, def.Name)
+		}
+
+		var compiled operand
+		switch op {
+		case code.NOP:
+			continue
+		case code.ERR:
+			compiled = dynamic(func() (objects.Object, error) {
+				return objects.Null, errors.New("execution halted")
+			})
+		case code.PUSH_T:
+			compiled = constant(objects.PackedTrue)
+		case code.PUSH_F:
+			compiled = constant(objects.PackedFalse)
+		case code.PUSH_CONST, code.PUSH_FUNC, code.PUSH_PTR, code.PUSH_STR,
+			code.PUSH_CONST_W, code.PUSH_FUNC_W, code.PUSH_PTR_W, code.PUSH_STR_W:
+			index := objects.Index(code.ReadIndex(op, operands))
+			if int(index) >= len(this.Objects.Values()) {
+				return nil, fmt.Errorf("0x%02X: constant 0x%04X not found", at, index)
+			}
+			compiled = constant(this.Objects.AtIndex(index))
+		case code.INVERT:
+			inner, err := pop(1)
+			if err != nil {
+				return nil, fmt.Errorf("0x%02X: %w", at, err)
+			}
+			compiled = dynamic(invert(inner[0].eval))
+		case code.NEED_ALL, code.NEED_ANY:
+			popped, err := pop(int(code.ReadU16(operands)))
+			if err != nil {
+				return nil, fmt.Errorf("0x%02X: %w", at, err)
+			}
+			compiled = dynamic(reduce(op == code.NEED_ALL, evals(popped)))
+		case code.CHK_QTY, code.CHK_QTY_W:
+			compiled, err = this.chkqty(objects.Index(code.ReadIndex(op, operands)), float64(code.ReadU8(operands[def.Operands[0]:])))
+		case code.INVOKE:
+			var popped []operand
+			if popped, err = pop(int(code.ReadU16(operands)) + 1); err == nil {
+				compiled, err = this.invoke(popped[len(popped)-1], popped[:len(popped)-1])
+			}
+		case code.INVOKE_0, code.INVOKE_0_W:
+			index := objects.Index(code.ReadIndex(op, operands))
+			if int(index) >= len(this.Objects.Values()) {
+				return nil, fmt.Errorf("0x%02X: callee 0x%04X not found", at, index)
+			}
+			compiled, err = this.invoke(constant(this.Objects.AtIndex(index)), nil)
+		case code.CMP_EQ, code.CMP_NQ, code.CMP_LT:
+			popped, err := pop(2)
+			if err != nil {
+				return nil, fmt.Errorf("0x%02X: %w", at, err)
+			}
+			compiled = dynamic(this.compare(op, popped[1].eval, popped[0].eval))
+		case code.CALL_SUB:
+			compiled = dynamic(this.call(int(code.ReadU16(operands))))
+		case code.JMP_IF_FALSE, code.JMP_IF_TRUE:
+			target := int(code.ReadU16(operands))
+			popped, err := pop(1)
+			if err != nil {
+				return nil, fmt.Errorf("0x%02X: %w", at, err)
+			}
+			if target < ip || (len(groups) > 0 && target > groups[len(groups)-1].target) {
+				return nil, fmt.Errorf("0x%02X: unstructured jump", at)
+			}
+			if n := len(groups) - 1; n >= 0 && groups[n].target == target && groups[n].jump == op && groups[n].depth == len(stack) {
+				groups[n].operands = append(groups[n].operands, popped[0].eval)
+			} else {
+				groups = append(groups, group{op, target, len(stack), []closure{popped[0].eval}})
+			}
+			continue
+		default:
+			return nil, fmt.Errorf("0x%02X: %s cannot be compiled to a closure", at, def.Name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("0x%02X: %w", at, err)
+		}
+		stack = append(stack, compiled)
+	}
+
+	if len(groups) > 0 {
+		return nil, fmt.Errorf("0x%02X: jump beyond end of tape", groups[len(groups)-1].target)
+	}
+	// like VM only the top of the stack is answered
+	if len(stack) == 0 {
+		return func() (objects.Object, error) { return objects.Null, nil }, nil
+	}
+	return stack[len(stack)-1].eval, nil
+}
+
+// JMP_IF_FALSE groups are every, JMP_IF_TRUE groups are anyof. Like the
+// jumps they come from the deciding operand is answered as is.
+func shortCircuit(jump code.Op, operands []closure) closure {
+	decides := jump == code.JMP_IF_TRUE
+	last := len(operands) - 1
+	return func() (objects.Object, error) {
+		for _, operand := range operands[:last] {
+			answer, err := operand()
+			if err != nil || truthy(answer) == decides {
+				return answer, err
+			}
+		}
+		return operands[last]()
+	}
+}
+
+func invert(inner closure) closure {
+	return func() (objects.Object, error) {
+		answer, err := inner()
+		if err != nil {
+			return objects.Null, err
+		}
+		return objects.PackBool(!truthy(answer)), nil
+	}
+}
+
+// like NEED_ALL and NEED_ANY every operand is evaluated before reducing
+func reduce(all bool, operands []closure) closure {
+	return func() (objects.Object, error) {
+		reduction := all
+		for _, operand := range operands {
+			answer, err := operand()
+			if err != nil {
+				return objects.Null, err
+			}
+			if truthy(answer) != all {
+				reduction = !all
+			}
+		}
+		return objects.PackBool(reduction), nil
+	}
+}
+
+func evals(operands []operand) []closure {
+	closures := make([]closure, len(operands))
+	for i := range operands {
+		closures[i] = operands[i].eval
+	}
+	return closures
+}
+
+func (this *Closures) chkqty(index objects.Index, qty float64) (operand, error) {
+	if this.Has == nil {
+		return operand{}, fmt.Errorf("fastop 0x%02X not found in table", code.CHK_QTY)
+	}
+	if int(index) >= len(this.Objects.Values()) {
+		return operand{}, fmt.Errorf("token 0x%04X not found", index)
+	}
+	token, has := objects.UnpackPtr32(this.Objects.AtIndex(index)).Addr, this.Has
+	return dynamic(func() (objects.Object, error) {
+		return objects.PackBool(has(token, qty)), nil
+	}), nil
+}
+
+// callees are resolved now, arguments that are all constants are packed
+// once and otherwise evaluated into a buffer the closure owns
+func (this *Closures) invoke(callee operand, args []operand) (operand, error) {
+	if !callee.constant || callee.value.Type() != objects.STR_PTR32 {
+		return operand{}, errors.New("callee is not a constant function")
+	}
+	addr := int(objects.UnpackPtr32(callee.value).Addr)
+	if addr >= len(this.Funcs) || this.Funcs[addr] == nil {
+		return operand{}, fmt.Errorf("function 0x%04X not found", addr)
+	}
+	fn, tbl := this.Funcs[addr], this.Objects
+
+	packed := make([]objects.Object, len(args))
+	prepacked := true
+	for i := range args {
+		packed[i] = args[i].value
+		prepacked = prepacked && args[i].constant
+	}
+	if prepacked {
+		if len(packed) == 0 {
+			packed = nil
+		}
+		return dynamic(func() (objects.Object, error) {
+			return fn(tbl, packed)
+		}), nil
+	}
+
+	evaluate := evals(args)
+	return dynamic(func() (objects.Object, error) {
+		for i := range evaluate {
+			var err error
+			if packed[i], err = evaluate[i](); err != nil {
+				return objects.Null, err
+			}
+		}
+		return fn(tbl, packed)
+	}), nil
+}
+
+// evaluated right to left like the tape
+func (this *Closures) compare(op code.Op, lhs, rhs closure) closure {
+	tbl := this.Objects
+	return func() (objects.Object, error) {
+		right, err := rhs()
+		if err != nil {
+			return objects.Null, err
+		}
+		left, err := lhs()
+		if err != nil {
+			return objects.Null, err
+		}
+		return compare(tbl, op, left, right)
+	}
+}
+
+// bodies are compiled on their first call and memoized like VM memoizes them
+func (this *Closures) call(index int) closure {
+	return func() (objects.Object, error) {
+		if index >= len(this.Subroutines) {
+			return objects.Null, fmt.Errorf("subroutine 0x%04X not found", index)
+		}
+		if len(this.subs) != len(this.Subroutines) {
+			this.subs = make([]closure, len(this.Subroutines))
+		}
+		body := this.subs[index]
+		if body == nil {
+			var err error
+			if body, err = this.compile(this.Subroutines[index].Tape); err != nil {
+				return objects.Null, err
+			}
+			this.subs[index] = body
+		}
+		if this.State == nil {
+			return body()
+		}
+
+		state := this.State()
+		if this.memo == nil || this.memo.state != state || len(this.memo.results) != len(this.Subroutines) {
+			this.memo = &memo{state, make([]objects.Object, len(this.Subroutines))}
+		}
+		if answer := this.memo.results[index]; answer != objects.Null {
+			return answer, nil
+		}
+		answer, err := body()
+		if err == nil {
+			this.memo.results[index] = answer
+		}
+		return answer, err
+	}
+}
@@ -0,0 +1,191 @@
+package vm
+
+import (
+	"math/rand/v2"
+	"sudonters/libzootr/mido/ast"
+	"sudonters/libzootr/mido/compiler"
+	"sudonters/libzootr/mido/objects"
+	"sudonters/libzootr/mido/symbols"
+	"testing"
+)
+
+// random rules over a handful of builtins and tokens
+type corpus struct {
+	rng    *rand.Rand
+	funcs  map[string]*symbols.Sym
+	tokens []*symbols.Sym
+}
+
+func (this corpus) rule(depth int) ast.Node {
+	if depth == 0 {
+		return this.leaf()
+	}
+	switch this.rng.IntN(6) {
+	case 0:
+		return ast.Every(this.rules(depth-1, 1+this.rng.IntN(4)))
+	case 1:
+		return ast.AnyOf(this.rules(depth-1, 1+this.rng.IntN(4)))
+	case 2:
+		return ast.Invert{Inner: this.rule(depth - 1)}
+	default:
+		return this.leaf()
+	}
+}
+
+func (this corpus) rules(depth, n int) []ast.Node {
+	nodes := make([]ast.Node, n)
+	for i := range nodes {
+		nodes[i] = this.rule(depth)
+	}
+	return nodes
+}
+
+func (this corpus) token() ast.Node {
+	return ast.IdentifierFrom(this.tokens[this.rng.IntN(len(this.tokens))])
+}
+
+func (this corpus) invoke(name string, args ...ast.Node) ast.Node {
+	return ast.Invoke{Target: ast.IdentifierFrom(this.funcs[name]), Args: args}
+}
+
+func (this corpus) leaf() ast.Node {
+	operands := []ast.Node{ast.Number(1), ast.Number(2), ast.String("open"), ast.String("closed"), ast.Boolean(true), this.token()}
+	operand := func() ast.Node { return operands[this.rng.IntN(len(operands))] }
+	switch this.rng.IntN(8) {
+	case 0:
+		return ast.Boolean(this.rng.IntN(2) == 0)
+	case 1:
+		return this.invoke("has", this.token(), ast.Number(1+this.rng.IntN(3)))
+	case 2:
+		return this.invoke("has_anyof", this.token(), this.token())
+	case 3:
+		return this.invoke("has_every", this.token(), this.token())
+	case 4:
+		return this.invoke("is_adult")
+	case 5:
+		return this.invoke("is_child")
+	default:
+		// mixes types and unorderable operands so both evaluators also fail
+		return ast.Compare{LHS: operand(), RHS: operand(), Op: ast.CompareOp(1 + this.rng.IntN(3))}
+	}
+}
+
+func TestClosuresAgreeWithVM(t *testing.T) {
+	rng := rand.New(rand.NewPCG(0x5EED, 0x1EAF))
+	syms := symbols.NewTable()
+	objs := objects.NewTableBuilder()
+	corpus := corpus{rng: rng, funcs: make(map[string]*symbols.Sym)}
+	for i, def := range []objects.BuiltInFunctionDef{
+		{Name: "has", Params: 2},
+		{Name: "has_anyof", Params: -1},
+		{Name: "has_every", Params: -1},
+		{Name: "is_adult", Params: 0},
+		{Name: "is_child", Params: 0},
+	} {
+		symbol := syms.Declare(def.Name, symbols.BUILT_IN_FUNCTION)
+		objs.DefineFunction(symbol, objects.PackPtr32(objects.Ptr32{Tag: objects.PtrFunc, Addr: objects.Addr32(i)}), def)
+		corpus.funcs[def.Name] = symbol
+	}
+	for i, name := range []string{"Bow", "Hookshot", "Bombs", "Bottle"} {
+		symbol := syms.Declare(name, symbols.TOKEN)
+		objs.AssociateSymbol(symbol, objects.PackPtr32(objects.Ptr32{Tag: objects.PtrToken, Addr: objects.Addr32(i)}))
+		corpus.tokens = append(corpus.tokens, symbol)
+	}
+
+	nodes := corpus.rules(4, 2000)
+	subs := compiler.NewSubroutines()
+	for _, node := range nodes {
+		subs.Count(node)
+	}
+	var rules []compiler.Bytecode
+	defs := objs.FunctionTable()
+	for _, node := range nodes {
+		shared, err := compiler.CompileShared(node, &syms, &objs, defs, &subs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reduced, err := compiler.CompileReducing(node, &syms, &objs, defs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules = append(rules, shared, reduced)
+	}
+
+	var state uint64
+	var adult bool
+	owned := make([]float64, len(corpus.tokens))
+	has := func(token objects.Addr32, qty float64) bool { return owned[token] >= qty }
+	tokens := func(args []objects.Object) []objects.Addr32 {
+		addrs := make([]objects.Addr32, len(args))
+		for i := range args {
+			addrs[i] = objects.UnpackPtr32(args[i]).Addr
+		}
+		return addrs
+	}
+	funcs := objects.BuiltInFunctions{
+		func(_ *objects.Table, args []objects.Object) (objects.Object, error) {
+			return objects.PackBool(has(objects.UnpackPtr32(args[0]).Addr, objects.UnpackF64(args[1]))), nil
+		},
+		func(_ *objects.Table, args []objects.Object) (objects.Object, error) {
+			for _, token := range tokens(args) {
+				if has(token, 1) {
+					return objects.PackedTrue, nil
+				}
+			}
+			return objects.PackedFalse, nil
+		},
+		func(_ *objects.Table, args []objects.Object) (objects.Object, error) {
+			for _, token := range tokens(args) {
+				if !has(token, 1) {
+					return objects.PackedFalse, nil
+				}
+			}
+			return objects.PackedTrue, nil
+		},
+		func(*objects.Table, []objects.Object) (objects.Object, error) { return objects.PackBool(adult), nil },
+		func(*objects.Table, []objects.Object) (objects.Object, error) { return objects.PackBool(!adult), nil },
+	}
+
+	tbl := objects.TableFrom(&objs)
+	stateOf := func() uint64 { return state }
+	vm := VM{Objects: &tbl, Funcs: funcs, ChkQty: funcs[0], Subroutines: subs.Bodies, State: stateOf}
+	closures := Closures{Objects: &tbl, Funcs: funcs, Has: has, Subroutines: subs.Bodies, State: stateOf}
+
+	var failures int
+	for range 16 {
+		state++
+		adult = rng.IntN(2) == 0
+		for i := range owned {
+			owned[i] = float64(rng.IntN(3))
+		}
+		for i, rule := range rules {
+			expected, vmErr := vm.Execute(rule)
+			answer, closureErr := closures.Execute(rule)
+			if expected != answer || (vmErr == nil) != (closureErr == nil) {
+				failures++
+				t.Errorf("rule %d %s: vm answered %s %v, closures answered %s %v",
+					i/2, ast.Render(nodes[i/2]), expected, vmErr, answer, closureErr)
+			}
+			if failures > 10 {
+				t.FailNow()
+			}
+		}
+	}
+}
+
+func BenchmarkClosures(b *testing.B) {
+	node, syms, objs, calls := shortCircuitFixture(b, benchmarkRule)
+	bytecode, err := compiler.Compile(node, syms, objs, objs.FunctionTable())
+	if err != nil {
+		b.Fatal(err)
+	}
+	vm := shortCircuitVM(objs, calls)
+	closures := Closures{Objects: vm.Objects, Funcs: vm.Funcs}
+
+	b.ResetTimer()
+	for range b.N {
+		if _, err := closures.Execute(bytecode); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
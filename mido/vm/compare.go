@@ -0,0 +1,52 @@
+package vm
+
+import (
+	"fmt"
+	"sudonters/libzootr/mido/code"
+	"sudonters/libzootr/mido/objects"
+)
+
+// objects of different types are never equal and cannot be ordered. Strings
+// compare by content, booleans order false before true and pointers are only
+// equal to the same tag and address and cannot be ordered.
+func compare(tbl *objects.Table, op code.Op, lhs, rhs objects.Object) (objects.Object, error) {
+	lty, rty := lhs.Type(), rhs.Type()
+	switch op {
+	case code.CMP_EQ:
+		return objects.PackBool(lty == rty && equal(tbl, lty, lhs, rhs)), nil
+	case code.CMP_NQ:
+		return objects.PackBool(lty != rty || !equal(tbl, lty, lhs, rhs)), nil
+	case code.CMP_LT:
+		if lty != rty {
+			return objects.Null, fmt.Errorf("cannot order %s and %s", lty, rty)
+		}
+		less, err := less(tbl, lty, lhs, rhs)
+		return objects.PackBool(less), err
+	default:
+		return objects.Null, fmt.Errorf("not a comparison op: 0x%02X", op)
+	}
+}
+
+func equal(tbl *objects.Table, ty string, lhs, rhs objects.Object) bool {
+	switch ty {
+	case objects.STR_F64:
+		return objects.UnpackF64(lhs) == objects.UnpackF64(rhs)
+	case objects.STR_STR32:
+		return tbl.DerefString(lhs) == tbl.DerefString(rhs)
+	default:
+		return lhs == rhs
+	}
+}
+
+func less(tbl *objects.Table, ty string, lhs, rhs objects.Object) (bool, error) {
+	switch ty {
+	case objects.STR_F64:
+		return objects.UnpackF64(lhs) < objects.UnpackF64(rhs), nil
+	case objects.STR_STR32:
+		return tbl.DerefString(lhs) < tbl.DerefString(rhs), nil
+	case objects.STR_BOOL:
+		return !objects.UnpackBool(lhs) && objects.UnpackBool(rhs), nil
+	default:
+		return false, fmt.Errorf("%s does not support ordering", ty)
+	}
+}
@@ -0,0 +1,126 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+	"sudonters/libzootr/mido/code"
+	"sudonters/libzootr/mido/compiler"
+	"sudonters/libzootr/mido/objects"
+)
+
+// the instruction VM is about to execute, handed to VM.Debug
+type Step struct {
+	IP       int
+	Op       code.Defintion
+	Operands []int
+	// bottom first, only valid until the step returns
+	Stack    []objects.Object
+	Bytecode compiler.Bytecode
+	Objects  *objects.Table
+	// how many CALL_SUB bodies deep this step is
+	Depth int
+}
+
+func (this Step) String() string {
+	var line strings.Builder
+	fmt.Fprintf(&line, "%s0x%02X | %-12s", strings.Repeat("  ", this.Depth), this.IP, this.Op.Name)
+	for _, operand := range this.Operands {
+		if this.Op.Op.IsJump() {
+			fmt.Fprintf(&line, " | -> 0x%02X", operand)
+		} else {
+			fmt.Fprintf(&line, " | 0x%04X", operand)
+		}
+	}
+	return strings.TrimRight(line.String(), " ")
+}
+
+// the function INVOKE, INVOKE_0 or INVOKE_0_W is about to call
+func (this Step) Callee() (objects.Object, bool) {
+	switch this.Op.Op {
+	case code.INVOKE:
+		if len(this.Stack) > 0 {
+			return this.Stack[len(this.Stack)-1], true
+		}
+	case code.INVOKE_0, code.INVOKE_0_W:
+		return this.Objects.AtIndex(objects.Index(this.Operands[0])), true
+	}
+	return objects.Null, false
+}
+
+// renders obj with the name the bytecode gave it if it has one
+func (this Step) Describe(obj objects.Object) string {
+	switch obj.Type() {
+	case objects.STR_BOOL:
+		return fmt.Sprintf("%t", objects.UnpackBool(obj))
+	case objects.STR_F64:
+		return fmt.Sprintf("%v", objects.UnpackF64(obj))
+	case objects.STR_STR32:
+		return fmt.Sprintf("%q", this.Objects.DerefString(obj))
+	case objects.STR_PTR32:
+		for index, name := range this.Bytecode.Names {
+			if this.Objects.AtIndex(index) == obj {
+				return name
+			}
+		}
+		ptr := objects.UnpackPtr32(obj)
+		return fmt.Sprintf("%s(0x%04X)", ptr.Tag, ptr.Addr)
+	default:
+		return obj.Type()
+	}
+}
+
+// pauses before instructions that match a breakpoint or before every
+// instruction while stepping, Hook is installed as VM.Debug
+type Debugger struct {
+	Stepping bool
+	Ops      map[code.Op]bool
+	// addresses of PtrFunc objects, matched against every invoke
+	Funcs map[objects.Addr32]bool
+	// sees every step, returning an error halts execution
+	Trace func(Step) error
+	Pause func(Step) error
+}
+
+func (this *Debugger) Hook(step Step) error {
+	if this.Trace != nil {
+		if err := this.Trace(step); err != nil {
+			return err
+		}
+	}
+	if this.Pause != nil && (this.Stepping || this.Breaks(step)) {
+		return this.Pause(step)
+	}
+	return nil
+}
+
+func (this *Debugger) Breaks(step Step) bool {
+	if this.Ops[step.Op.Op] {
+		return true
+	}
+	callee, invokes := step.Callee()
+	return invokes && callee.Type() == objects.STR_PTR32 && this.Funcs[objects.UnpackPtr32(callee).Addr]
+}
+
+func (this *execution) step(ip int, depth int, tbl *objects.Table) Step {
+	def, _ := code.LookUp(code.Op(this.code.Tape[ip]))
+	step := Step{
+		IP: ip, Op: def,
+		Stack:    this.stack.slice(0, this.stack.ptr),
+		Bytecode: this.code,
+		Objects:  tbl,
+		Depth:    depth,
+	}
+	operands := this.code.Tape[ip+1:]
+	for _, width := range def.Operands {
+		switch width {
+		case 1:
+			step.Operands = append(step.Operands, int(code.ReadU8(operands)))
+		case 2:
+			step.Operands = append(step.Operands, int(code.ReadU16(operands)))
+		case 4:
+			step.Operands = append(step.Operands, int(code.ReadU32(operands)))
+		}
+		operands = operands[width:]
+	}
+	return step
+}
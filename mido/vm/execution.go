@@ -24,11 +24,21 @@ func (this *execution) readu16() uint16 {
 	return u16
 }
 
+func (this *execution) readu32() uint32 {
+	u32 := code.ReadU32(this.code.Tape[this.ip:])
+	this.ip += 4
+	return u32
+}
+
 func (this *execution) endOfTape() int {
 	return len(this.code.Tape)
 }
 
-func (this *execution) readIndex() objects.Index {
+// wide ops index with a u32 operand
+func (this *execution) readIndex(op code.Op) objects.Index {
+	if op.IsWide() {
+		return objects.Index(this.readu32())
+	}
 	return objects.Index(this.readu16())
 }
 
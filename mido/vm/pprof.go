@@ -0,0 +1,130 @@
+package vm
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+)
+
+// writes the profile as gzipped profile.proto so go tool pprof can read it.
+// Each rule is a sample with itself as the only frame and each builtin a
+// rule called is a sample with the builtin framed above the rule, every
+// sample is labelled with the rule's name.
+func (this *Profile) WritePprof(w io.Writer) error {
+	var profile protobuf
+	strings := map[string]int64{"": 0}
+	order := []string{""}
+	str := func(s string) int64 {
+		index, exists := strings[s]
+		if !exists {
+			index = int64(len(order))
+			strings[s] = index
+			order = append(order, s)
+		}
+		return index
+	}
+
+	for _, sampled := range [][2]string{
+		{"executions", "count"}, {"instructions", "count"},
+		{"builtin_calls", "count"}, {"wall", "nanoseconds"},
+	} {
+		var valueType protobuf
+		valueType.int(1, str(sampled[0]))
+		valueType.int(2, str(sampled[1]))
+		profile.message(1, valueType)
+	}
+
+	// functions and locations share ids, one per frame name
+	frames := make(map[string]uint64)
+	frame := func(name string) uint64 {
+		id, exists := frames[name]
+		if !exists {
+			id = uint64(len(frames) + 1)
+			frames[name] = id
+
+			var function, location, line protobuf
+			function.uint(1, id)
+			function.int(2, str(name))
+			function.int(3, str(name))
+			profile.message(5, function)
+
+			line.uint(1, id)
+			location.uint(1, id)
+			location.message(4, line)
+			profile.message(4, location)
+		}
+		return id
+	}
+
+	sample := func(rule string, stack []uint64, values [4]int64) {
+		var sample, label protobuf
+		sample.packedUints(1, stack)
+		sample.packedInts(2, values[:])
+		label.int(1, str("rule"))
+		label.int(2, str(rule))
+		sample.message(3, label)
+		profile.message(2, sample)
+	}
+
+	for _, rule := range this.Rules() {
+		self := frame(rule.Name)
+		sample(rule.Name, []uint64{self}, [4]int64{int64(rule.Executions), int64(rule.Instructions), 0, int64(rule.Wall)})
+		for _, builtin := range sortedByCount(rule.Builtins) {
+			sample(rule.Name, []uint64{frame(builtin + "()"), self}, [4]int64{0, 0, int64(rule.Builtins[builtin]), 0})
+		}
+	}
+
+	for _, s := range order {
+		profile.bytes(6, []byte(s))
+	}
+
+	zipped := gzip.NewWriter(w)
+	if _, err := zipped.Write(profile); err != nil {
+		return err
+	}
+	return zipped.Close()
+}
+
+// just enough of the protobuf wire format to write profile.proto
+type protobuf []byte
+
+func (this *protobuf) varint(v uint64) {
+	*this = binary.AppendUvarint(*this, v)
+}
+
+func (this *protobuf) uint(field int, v uint64) {
+	if v != 0 {
+		this.varint(uint64(field) << 3)
+		this.varint(v)
+	}
+}
+
+func (this *protobuf) int(field int, v int64) {
+	this.uint(field, uint64(v))
+}
+
+func (this *protobuf) bytes(field int, b []byte) {
+	this.varint(uint64(field)<<3 | 2)
+	this.varint(uint64(len(b)))
+	*this = append(*this, b...)
+}
+
+func (this *protobuf) message(field int, msg protobuf) {
+	this.bytes(field, msg)
+}
+
+func (this *protobuf) packedUints(field int, vs []uint64) {
+	var packed protobuf
+	for _, v := range vs {
+		packed.varint(v)
+	}
+	this.bytes(field, packed)
+}
+
+func (this *protobuf) packedInts(field int, vs []int64) {
+	var packed protobuf
+	for _, v := range vs {
+		packed.varint(uint64(v))
+	}
+	this.bytes(field, packed)
+}
@@ -0,0 +1,228 @@
+package vm
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// the parts of profile.proto WritePprof writes, read back without sharing
+// any of its encoder
+type pprofProfile struct {
+	SampleTypes [][2]string
+	Samples     []pprofSample
+}
+
+type pprofSample struct {
+	// leaf first
+	Stack  []string
+	Values []int64
+	Labels map[string]string
+}
+
+func readPprof(r io.Reader) (pprofProfile, error) {
+	var profile pprofProfile
+	unzipped, err := gzip.NewReader(r)
+	if err != nil {
+		return profile, err
+	}
+	raw, err := io.ReadAll(unzipped)
+	if err != nil {
+		return profile, err
+	}
+	fields, err := protoFields(raw)
+	if err != nil {
+		return profile, err
+	}
+
+	var strings []string
+	for _, field := range fields {
+		if field.num == 6 {
+			strings = append(strings, string(field.bytes))
+		}
+	}
+	str := func(index uint64) (string, error) {
+		if index >= uint64(len(strings)) {
+			return "", fmt.Errorf("string %d is past the end of the table", index)
+		}
+		return strings[index], nil
+	}
+
+	functions := make(map[uint64]string)
+	locations := make(map[uint64]uint64)
+	var sampleTypes, samples []protoField
+	for _, field := range fields {
+		var err error
+		switch field.num {
+		case 1:
+			sampleTypes = append(sampleTypes, field)
+		case 2:
+			samples = append(samples, field)
+		case 4:
+			var id, function uint64
+			id, err = readMessage(field, func(inner protoField) error {
+				if inner.num == 4 {
+					_, err := readMessage(inner, func(line protoField) error {
+						if line.num == 1 {
+							function = line.varint
+						}
+						return nil
+					})
+					return err
+				}
+				return nil
+			})
+			locations[id] = function
+		case 5:
+			var id, name uint64
+			id, err = readMessage(field, func(inner protoField) error {
+				if inner.num == 2 {
+					name = inner.varint
+				}
+				return nil
+			})
+			functions[id], err = str(name)
+		}
+		if err != nil {
+			return profile, err
+		}
+	}
+
+	for _, field := range sampleTypes {
+		var kind, unit string
+		_, err := readMessage(field, func(inner protoField) error {
+			var err error
+			switch inner.num {
+			case 1:
+				kind, err = str(inner.varint)
+			case 2:
+				unit, err = str(inner.varint)
+			}
+			return err
+		})
+		if err != nil {
+			return profile, err
+		}
+		profile.SampleTypes = append(profile.SampleTypes, [2]string{kind, unit})
+	}
+
+	for _, field := range samples {
+		sample := pprofSample{Labels: make(map[string]string)}
+		_, err := readMessage(field, func(inner protoField) error {
+			switch inner.num {
+			case 1:
+				ids, err := inner.varints()
+				for _, id := range ids {
+					function, exists := functions[locations[id]]
+					if !exists {
+						return fmt.Errorf("location %d has no function", id)
+					}
+					sample.Stack = append(sample.Stack, function)
+				}
+				return err
+			case 2:
+				values, err := inner.varints()
+				for _, value := range values {
+					sample.Values = append(sample.Values, int64(value))
+				}
+				return err
+			case 3:
+				var key, value string
+				_, err := readMessage(inner, func(label protoField) error {
+					var err error
+					switch label.num {
+					case 1:
+						key, err = str(label.varint)
+					case 2:
+						value, err = str(label.varint)
+					}
+					return err
+				})
+				sample.Labels[key] = value
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return profile, err
+		}
+		profile.Samples = append(profile.Samples, sample)
+	}
+	return profile, nil
+}
+
+type protoField struct {
+	num    int
+	varint uint64
+	bytes  []byte
+	packed bool
+}
+
+func protoFields(msg []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return nil, errors.New("malformed field key")
+		}
+		msg = msg[n:]
+		field := protoField{num: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			field.varint, n = binary.Uvarint(msg)
+			if n <= 0 {
+				return nil, fmt.Errorf("field %d: malformed varint", field.num)
+			}
+			msg = msg[n:]
+		case 2:
+			size, n := binary.Uvarint(msg)
+			if n <= 0 || size > uint64(len(msg)-n) {
+				return nil, fmt.Errorf("field %d: truncated", field.num)
+			}
+			field.bytes, field.packed = msg[n:n+int(size)], true
+			msg = msg[n+int(size):]
+		default:
+			return nil, fmt.Errorf("field %d: unexpected wire type %d", field.num, key&7)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// hands each of the embedded message's fields to read and returns its id,
+// field 1
+func readMessage(field protoField, read func(protoField) error) (uint64, error) {
+	inner, err := protoFields(field.bytes)
+	if err != nil {
+		return 0, fmt.Errorf("field %d: %w", field.num, err)
+	}
+	var id uint64
+	for _, each := range inner {
+		if each.num == 1 && !each.packed {
+			id = each.varint
+		}
+		if err := read(each); err != nil {
+			return id, err
+		}
+	}
+	return id, nil
+}
+
+// a repeated varint, either packed or a single element
+func (this protoField) varints() ([]uint64, error) {
+	if !this.packed {
+		return []uint64{this.varint}, nil
+	}
+	var vs []uint64
+	for packed := this.bytes; len(packed) > 0; {
+		v, n := binary.Uvarint(packed)
+		if n <= 0 {
+			return nil, fmt.Errorf("field %d: malformed packed varint", this.num)
+		}
+		vs = append(vs, v)
+		packed = packed[n:]
+	}
+	return vs, nil
+}
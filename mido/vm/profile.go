@@ -0,0 +1,140 @@
+package vm
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sudonters/libzootr/mido/objects"
+	"text/tabwriter"
+	"time"
+)
+
+// opt in execution counts, install as VM.Profile and name each rule with
+// Rule before executing it. Subroutines are attributed to the rule that
+// called them and nothing is recorded until the first rule is named.
+type Profile struct {
+	// indexed by PtrFunc address
+	Funcs []string
+
+	rules   map[uint32]*RuleProfile
+	current *RuleProfile
+}
+
+type RuleProfile struct {
+	Entity       uint32
+	Name         string
+	Executions   int
+	Instructions int
+	// CHK_QTY is counted as has, the call it stands in for
+	Builtins map[string]int
+	Wall     time.Duration
+}
+
+func NewProfile(defs []objects.BuiltInFunctionDef) *Profile {
+	profile := &Profile{Funcs: make([]string, len(defs))}
+	for addr, def := range defs {
+		profile.Funcs[addr] = def.Name
+	}
+	return profile
+}
+
+// attributes everything executed until the next call to entity
+func (this *Profile) Rule(entity uint32, name string) {
+	if this.rules == nil {
+		this.rules = make(map[uint32]*RuleProfile)
+	}
+	rule, exists := this.rules[entity]
+	if !exists {
+		rule = &RuleProfile{Entity: entity, Name: name, Builtins: make(map[string]int)}
+		this.rules[entity] = rule
+	}
+	this.current = rule
+}
+
+// most wall time first
+func (this *Profile) Rules() []RuleProfile {
+	rules := make([]RuleProfile, 0, len(this.rules))
+	for _, rule := range this.rules {
+		rules = append(rules, *rule)
+	}
+	slices.SortFunc(rules, func(a, b RuleProfile) int {
+		return cmp.Or(cmp.Compare(b.Wall, a.Wall), cmp.Compare(b.Instructions, a.Instructions), cmp.Compare(a.Name, b.Name))
+	})
+	return rules
+}
+
+// calls per builtin across every rule
+func (this *Profile) Builtins() map[string]int {
+	calls := make(map[string]int)
+	for _, rule := range this.rules {
+		for name, count := range rule.Builtins {
+			calls[name] += count
+		}
+	}
+	return calls
+}
+
+func (this *Profile) WriteReport(w io.Writer) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "wall\texecs\tinstrs\tavg wall\t  rule")
+	for _, rule := range this.Rules() {
+		var avg time.Duration
+		if rule.Executions > 0 {
+			avg = rule.Wall / time.Duration(rule.Executions)
+		}
+		fmt.Fprintf(table, "%s\t%d\t%d\t%s\t  %s\n", rule.Wall, rule.Executions, rule.Instructions, avg, rule.Name)
+	}
+	fmt.Fprintln(table)
+
+	calls := this.Builtins()
+	fmt.Fprintln(table, "calls\t  builtin")
+	for _, name := range sortedByCount(calls) {
+		fmt.Fprintf(table, "%d\t  %s\n", calls[name], name)
+	}
+	return table.Flush()
+}
+
+func sortedByCount(counts map[string]int) []string {
+	return slices.SortedFunc(maps.Keys(counts), func(a, b string) int {
+		return cmp.Or(cmp.Compare(counts[b], counts[a]), cmp.Compare(a, b))
+	})
+}
+
+func (this *Profile) executed(began time.Time) {
+	if this.current != nil {
+		this.current.Executions++
+		this.current.Wall += time.Since(began)
+	}
+}
+
+func (this *Profile) retired() {
+	if this.current != nil {
+		this.current.Instructions++
+	}
+}
+
+func (this *Profile) called(callee objects.Object) {
+	if this.current == nil {
+		return
+	}
+	this.current.Builtins[this.funcName(callee)]++
+}
+
+func (this *Profile) checkedQty() {
+	if this.current != nil {
+		this.current.Builtins["has"]++
+	}
+}
+
+func (this *Profile) funcName(callee objects.Object) string {
+	if callee.Type() != objects.STR_PTR32 {
+		return callee.Type()
+	}
+	addr := int(objects.UnpackPtr32(callee).Addr)
+	if addr < len(this.Funcs) && this.Funcs[addr] != "" {
+		return this.Funcs[addr]
+	}
+	return fmt.Sprintf("func(0x%04X)", addr)
+}
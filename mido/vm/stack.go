@@ -44,3 +44,10 @@ func (this *stack[T]) pop() T {
 	this.ptr--
 	return this.items[this.ptr]
 }
+
+func (this *stack[T]) peek() T {
+	if this.ptr == 0 {
+		panic(ErrStackEmpty)
+	}
+	return this.items[this.ptr-1]
+}
@@ -9,21 +9,52 @@ import (
 	"sudonters/libzootr/mido/code"
 	"sudonters/libzootr/mido/compiler"
 	"sudonters/libzootr/mido/objects"
+	"time"
 
 	"github.com/etc-sudonters/substrate/dontio"
 )
 
+// runs compiled rules, VM interprets each tape while Closures compiles them
+// into Go closures the first time they are executed
+type Evaluator interface {
+	Execute(compiler.Bytecode) (objects.Object, error)
+	Truthy(objects.Object) bool
+}
+
+var _ Evaluator = (*VM)(nil)
+
 type VM struct {
 	Objects *objects.Table
 	Funcs   objects.BuiltInFunctions
 	Std     *dontio.Std
 	ChkQty  objects.BuiltInFunction
+	// shared bodies called with CALL_SUB, see compiler.Subroutines
+	Subroutines []compiler.Bytecode
+	// subroutine results are memoized until State changes, nil disables
+	// memoization
+	State func() uint64
+	// called before every instruction, returning an error halts execution.
+	// See Debugger
+	Debug func(Step) error
+	// counts what each rule executes, nil disables profiling
+	Profile *Profile
+
+	memo  *memo
+	depth int
+}
+
+type memo struct {
+	state   uint64
+	results []objects.Object
 }
 
 func (this *VM) Execute(bytecode compiler.Bytecode) (obj objects.Object, err error) {
 	result := objects.Null
-	unit := execution{0, bytecode, newstack[objects.Object](256)}
+	unit := execution{0, bytecode, newstack[objects.Object](code.MaxStack)}
 	EOT := unit.endOfTape()
+	if this.Profile != nil && this.depth == 0 {
+		defer this.Profile.executed(time.Now())
+	}
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -39,6 +70,14 @@ func (this *VM) Execute(bytecode compiler.Bytecode) (obj objects.Object, err err
 
 loop:
 	for unit.ip < EOT {
+		if this.Debug != nil {
+			if err = this.Debug(unit.step(unit.ip, this.depth, this.Objects)); err != nil {
+				break loop
+			}
+		}
+		if this.Profile != nil {
+			this.Profile.retired()
+		}
 		thisOp := unit.readOp()
 		switch thisOp {
 		case code.NOP:
@@ -50,8 +89,9 @@ loop:
 			unit.stack.push(objects.PackedTrue)
 		case code.PUSH_F:
 			unit.stack.push(objects.PackedFalse)
-		case code.PUSH_CONST, code.PUSH_FUNC, code.PUSH_PTR, code.PUSH_STR:
-			index := unit.readIndex()
+		case code.PUSH_CONST, code.PUSH_FUNC, code.PUSH_PTR, code.PUSH_STR,
+			code.PUSH_CONST_W, code.PUSH_FUNC_W, code.PUSH_PTR_W, code.PUSH_STR_W:
+			index := unit.readIndex(thisOp)
 			unit.stack.push(this.Objects.AtIndex(index))
 		case code.INVERT:
 			obj := unit.stack.pop()
@@ -85,6 +125,9 @@ loop:
 			obj := unit.stack.pop()
 			count := int(unit.readu16())
 			args := unit.stackargs(count)
+			if this.Profile != nil {
+				this.Profile.called(obj)
+			}
 			answer, err = this.Funcs.Call(this.Objects, obj, args)
 			unit.stack.popN(count)
 			if err != nil {
@@ -93,9 +136,12 @@ loop:
 			if answer != objects.Null {
 				unit.stack.push(answer)
 			}
-		case code.INVOKE_0:
-			index := unit.readIndex()
+		case code.INVOKE_0, code.INVOKE_0_W:
+			index := unit.readIndex(thisOp)
 			obj := this.Objects.AtIndex(index)
+			if this.Profile != nil {
+				this.Profile.called(obj)
+			}
 			answer, err := this.Funcs.Call(this.Objects, obj, nil)
 			if err != nil {
 				break loop
@@ -103,15 +149,18 @@ loop:
 			if answer != objects.Null {
 				unit.stack.push(answer)
 			}
-		case code.CHK_QTY:
+		case code.CHK_QTY, code.CHK_QTY_W:
 			if this.ChkQty == nil {
 				err = fmt.Errorf("fastop 0x%02X not found in table", thisOp)
 				break loop
 			}
 
-			index := unit.readIndex()
+			index := unit.readIndex(thisOp)
 			qty := unit.readu8()
 			obj := this.Objects.AtIndex(index)
+			if this.Profile != nil {
+				this.Profile.checkedQty()
+			}
 			answer, err := this.ChkQty(this.Objects, []objects.Object{
 				obj, objects.PackF64(float64(qty)),
 			})
@@ -119,9 +168,32 @@ loop:
 				break loop
 			}
 			unit.stack.push(answer)
+		case code.CALL_SUB:
+			index := int(unit.readu16())
+			answer, subErr := this.call(index)
+			if subErr != nil {
+				err = subErr
+				break loop
+			}
+			unit.stack.push(answer)
+		case code.JMP:
+			unit.ip = int(unit.readu16())
+		case code.JMP_IF_FALSE, code.JMP_IF_TRUE:
+			target := int(unit.readu16())
+			if this.Truthy(unit.stack.peek()) == (thisOp == code.JMP_IF_TRUE) {
+				unit.ip = target
+			} else {
+				unit.stack.pop()
+			}
 		case code.CMP_EQ, code.CMP_NQ, code.CMP_LT:
-			err = fmt.Errorf("runtime comparison not implemented")
-			break loop
+			lhs := unit.stack.pop()
+			rhs := unit.stack.pop()
+			answer, cmpErr := compare(this.Objects, thisOp, lhs, rhs)
+			if cmpErr != nil {
+				err = cmpErr
+				break loop
+			}
+			unit.stack.push(answer)
 		default:
 			err = fmt.Errorf("unrecognized op: 0x%02x", thisOp)
 			break loop
@@ -134,7 +206,36 @@ loop:
 	return result, err
 }
 
+func (this *VM) call(index int) (objects.Object, error) {
+	if index >= len(this.Subroutines) {
+		return objects.Null, fmt.Errorf("subroutine 0x%04X not found", index)
+	}
+	this.depth++
+	defer func() { this.depth-- }()
+	if this.State == nil {
+		return this.Execute(this.Subroutines[index])
+	}
+
+	state := this.State()
+	if this.memo == nil || this.memo.state != state || len(this.memo.results) != len(this.Subroutines) {
+		this.memo = &memo{state, make([]objects.Object, len(this.Subroutines))}
+	}
+	if answer := this.memo.results[index]; answer != objects.Null {
+		return answer, nil
+	}
+
+	answer, err := this.Execute(this.Subroutines[index])
+	if err == nil {
+		this.memo.results[index] = answer
+	}
+	return answer, err
+}
+
 func (this *VM) Truthy(obj objects.Object) bool {
+	return truthy(obj)
+}
+
+func truthy(obj objects.Object) bool {
 	if obj != objects.PackedTrue && obj != objects.PackedFalse {
 		slog.Warn("truthy checked non-boolean", "kind", obj.Type(), "obj", obj.String())
 	}
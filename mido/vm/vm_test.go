@@ -0,0 +1,465 @@
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"sudonters/libzootr/mido/ast"
+	"sudonters/libzootr/mido/code"
+	"sudonters/libzootr/mido/compiler"
+	"sudonters/libzootr/mido/objects"
+	"sudonters/libzootr/mido/symbols"
+	"sudonters/libzootr/ruleparser"
+	"testing"
+	"time"
+)
+
+func TestSharedSubroutinesEvaluateOncePerState(t *testing.T) {
+	grammar := ruleparser.NewRulesGrammar()
+	syms := symbols.NewTable()
+	objs := objects.NewTableBuilder()
+	hasAnyOf := syms.Declare("has_anyof", symbols.BUILT_IN_FUNCTION)
+	objs.DefineFunction(hasAnyOf, objects.PackPtr32(objects.Ptr32{Tag: objects.PtrFunc}), objects.BuiltInFunctionDef{
+		Name: "has_anyof", Params: -1,
+	})
+	for i, name := range []string{"Bow", "Hookshot", "Bombs"} {
+		objs.AssociateSymbol(syms.Declare(name, symbols.TOKEN), objects.PackPtr32(objects.Ptr32{
+			Tag: objects.PtrToken, Addr: objects.Addr32(i),
+		}))
+	}
+
+	subs := compiler.NewSubroutines()
+	rules := make([]ast.Node, 3)
+	for i, source := range []string{
+		"has_anyof(Bow, Hookshot) and has_anyof(Bombs)",
+		"has_anyof(Bombs) or has_anyof(Bow, Hookshot)",
+		"has_anyof(Bow, Hookshot)",
+	} {
+		var err error
+		if rules[i], err = ast.Parse(source, &syms, grammar); err != nil {
+			t.Fatal(err)
+		}
+		subs.Count(rules[i])
+	}
+
+	tapes := make([]compiler.Bytecode, len(rules))
+	funcs := objs.FunctionTable()
+	for i := range rules {
+		var err error
+		if tapes[i], err = compiler.CompileShared(rules[i], &syms, &objs, funcs, &subs); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sharing := subs.Sharing()
+	if sharing.Subroutines != 2 || sharing.Calls != 5 || sharing.Ratio() <= 1 {
+		t.Fatalf("expected 2 subroutines called 5 times, found %+v", sharing)
+	}
+	if expected := code.Make(code.CALL_SUB, 0); !bytes.Equal(tapes[2].Tape, expected) {
+		t.Fatalf("expected rule to be a single call, found\n%s", code.DisassembleToString(tapes[2].Tape))
+	}
+
+	var calls int
+	var state uint64
+	owned := map[objects.Addr32]bool{}
+	tbl := objects.TableFrom(&objs)
+	vm := VM{
+		Objects: &tbl,
+		Funcs: objects.BuiltInFunctions{func(_ *objects.Table, args []objects.Object) (objects.Object, error) {
+			calls++
+			for _, arg := range args {
+				if owned[objects.UnpackPtr32(arg).Addr] {
+					return objects.PackedTrue, nil
+				}
+			}
+			return objects.PackedFalse, nil
+		}},
+		Subroutines: subs.Bodies,
+		State:       func() uint64 { return state },
+	}
+
+	evaluate := func(expected ...bool) {
+		t.Helper()
+		for i := range tapes {
+			answer, err := vm.Execute(tapes[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if vm.Truthy(answer) != expected[i] {
+				t.Fatalf("rule %d: expected %t", i, expected[i])
+			}
+		}
+	}
+
+	evaluate(false, false, false)
+	if calls != 2 {
+		t.Fatalf("expected each subroutine to be evaluated once, found %d calls", calls)
+	}
+
+	owned[1] = true
+	state++
+	evaluate(false, true, true)
+	if calls != 4 {
+		t.Fatalf("expected new state to evaluate subroutines again, found %d calls", calls)
+	}
+}
+
+func TestCompares(t *testing.T) {
+	syms := symbols.NewTable()
+	objs := objects.NewTableBuilder()
+	bow, hookshot := syms.Declare("Bow", symbols.TOKEN), syms.Declare("Hookshot", symbols.TOKEN)
+	objs.AssociateSymbol(bow, objects.PackPtr32(objects.Ptr32{Tag: objects.PtrToken, Addr: 1}))
+	objs.AssociateSymbol(hookshot, objects.PackPtr32(objects.Ptr32{Tag: objects.PtrToken, Addr: 2}))
+
+	compare := func(lhs ast.Node, op ast.CompareOp, rhs ast.Node) ast.Node {
+		return ast.Compare{LHS: lhs, RHS: rhs, Op: op}
+	}
+	one, two := ast.Number(1), ast.Number(2)
+	open, closed := ast.String("open"), ast.String("closed")
+	yes, no := ast.Boolean(true), ast.Boolean(false)
+	ptrBow, ptrHookshot := ast.IdentifierFrom(bow), ast.IdentifierFrom(hookshot)
+
+	expected := []struct {
+		node     ast.Node
+		expected bool
+	}{
+		{compare(one, ast.CompareEq, one), true},
+		{compare(one, ast.CompareEq, two), false},
+		{compare(one, ast.CompareNq, two), true},
+		{compare(one, ast.CompareNq, one), false},
+		{compare(one, ast.CompareLt, two), true},
+		{compare(two, ast.CompareLt, one), false},
+		{compare(open, ast.CompareEq, open), true},
+		{compare(open, ast.CompareEq, closed), false},
+		{compare(open, ast.CompareNq, closed), true},
+		{compare(closed, ast.CompareLt, open), true},
+		{compare(open, ast.CompareLt, closed), false},
+		{compare(yes, ast.CompareEq, yes), true},
+		{compare(yes, ast.CompareNq, no), true},
+		{compare(no, ast.CompareLt, yes), true},
+		{compare(yes, ast.CompareLt, no), false},
+		{compare(ptrBow, ast.CompareEq, ptrBow), true},
+		{compare(ptrBow, ast.CompareEq, ptrHookshot), false},
+		{compare(ptrBow, ast.CompareNq, ptrHookshot), true},
+		// mixed types are never equal
+		{compare(one, ast.CompareEq, yes), false},
+		{compare(one, ast.CompareNq, ast.String("1")), true},
+		{compare(ptrBow, ast.CompareEq, ast.String("Bow")), false},
+		{compare(no, ast.CompareNq, ast.Number(0)), true},
+	}
+
+	execute := func(node ast.Node) (objects.Object, error) {
+		bytecode, err := compiler.Compile(node, &syms, &objs, objs.FunctionTable())
+		if err != nil {
+			t.Fatal(err)
+		}
+		tbl := objects.TableFrom(&objs)
+		vm := VM{Objects: &tbl}
+		return vm.Execute(bytecode)
+	}
+
+	for _, test := range expected {
+		answer, err := execute(test.node)
+		if err != nil {
+			t.Errorf("%s: %s", ast.Render(test.node), err)
+			continue
+		}
+		if answer != objects.PackBool(test.expected) {
+			t.Errorf("%s: expected %t", ast.Render(test.node), test.expected)
+		}
+	}
+
+	for _, unordered := range []ast.Node{
+		compare(ptrBow, ast.CompareLt, ptrHookshot),
+		compare(one, ast.CompareLt, open),
+		compare(yes, ast.CompareLt, one),
+	} {
+		if _, err := execute(unordered); err == nil {
+			t.Errorf("%s: expected ordering to fail", ast.Render(unordered))
+		}
+	}
+}
+
+// is_adult() is false, every other builtin counts its calls
+func shortCircuitFixture(tb testing.TB, source string) (ast.Node, *symbols.Table, *objects.Builder, *int) {
+	syms := symbols.NewTable()
+	objs := objects.NewTableBuilder()
+	for i, name := range []string{"is_adult", "is_child", "has_bottle"} {
+		symbol := syms.Declare(name, symbols.BUILT_IN_FUNCTION)
+		objs.DefineFunction(symbol, objects.PackPtr32(objects.Ptr32{Tag: objects.PtrFunc, Addr: objects.Addr32(i)}),
+			objects.BuiltInFunctionDef{Name: name})
+	}
+	node, err := ast.Parse(source, &syms, ruleparser.NewRulesGrammar())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return node, &syms, &objs, new(int)
+}
+
+func shortCircuitVM(objs *objects.Builder, calls *int) VM {
+	tbl := objects.TableFrom(objs)
+	counting := func(*objects.Table, []objects.Object) (objects.Object, error) {
+		*calls++
+		return objects.PackedTrue, nil
+	}
+	return VM{
+		Objects: &tbl,
+		Funcs:   objects.BuiltInFunctions{magicFalse, counting, counting},
+	}
+}
+
+func magicFalse(*objects.Table, []objects.Object) (objects.Object, error) {
+	return objects.PackedFalse, nil
+}
+
+func TestShortCircuits(t *testing.T) {
+	expected := map[string]struct {
+		answer bool
+		calls  int
+	}{
+		"is_adult() and is_child() and has_bottle()":       {false, 0},
+		"is_child() or has_bottle()":                       {true, 1},
+		"is_adult() or is_child() or has_bottle()":         {true, 1},
+		"(is_adult() or is_child()) and has_bottle()":      {true, 2},
+		"is_adult() and is_child() or has_bottle()":        {true, 1},
+		"not (is_adult() and has_bottle()) and is_child()": {true, 1},
+	}
+
+	for source, want := range expected {
+		node, syms, objs, calls := shortCircuitFixture(t, source)
+		bytecode, err := compiler.Compile(node, syms, objs, objs.FunctionTable())
+		if err != nil {
+			t.Fatal(err)
+		}
+		vm := shortCircuitVM(objs, calls)
+		answer, err := vm.Execute(bytecode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if vm.Truthy(answer) != want.answer || *calls != want.calls {
+			t.Errorf("%s: expected %t after %d calls, found %t after %d calls",
+				source, want.answer, want.calls, vm.Truthy(answer), *calls)
+		}
+	}
+}
+
+const benchmarkRule = "is_adult() and is_child() and has_bottle() and is_child() and has_bottle() and is_child() and has_bottle() and is_child()"
+
+func BenchmarkShortCircuit(b *testing.B) {
+	benchmarkLowering(b, compiler.Compile)
+}
+
+func BenchmarkReduction(b *testing.B) {
+	benchmarkLowering(b, compiler.CompileReducing)
+}
+
+func benchmarkLowering(b *testing.B, compile func(ast.Node, *symbols.Table, *objects.Builder, []objects.BuiltInFunctionDef) (compiler.Bytecode, error)) {
+	node, syms, objs, calls := shortCircuitFixture(b, benchmarkRule)
+	bytecode, err := compile(node, syms, objs, objs.FunctionTable())
+	if err != nil {
+		b.Fatal(err)
+	}
+	vm := shortCircuitVM(objs, calls)
+
+	b.ResetTimer()
+	for range b.N {
+		if _, err := vm.Execute(bytecode); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(*calls)/float64(b.N), "calls/op")
+}
+
+func TestDebuggerPausesAtBreakpoints(t *testing.T) {
+	node, syms, objs, calls := shortCircuitFixture(t, "is_child() and (is_adult() or has_bottle())")
+	bytecode, err := compiler.Compile(node, syms, objs, objs.FunctionTable())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var traced int
+	var paused []string
+	var stacks [][]objects.Object
+	debugger := Debugger{
+		Ops:   map[code.Op]bool{code.JMP_IF_TRUE: true},
+		Funcs: map[objects.Addr32]bool{2: true},
+		Trace: func(Step) error { traced++; return nil },
+		Pause: func(step Step) error {
+			paused = append(paused, step.String())
+			stacks = append(stacks, slices.Clone(step.Stack))
+			return nil
+		},
+	}
+	vm := shortCircuitVM(objs, calls)
+	vm.Debug = debugger.Hook
+	if _, err := vm.Execute(bytecode); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"0x09 | JMP_IF_TRUE  | -> 0x0F",
+		"0x0C | INVOKE_0     | 0x0002",
+	}
+	if !slices.Equal(paused, expected) {
+		t.Fatalf("expected to pause at\n%s\nfound\n%s", strings.Join(expected, "\n"), strings.Join(paused, "\n"))
+	}
+	if !slices.Equal(stacks[0], []objects.Object{objects.PackedFalse}) || len(stacks[1]) != 0 {
+		t.Fatalf("expected is_adult() on the stack at the first pause, found %v", stacks)
+	}
+	if traced != 5 {
+		t.Fatalf("expected to trace 5 steps, found %d", traced)
+	}
+
+	halt := errors.New("halt")
+	debugger.Stepping = true
+	debugger.Pause = func(Step) error { return halt }
+	if _, err := vm.Execute(bytecode); !errors.Is(err, halt) {
+		t.Fatalf("expected pausing to halt execution, found %v", err)
+	}
+}
+
+func TestProfilesEachRule(t *testing.T) {
+	node, syms, objs, calls := shortCircuitFixture(t, "is_child() and (is_adult() or has_bottle())")
+	slow, err := compiler.Compile(node, syms, objs, objs.FunctionTable())
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err = ast.Parse("is_adult()", syms, ruleparser.NewRulesGrammar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fast, err := compiler.Compile(node, syms, objs, objs.FunctionTable())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vm := shortCircuitVM(objs, calls)
+	vm.Funcs[2] = func(*objects.Table, []objects.Object) (objects.Object, error) {
+		time.Sleep(time.Millisecond)
+		return objects.PackedTrue, nil
+	}
+	vm.Profile = NewProfile([]objects.BuiltInFunctionDef{{Name: "is_adult"}, {Name: "is_child"}, {Name: "has_bottle"}})
+	vm.Profile.Rule(2, "fast")
+	if _, err := vm.Execute(fast); err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		vm.Profile.Rule(1, "slow")
+		if _, err := vm.Execute(slow); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rules := vm.Profile.Rules()
+	if len(rules) != 2 || rules[0].Name != "slow" || rules[1].Name != "fast" {
+		t.Fatalf("expected slow rule to sort first, found %v", rules)
+	}
+	expected := map[string]int{"is_child": 3, "is_adult": 3, "has_bottle": 3}
+	if rules[0].Entity != 1 || rules[0].Executions != 3 || rules[0].Instructions != 15 || !maps.Equal(rules[0].Builtins, expected) {
+		t.Errorf("unexpected slow rule profile %+v", rules[0])
+	}
+	if rules[1].Executions != 1 || rules[1].Instructions != 1 || !maps.Equal(rules[1].Builtins, map[string]int{"is_adult": 1}) {
+		t.Errorf("unexpected fast rule profile %+v", rules[1])
+	}
+	if calls := vm.Profile.Builtins(); calls["is_adult"] != 4 {
+		t.Errorf("expected 4 calls to is_adult, found %v", calls)
+	}
+
+	var report strings.Builder
+	if err := vm.Profile.WriteReport(&report); err != nil {
+		t.Fatal(err)
+	}
+	if slowAt, fastAt := strings.Index(report.String(), "slow"), strings.Index(report.String(), "fast"); slowAt < 0 || fastAt < slowAt {
+		t.Errorf("expected report to list slow before fast\n%s", report.String())
+	}
+
+	var samples bytes.Buffer
+	if err := vm.Profile.WritePprof(&samples); err != nil {
+		t.Fatal(err)
+	}
+	pprof, err := readPprof(&samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valueTypes := [][2]string{{"executions", "count"}, {"instructions", "count"}, {"builtin_calls", "count"}, {"wall", "nanoseconds"}}
+	if !slices.Equal(pprof.SampleTypes, valueTypes) {
+		t.Errorf("expected value types %v, found %v", valueTypes, pprof.SampleTypes)
+	}
+	// each rule and each builtin it called
+	if len(pprof.Samples) != 6 {
+		t.Fatalf("expected 6 samples, found %d", len(pprof.Samples))
+	}
+	counts := make(map[string][3]int64)
+	for _, sample := range pprof.Samples {
+		if len(sample.Stack) == 0 || len(sample.Values) != len(valueTypes) {
+			t.Fatalf("malformed sample %+v", sample)
+		}
+		rule := sample.Stack[len(sample.Stack)-1]
+		if !maps.Equal(sample.Labels, map[string]string{"rule": rule}) {
+			t.Errorf("expected sample to be labelled rule=%s, found %v", rule, sample.Labels)
+		}
+		counts[strings.Join(sample.Stack, ";")] = [3]int64(sample.Values[:3])
+	}
+	expectedCounts := map[string][3]int64{
+		"slow": {3, 15, 0}, "fast": {1, 1, 0},
+		"is_child();slow": {0, 0, 3}, "is_adult();slow": {0, 0, 3}, "has_bottle();slow": {0, 0, 3},
+		"is_adult();fast": {0, 0, 1},
+	}
+	if !maps.Equal(counts, expectedCounts) {
+		t.Errorf("expected sampled counts %v, found %v", expectedCounts, counts)
+	}
+}
+
+func TestExecutesWideIndices(t *testing.T) {
+	syms := symbols.NewTable()
+	objs := objects.NewTableBuilder()
+	for n := range math.MaxUint16 + 1 {
+		objs.InternNumber(float64(n) + 0.5)
+	}
+	for i, name := range []string{"is_adult", "has"} {
+		symbol := syms.Declare(name, symbols.BUILT_IN_FUNCTION)
+		objs.DefineFunction(symbol, objects.PackPtr32(objects.Ptr32{Tag: objects.PtrFunc, Addr: objects.Addr32(i)}),
+			objects.BuiltInFunctionDef{Name: name, Params: i * 2})
+	}
+	objs.AssociateSymbol(syms.Declare("Bow", symbols.TOKEN), objects.PackPtr32(objects.Ptr32{Tag: objects.PtrToken}))
+
+	long := strings.Repeat("long", 100)
+	node, err := ast.Parse(fmt.Sprintf("is_adult() and has(Bow, 2) and '%s' != 'short'", long), &syms, ruleparser.NewRulesGrammar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bytecode, err := compiler.Compile(node, &syms, &objs, objs.FunctionTable())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dis := code.DisassembleToString(bytecode.Tape); !strings.Contains(dis, "PUSH_STR_W") || !strings.Contains(dis, "CHK_QTY_W") {
+		t.Fatalf("expected wide ops\n%s", dis)
+	}
+
+	owned := 2.0
+	has := func(_ *objects.Table, args []objects.Object) (objects.Object, error) {
+		return objects.PackBool(objects.UnpackPtr32(args[0]).Addr == 0 && owned >= objects.UnpackF64(args[1])), nil
+	}
+	tbl := objects.TableFrom(&objs)
+	funcs := objects.BuiltInFunctions{magicTrue, has}
+	evaluators := map[string]Evaluator{
+		"vm": &VM{Objects: &tbl, Funcs: funcs, ChkQty: has},
+		"closures": &Closures{Objects: &tbl, Funcs: funcs, Has: func(token objects.Addr32, qty float64) bool {
+			return token == 0 && owned >= qty
+		}},
+	}
+	for name, evaluator := range evaluators {
+		answer, err := evaluator.Execute(bytecode)
+		if err != nil || answer != objects.PackedTrue {
+			t.Errorf("%s: expected true, found %s %v", name, answer, err)
+		}
+	}
+}
+
+func magicTrue(*objects.Table, []objects.Object) (objects.Object, error) {
+	return objects.PackedTrue, nil
+}
@@ -0,0 +1,114 @@
+package ruleparser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/etc-sudonters/substrate/peruse"
+)
+
+// what a source belongs to, e.g. "Kokiri Forest -> Lost Woods" or a helper
+// name
+type Origin struct {
+	Name, Source string
+}
+
+// an error rendered against the source it came from
+type Diagnostic struct {
+	Origin
+	At    Span
+	Stage string
+	Cause error
+}
+
+func (this Diagnostic) Unwrap() error {
+	return this.Cause
+}
+
+func (this Diagnostic) Location() Span {
+	return this.At
+}
+
+// renders as
+//
+//	parse error in Kokiri Forest -> Lost Woods: unexpected token
+//	  | can_use(Slingshot) and and is_adult
+//	  |                        ^^^
+func (this Diagnostic) Error() string {
+	var b strings.Builder
+	stage := this.Stage
+	if stage == "" {
+		stage = "rule"
+	}
+
+	fmt.Fprintf(&b, "%s error", stage)
+	if this.Name != "" {
+		fmt.Fprintf(&b, " in %s", this.Name)
+	}
+	fmt.Fprintf(&b, ": %s", this.Cause)
+
+	if this.Source == "" {
+		return b.String()
+	}
+
+	start := min(max(int(this.At.Start), 0), len(this.Source))
+	end := min(max(int(this.At.End), start+1), len(this.Source)+1)
+	lineStart := strings.LastIndexByte(this.Source[:start], '\n') + 1
+	lineEnd := len(this.Source)
+	if nl := strings.IndexByte(this.Source[start:], '\n'); nl != -1 {
+		lineEnd = start + nl
+	}
+	end = min(end, max(lineEnd, start+1))
+
+	b.WriteString("\n  | ")
+	b.WriteString(this.Source[lineStart:lineEnd])
+	b.WriteString("\n  | ")
+	for _, r := range this.Source[lineStart:start] {
+		if r == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	b.WriteString(strings.Repeat("^", end-start))
+	return b.String()
+}
+
+// locates err within origin, falls back to the entire source
+func Diagnose(origin Origin, stage string, err error) Diagnostic {
+	span, found := SpanOf(err)
+	if !found {
+		span = Span{0, peruse.Pos(len(origin.Source))}
+	}
+	return Diagnostic{Origin: origin, At: span, Stage: stage, Cause: err}
+}
+
+// every diagnostic from a batch of sources
+type Diagnostics []Diagnostic
+
+// renders each diagnostic followed by a count
+func (this Diagnostics) Error() string {
+	var b strings.Builder
+	for i := range this {
+		b.WriteString(this[i].Error())
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "%d error(s)", len(this))
+	return b.String()
+}
+
+func (this Diagnostics) Unwrap() []error {
+	errs := make([]error, len(this))
+	for i := range this {
+		errs[i] = this[i]
+	}
+	return errs
+}
+
+// nil when there are no diagnostics
+func (this Diagnostics) Err() error {
+	if len(this) == 0 {
+		return nil
+	}
+	return this
+}
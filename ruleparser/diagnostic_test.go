@@ -0,0 +1,51 @@
+package ruleparser
+
+import (
+	"testing"
+)
+
+func parse(t *testing.T, source string) (Tree, error) {
+	t.Helper()
+	return Parse(NewRulesGrammar(), source)
+}
+
+func TestSpansCoverSource(t *testing.T) {
+	source := "can_use(Slingshot) and 'Goron Tunic'"
+	tree, err := parse(t, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	boolOp := MustAssertAs[*BoolOp](tree)
+	expected := map[string]Span{
+		"can_use(Slingshot)": boolOp.Left.Location(),
+		"'Goron Tunic'":      boolOp.Right.Location(),
+		source:               boolOp.Location(),
+	}
+
+	for text, span := range expected {
+		if spanned := source[span.Start:span.End]; spanned != text {
+			t.Errorf("expected span to cover %q but covered %q", text, spanned)
+		}
+	}
+}
+
+func TestDiagnosticPointsAtToken(t *testing.T) {
+	source := "is_adult and and Hover_Boots"
+	_, err := parse(t, source)
+	if err == nil {
+		t.Fatal("expected parse to fail")
+	}
+
+	diagnostic := Diagnose(Origin{Name: "Root -> Hyrule Field", Source: source}, "parse", err)
+	if spanned := source[diagnostic.At.Start:diagnostic.At.End]; spanned != "and" {
+		t.Fatalf("expected diagnostic at second 'and', found %q", spanned)
+	}
+
+	expected := "parse error in Root -> Hyrule Field: " + err.Error() + `
+  | is_adult and and Hover_Boots
+  |              ^^^`
+	if rendered := diagnostic.Error(); rendered != expected {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, rendered)
+	}
+}
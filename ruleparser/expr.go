@@ -92,13 +92,17 @@ func Unify[A Tree, B Tree, C any](pt Tree, a func(A) (C, error), b func(B) (C, e
 }
 
 var (
-	BinOpEq       BinOpKind   = "=="
-	BinOpNotEq    BinOpKind   = "!="
-	BinOpLt       BinOpKind   = "<"
-	BinOpContains BinOpKind   = "in"
-	BoolOpAnd     BoolOpKind  = "and"
-	BoolOpOr      BoolOpKind  = "or"
-	UnaryNot      UnaryOpKind = "not"
+	BinOpEq          BinOpKind   = "=="
+	BinOpNotEq       BinOpKind   = "!="
+	BinOpLt          BinOpKind   = "<"
+	BinOpGt          BinOpKind   = ">"
+	BinOpLtEq        BinOpKind   = "<="
+	BinOpGtEq        BinOpKind   = ">="
+	BinOpContains    BinOpKind   = "in"
+	BinOpNotContains BinOpKind   = "not in"
+	BoolOpAnd        BoolOpKind  = "and"
+	BoolOpOr         BoolOpKind  = "or"
+	UnaryNot         UnaryOpKind = "not"
 )
 
 type ExprType string
@@ -112,6 +116,9 @@ const (
 	ExprTuple      = "Tuple"
 	ExprUnaryOp    = "UnaryOp"
 	ExprLiteral    = "Literal"
+	ExprInvalid    = "Invalid"
+	ExprAttribute  = "Attribute"
+	ExprKeyword    = "Keyword"
 )
 
 type LiteralKind string
@@ -126,6 +133,7 @@ const (
 type (
 	Tree interface {
 		Type() ExprType
+		Location() Span
 		exprNode()
 	}
 )
@@ -141,40 +149,70 @@ type (
 		Left  Tree
 		Op    BoolOpKind
 		Right Tree
+		At    Span
 	}
 
 	Literal struct {
 		Kind  LiteralKind
 		Value any
+		At    Span
 	}
 
 	Identifier struct {
 		Value string
+		At    Span
 	}
 
 	BinOp struct {
 		Left  Tree
 		Op    BinOpKind
 		Right Tree
+		At    Span
 	}
 
 	Call struct {
 		Callee Tree
 		Args   []Tree
+		At     Span
 	}
 
 	Subscript struct {
 		Target Tree
 		Index  Tree
+		At     Span
 	}
 
 	Tuple struct {
 		Elems []Tree
+		At    Span
 	}
 
 	UnaryOp struct {
 		Op     UnaryOpKind
 		Target Tree
+		At     Span
+	}
+
+	// target.Name, e.g. settings.bridge
+	Attribute struct {
+		Target Tree
+		Name   string
+		At     Span
+	}
+
+	// a Name=Value argument, only found in Call.Args
+	Keyword struct {
+		Name  string
+		Value Tree
+		At    Span
+	}
+
+	// stands in for source that could not be parsed, Partial holds
+	// whatever was parsed before the error
+	Invalid struct {
+		Cause   error
+		Partial []Tree
+		At      Span
 	}
 )
 
@@ -186,6 +224,9 @@ func (s *Subscript) exprNode()  {}
 func (t *Tuple) exprNode()      {}
 func (u *UnaryOp) exprNode()    {}
 func (l *Literal) exprNode()    {}
+func (i *Invalid) exprNode()    {}
+func (a *Attribute) exprNode()  {}
+func (k *Keyword) exprNode()    {}
 
 func (expr *BinOp) Type() ExprType      { return ExprBinOp }
 func (expr *BoolOp) Type() ExprType     { return ExprBoolOp }
@@ -195,6 +236,21 @@ func (expr *Subscript) Type() ExprType  { return ExprSubscript }
 func (expr *Tuple) Type() ExprType      { return ExprTuple }
 func (expr *UnaryOp) Type() ExprType    { return ExprUnaryOp }
 func (expr *Literal) Type() ExprType    { return ExprLiteral }
+func (expr *Invalid) Type() ExprType    { return ExprInvalid }
+func (expr *Attribute) Type() ExprType  { return ExprAttribute }
+func (expr *Keyword) Type() ExprType    { return ExprKeyword }
+
+func (expr *BinOp) Location() Span      { return expr.At }
+func (expr *BoolOp) Location() Span     { return expr.At }
+func (expr *Call) Location() Span       { return expr.At }
+func (expr *Identifier) Location() Span { return expr.At }
+func (expr *Subscript) Location() Span  { return expr.At }
+func (expr *Tuple) Location() Span      { return expr.At }
+func (expr *UnaryOp) Location() Span    { return expr.At }
+func (expr *Literal) Location() Span    { return expr.At }
+func (expr *Invalid) Location() Span    { return expr.At }
+func (expr *Attribute) Location() Span  { return expr.At }
+func (expr *Keyword) Location() Span    { return expr.At }
 
 func (expr *Literal) AsBool() (bool, bool) {
 	if expr.Kind == LiteralBool {
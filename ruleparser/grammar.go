@@ -2,6 +2,7 @@ package ruleparser
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -29,16 +30,25 @@ func NewRulesGrammar() peruse.Grammar[Tree] {
 	g.Parse(TokenOpenParen, parseParenExpr)
 	g.Parse(TokenString, parseString)
 	g.Parse(TokenUnaryNot, parsePrefixNot)
+	g.Parse(TokenOpenBracket, parseList)
 
 	g.Infix(OR, parseBoolOpExpr, TokenOr)
 	g.Infix(AND, parseBoolOpExpr, TokenAnd)
-	g.Infix(EQ, parseBinOp, TokenEq, TokenNotEq, TokenLt, TokenContains)
+	g.Infix(EQ, parseBinOp, comparisons...)
 	g.Infix(INDEX, parseSubscript, TokenOpenBracket)
+	g.Infix(INDEX, parseAttribute, TokenDot)
 	g.Infix(PARENS, parseCall, TokenOpenParen)
 
 	return g
 }
 
+// not is only a comparison when followed by in
+var comparisons = []peruse.TokenType{
+	TokenEq, TokenNotEq, TokenLt, TokenGt, TokenLtEq, TokenGtEq, TokenContains, TokenUnaryNot,
+}
+
+var unescape = strings.NewReplacer(`\\`, `\`, `\'`, `'`)
+
 func BoolOpFromTok(t peruse.Token) BoolOpKind {
 	switch s := strings.ToLower(t.Literal); s {
 	case string(BoolOpAnd):
@@ -54,37 +64,40 @@ func BinOpFromTok(t peruse.Token) BinOpKind {
 	switch t.Literal {
 	case string(BinOpLt):
 		return BinOpLt
+	case string(BinOpGt):
+		return BinOpGt
+	case string(BinOpLtEq):
+		return BinOpLtEq
+	case string(BinOpGtEq):
+		return BinOpGtEq
 	case string(BinOpEq):
 		return BinOpEq
 	case string(BinOpNotEq):
 		return BinOpNotEq
 	case string(BinOpContains):
 		return BinOpContains
+	case notWord:
+		return BinOpNotContains
 	default:
 		panic(fmt.Errorf("invalid binop %q", t))
 	}
 }
 
 func parseParenExpr(p *peruse.Parser[Tree]) (Tree, error) {
-	p.Consume()
-	e, err := p.ParseAt(LOWEST)
-	if err != nil {
-		return nil, err
-	}
+	open := p.Cur
+	e := parseOperand(p, LOWEST)
 
 	if p.Next.Is(TokenComma) {
-		e, err = parseTuple(p, e)
-		if err != nil {
-			return nil, err
-		}
+		e, _ = parseTuple(p, e)
 	}
 
 	if !p.Expect(TokenCloseParen) {
-		return nil, fmt.Errorf(
-			"PARENEXPR: expected %q but got %q",
-			TokenTypeString(TokenCloseParen),
-			p.Next,
-		)
+		err := expectedToken("')'", p.Next)
+		return recoverTo(p, TokenCloseParen, err, e), nil
+	}
+
+	if tuple, isTuple := e.(*Tuple); isTuple {
+		tuple.At = TokenSpan(open).Join(TokenSpan(p.Cur))
 	}
 
 	return e, nil
@@ -93,67 +106,73 @@ func parseParenExpr(p *peruse.Parser[Tree]) (Tree, error) {
 func parseTuple(p *peruse.Parser[Tree], left Tree) (Tree, error) {
 	elems := []Tree{left}
 
-	for p.Expect(TokenComma) {
-		p.Consume()
-		elem, err := p.ParseAt(LOWEST)
-		if err != nil {
-			return nil, err
-		}
-
-		elems = append(elems, elem)
+	// (a,) is a single element tuple
+	for p.Expect(TokenComma) && !p.Next.Is(TokenCloseParen) {
+		elems = append(elems, parseOperand(p, LOWEST))
 	}
 
-	return &Tuple{Elems: elems}, nil
+	return &Tuple{Elems: elems, At: left.Location().Join(elems[len(elems)-1].Location())}, nil
 }
 
 func parseIdentifierExpr(p *peruse.Parser[Tree]) (Tree, error) {
-	return &Identifier{p.Cur.Literal}, nil
+	return &Identifier{Value: p.Cur.Literal, At: TokenSpan(p.Cur)}, nil
 }
 
 func parseBoolOpExpr(p *peruse.Parser[Tree], left Tree, parentPrecedence peruse.Precedence) (Tree, error) {
 	thisTok := p.Cur
-	p.Consume()
-	right, err := p.ParseAt(parentPrecedence)
-	if err != nil {
-		return nil, err
-	}
+	right := parseOperand(p, parentPrecedence)
 	b := BoolOp{
 		Left:  left,
 		Op:    BoolOpFromTok(thisTok),
 		Right: right,
+		At:    left.Location().Join(right.Location()),
 	}
 
 	return &b, nil
 }
 
+// comparisons chain like python's, a < b < c is a < b and b < c
 func parseBinOp(p *peruse.Parser[Tree], left Tree, bp peruse.Precedence) (Tree, error) {
 	thisTok := p.Cur
-	p.Consume()
-	right, err := p.ParseAt(bp)
-	if err != nil {
-		return nil, err
+	if thisTok.Is(TokenUnaryNot) && !p.Expect(TokenContains) {
+		err := expectedToken("'in' after 'not'", p.Next)
+		return recoverFrom(p, err, left), nil
 	}
 
+	right := parseOperand(p, bp)
 	b := BinOp{
 		Left:  left,
 		Op:    BinOpFromTok(thisTok),
 		Right: right,
+		At:    left.Location().Join(right.Location()),
 	}
 
-	return &b, nil
+	if !slices.Contains(comparisons, p.Next.Type) {
+		return &b, nil
+	}
+
+	p.Consume()
+	rest, err := parseBinOp(p, right, bp)
+	chain := BoolOp{
+		Left:  &b,
+		Op:    BoolOpAnd,
+		Right: rest,
+		At:    b.At.Join(rest.Location()),
+	}
+	return &chain, err
 }
 
 func parseCall(p *peruse.Parser[Tree], left Tree, bp peruse.Precedence) (Tree, error) {
 	if p.Expect(TokenCloseParen) { // fn()
-		return &Call{Callee: left}, nil
+		return &Call{Callee: left, At: left.Location().Join(TokenSpan(p.Cur))}, nil
 	}
 
 	var args []Tree
 	for {
-		p.Consume()
-		arg, err := p.ParseAt(LOWEST)
-		if err != nil {
-			return nil, err
+		arg := parseOperand(p, LOWEST)
+		if name, isIdent := arg.(*Identifier); isIdent && p.Expect(TokenAssign) {
+			value := parseOperand(p, LOWEST)
+			arg = &Keyword{Name: name.Value, Value: value, At: name.At.Join(value.Location())}
 		}
 		args = append(args, arg)
 		if !p.Expect(TokenComma) {
@@ -161,61 +180,85 @@ func parseCall(p *peruse.Parser[Tree], left Tree, bp peruse.Precedence) (Tree, e
 		}
 	}
 
-	if err := p.ExpectOrError(TokenCloseParen); err != nil {
-		return nil, peruse.UnexpectedAt("FNCALL", err)
+	if !p.Expect(TokenCloseParen) {
+		err := expectedToken("')'", p.Next)
+		args = append(args, recoverTo(p, TokenCloseParen, err))
 	}
 
-	c := Call{Callee: left, Args: args}
+	c := Call{Callee: left, Args: args, At: left.Location().Join(TokenSpan(p.Cur))}
 	return &c, nil
 }
 
 func parseSubscript(p *peruse.Parser[Tree], left Tree, bp peruse.Precedence) (Tree, error) {
-	p.Consume()
-	index, err := p.ParseAt(LOWEST)
-	if err != nil {
-		return nil, err
-	}
+	index := parseOperand(p, LOWEST)
 
-	if err = p.ExpectOrError(TokenCloseBracket); err != nil {
-		return nil, peruse.UnexpectedAt("SUBSCRIPT", err)
+	if !p.Expect(TokenCloseBracket) {
+		err := expectedToken("']'", p.Next)
+		index = recoverTo(p, TokenCloseBracket, err, index)
 	}
 
-	s := Subscript{Target: left, Index: index}
+	s := Subscript{Target: left, Index: index, At: left.Location().Join(TokenSpan(p.Cur))}
 	return &s, nil
 }
 
+func parseAttribute(p *peruse.Parser[Tree], left Tree, bp peruse.Precedence) (Tree, error) {
+	if !p.Expect(TokenIdentifier) {
+		err := expectedToken("an attribute name", p.Next)
+		return recoverFrom(p, err, left), nil
+	}
+
+	a := Attribute{Target: left, Name: p.Cur.Literal, At: left.Location().Join(TokenSpan(p.Cur))}
+	return &a, nil
+}
+
+// [a, b] is treated as the tuple (a, b)
+func parseList(p *peruse.Parser[Tree]) (Tree, error) {
+	open := p.Cur
+	var elems []Tree
+	for {
+		elems = append(elems, parseOperand(p, LOWEST))
+		if !p.Expect(TokenComma) || p.Next.Is(TokenCloseBracket) {
+			break
+		}
+	}
+
+	if !p.Expect(TokenCloseBracket) {
+		err := expectedToken("']'", p.Next)
+		return recoverTo(p, TokenCloseBracket, err, elems...), nil
+	}
+
+	return &Tuple{Elems: elems, At: TokenSpan(open).Join(TokenSpan(p.Cur))}, nil
+}
+
 func parseString(p *peruse.Parser[Tree]) (Tree, error) {
-	s := &Literal{Value: p.Cur.Literal, Kind: LiteralStr}
+	s := &Literal{Value: unescape.Replace(p.Cur.Literal), Kind: LiteralStr, At: TokenSpan(p.Cur)}
 	return s, nil
 }
 
 func parseNumber(p *peruse.Parser[Tree]) (Tree, error) {
 	n, err := strconv.ParseFloat(p.Cur.Literal, 64)
 	if err != nil {
-		return nil, fmt.Errorf("cannot parse %q as number", p.Cur)
+		return &Invalid{Cause: fmt.Errorf("cannot parse %q as number", p.Cur.Literal), At: TokenSpan(p.Cur)}, nil
 	}
-	return &Literal{Value: n, Kind: LiteralNum}, nil
+	return &Literal{Value: n, Kind: LiteralNum, At: TokenSpan(p.Cur)}, nil
 }
 
 func parseBool(p *peruse.Parser[Tree]) (Tree, error) {
-	return &Literal{Value: p.Cur.Literal == trueWord, Kind: LiteralBool}, nil
+	return &Literal{Value: p.Cur.Literal == trueWord, Kind: LiteralBool, At: TokenSpan(p.Cur)}, nil
 }
 
 func parsePrefixNot(p *peruse.Parser[Tree]) (Tree, error) {
 	thisTok := p.Cur
-	p.Consume()
-	target, err := p.ParseAt(NOT)
-	if err != nil {
-		return nil, err
-	}
+	target := parseOperand(p, NOT)
 	switch thisTok.Literal {
 	case notWord:
 		u := UnaryOp{
 			Op:     UnaryNot,
 			Target: target,
+			At:     TokenSpan(thisTok).Join(target.Location()),
 		}
 		return &u, nil
 	default:
-		return nil, fmt.Errorf("unexpected unary op %q", thisTok)
+		return &Invalid{Cause: fmt.Errorf("unexpected unary op %q", thisTok.Literal), Partial: []Tree{target}, At: TokenSpan(thisTok)}, nil
 	}
 }
@@ -35,6 +35,11 @@ const (
 	TokenLt
 	TokenUnaryNot
 	TokenContains
+	TokenGt
+	TokenLtEq
+	TokenGtEq
+	TokenDot
+	TokenAssign
 )
 
 func TokenTypeString(i peruse.TokenType) string {
@@ -77,6 +82,16 @@ func TokenTypeString(i peruse.TokenType) string {
 		return "<UNARY>"
 	case TokenContains:
 		return "<IN>"
+	case TokenGt:
+		return "<GT>"
+	case TokenLtEq:
+		return "<LTEQ>"
+	case TokenGtEq:
+		return "<GTEQ>"
+	case TokenDot:
+		return "<DOT>"
+	case TokenAssign:
+		return "<ASSIGN>"
 	default:
 		return "<UNKNOWN>"
 	}
@@ -91,14 +106,10 @@ type ruleLexState struct {
 	brackDepth int
 }
 
-func lexRule(l *peruse.StringLexer, state any) peruse.LexFn {
-	s := state.(*ruleLexState)
-
+func lexRule(l *peruse.StringLexer, _ any) peruse.LexFn {
 	switch r := l.Next(); {
 	case r == eof:
-		if s.parenDepth > 0 || s.brackDepth > 0 {
-			return l.Error("unclosed '(' or '['")
-		}
+		// the parser reports unclosed '(' and '[' where it expected the closer
 		return nil
 	case isIdentBegin(r):
 		l.Prev()
@@ -118,6 +129,8 @@ func lexRule(l *peruse.StringLexer, state any) peruse.LexFn {
 		return lexCloseBrack
 	case r == ',':
 		return l.Emit(TokenComma)
+	case r == '.':
+		return l.Emit(TokenDot)
 	case r == '=':
 		l.Prev()
 		return lexEq
@@ -214,13 +227,13 @@ func lexCloseBrack(l *peruse.StringLexer, state any) peruse.LexFn {
 	return l.Emit(TokenCloseBracket)
 }
 
+// either == or the = of a keyword argument
 func lexEq(l *peruse.StringLexer, _ any) peruse.LexFn {
-	//lint:ignore SA4000 they have side effects
-	if l.AcceptOneOf("=") && l.AcceptOneOf("=") {
+	l.AcceptOneOf("=")
+	if l.AcceptOneOf("=") {
 		return l.Emit(TokenEq)
 	}
-
-	return unexpected(l.Peek(), l)
+	return l.Emit(TokenAssign)
 }
 
 func lexNotEq(l *peruse.StringLexer, _ any) peruse.LexFn {
@@ -231,13 +244,31 @@ func lexNotEq(l *peruse.StringLexer, _ any) peruse.LexFn {
 }
 
 func lexInEq(l *peruse.StringLexer, _ any) peruse.LexFn {
-	l.AcceptOneOf("<") // know its one of these
-	return l.Emit(TokenLt)
+	if l.AcceptOneOf("<") { // know its one of these
+		if l.AcceptOneOf("=") {
+			return l.Emit(TokenLtEq)
+		}
+		return l.Emit(TokenLt)
+	}
+
+	l.AcceptOneOf(">")
+	if l.AcceptOneOf("=") {
+		return l.Emit(TokenGtEq)
+	}
+	return l.Emit(TokenGt)
 }
 
-// opening ' is already scanned
+// opening ' is already scanned, \' and \\ are left escaped for parseString
 func lexStr(l *peruse.StringLexer, _ any) peruse.LexFn {
-	l.AcceptWhile(func(r rune) bool { return r != '\'' })
+	escaped := false
+	l.AcceptWhile(func(r rune) bool {
+		if escaped {
+			escaped = false
+			return true
+		}
+		escaped = r == '\\'
+		return r != '\''
+	})
 	// sheer off ending '
 	next := l.Emit(TokenString)
 	l.Next()
@@ -268,7 +299,7 @@ func atSeparator(l *peruse.StringLexer) bool {
 	}
 
 	switch r {
-	case eof, '.', '(', ')', ',', '[', ']':
+	case eof, '.', '(', ')', ',', '[', ']', '=', '!', '<', '>':
 		return true
 	default:
 		return false
@@ -78,12 +78,11 @@ func TestCanLexParens(t *testing.T) {
 	toksAreEqual(expected, collected, t)
 }
 
-func TestErrsOnUnclosedParen(t *testing.T) {
+func TestLeavesUnclosedParenToParser(t *testing.T) {
 	rule := "(Compiler"
 	expected := []peruse.Token{
 		{Type: TokenOpenParen, Pos: 0, Literal: "("},
 		{Type: TokenIdentifier, Pos: 1, Literal: "Compiler"},
-		{Type: peruse.ERR, Pos: 9, Literal: "unclosed '(' or '['"},
 	}
 
 	l := NewRulesLexer(rule)
@@ -104,6 +103,31 @@ func TestErrsOnUnexpectedClosedParent(t *testing.T) {
 	toksAreEqual(expected, collected, t)
 }
 
+func TestCanLexComparisonsAndAttributes(t *testing.T) {
+	rule := "settings.x<=1>=f(a=b)>2"
+	expected := []peruse.Token{
+		{Type: TokenIdentifier, Pos: 0, Literal: "settings"},
+		{Type: TokenDot, Pos: 8, Literal: "."},
+		{Type: TokenIdentifier, Pos: 9, Literal: "x"},
+		{Type: TokenLtEq, Pos: 10, Literal: "<="},
+		{Type: TokenNumber, Pos: 12, Literal: "1"},
+		{Type: TokenGtEq, Pos: 13, Literal: ">="},
+		{Type: TokenIdentifier, Pos: 15, Literal: "f"},
+		{Type: TokenOpenParen, Pos: 16, Literal: "("},
+		{Type: TokenIdentifier, Pos: 17, Literal: "a"},
+		{Type: TokenAssign, Pos: 18, Literal: "="},
+		{Type: TokenIdentifier, Pos: 19, Literal: "b"},
+		{Type: TokenCloseParen, Pos: 20, Literal: ")"},
+		{Type: TokenGt, Pos: 21, Literal: ">"},
+		{Type: TokenNumber, Pos: 22, Literal: "2"},
+	}
+
+	l := NewRulesLexer(rule)
+	collected := lexUntilEofOrErr(l, t)
+
+	toksAreEqual(expected, collected, t)
+}
+
 func TestCanLexActualRules(t *testing.T) {
 	rules := []string{
 		"can_play(Song_of_Time) or (logic_shadow_mq_invisible_blades and damage_multiplier != 'ohko')",
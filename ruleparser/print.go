@@ -0,0 +1,186 @@
+package ruleparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/etc-sudonters/substrate/peruse"
+)
+
+var escape = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// renders trees as rule source that parses back into the same tree,
+// parentheses are only written where precedence requires them
+type Printer struct {
+	// and/or chains that would run past this many columns are broken
+	// across lines, 0 never wraps
+	Width int
+	// written once per nesting level before a wrapped line, defaults to
+	// four spaces
+	Indent string
+}
+
+func Print(tree Tree) string {
+	return Printer{}.Print(tree)
+}
+
+func (this Printer) Print(tree Tree) string {
+	var b strings.Builder
+	this.print(&b, tree, 0)
+	return b.String()
+}
+
+// true if name can be written as an identifier instead of a 'string'
+func IsIdentifier(name string) bool {
+	switch name {
+	case "", trueWord, falseWord, andWord, orWord, notWord, inWord:
+		return false
+	}
+
+	for i, r := range name {
+		if i == 0 && !isIdentBegin(r) || !isIdentRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (this Printer) print(b *strings.Builder, tree Tree, depth int) {
+	switch tree := tree.(type) {
+	case *BoolOp:
+		this.boolOp(b, tree, depth)
+	case *BinOp:
+		// comparisons chain so neither side may be a comparison
+		this.operand(b, tree.Left, EQ+1, depth)
+		fmt.Fprintf(b, " %s ", tree.Op)
+		this.operand(b, tree.Right, EQ+1, depth)
+	case *UnaryOp:
+		fmt.Fprintf(b, "%s ", tree.Op)
+		this.operand(b, tree.Target, NOT, depth)
+	case *Call:
+		this.operand(b, tree.Callee, PARENS, depth)
+		b.WriteRune('(')
+		this.join(b, tree.Args, depth)
+		b.WriteRune(')')
+	case *Subscript:
+		this.operand(b, tree.Target, INDEX, depth)
+		b.WriteRune('[')
+		this.print(b, tree.Index, depth)
+		b.WriteRune(']')
+	case *Attribute:
+		this.operand(b, tree.Target, INDEX, depth)
+		fmt.Fprintf(b, ".%s", tree.Name)
+	case *Keyword:
+		fmt.Fprintf(b, "%s=", tree.Name)
+		this.print(b, tree.Value, depth)
+	case *Tuple:
+		b.WriteRune('(')
+		this.join(b, tree.Elems, depth)
+		if len(tree.Elems) == 1 {
+			b.WriteRune(',')
+		}
+		b.WriteRune(')')
+	case *Identifier:
+		b.WriteString(tree.Value)
+	case *Literal:
+		printLiteral(b, tree)
+	case *Invalid:
+		fmt.Fprintf(b, "<invalid: %s>", tree.Cause)
+	default:
+		panic(fmt.Errorf("cannot print %T", tree))
+	}
+}
+
+// writes a and/or chain, wrapping each operand onto its own line if the
+// chain is too wide
+func (this Printer) boolOp(b *strings.Builder, tree *BoolOp, depth int) {
+	precedence := precedenceOf(tree)
+	chain := []Tree{tree.Right}
+	left := tree.Left
+	for {
+		inner, isBoolOp := left.(*BoolOp)
+		if !isBoolOp || inner.Op != tree.Op {
+			break
+		}
+		chain = append(chain, inner.Right)
+		left = inner.Left
+	}
+	chain = append(chain, left)
+
+	separator := fmt.Sprintf(" %s ", tree.Op)
+	if this.Width > 0 {
+		indent := this.Indent
+		if indent == "" {
+			indent = "    "
+		}
+		if depth*len(indent)+len(Printer{}.Print(tree)) > this.Width {
+			depth++
+			separator = fmt.Sprintf("\n%s%s ", strings.Repeat(indent, depth), tree.Op)
+		}
+	}
+
+	this.operand(b, chain[len(chain)-1], precedence, depth)
+	for i := len(chain) - 2; i >= 0; i-- {
+		b.WriteString(separator)
+		this.operand(b, chain[i], precedence+1, depth)
+	}
+}
+
+// parenthesizes operands that bind looser than the operator they belong to
+func (this Printer) operand(b *strings.Builder, tree Tree, least peruse.Precedence, depth int) {
+	if precedenceOf(tree) >= least {
+		this.print(b, tree, depth)
+		return
+	}
+	b.WriteRune('(')
+	this.print(b, tree, depth+1)
+	b.WriteRune(')')
+}
+
+func (this Printer) join(b *strings.Builder, trees []Tree, depth int) {
+	for i := range trees {
+		if i != 0 {
+			b.WriteString(", ")
+		}
+		this.print(b, trees[i], depth)
+	}
+}
+
+func printLiteral(b *strings.Builder, literal *Literal) {
+	switch value := literal.Value.(type) {
+	case bool:
+		if value {
+			b.WriteString(trueWord)
+		} else {
+			b.WriteString(falseWord)
+		}
+	case float64:
+		b.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+	case string:
+		fmt.Fprintf(b, "'%s'", escape.Replace(value))
+	default:
+		panic(fmt.Errorf("cannot print %s literal %v", literal.Kind, literal.Value))
+	}
+}
+
+// the grammar precedence an expression binds at, atoms bind tightest
+func precedenceOf(tree Tree) peruse.Precedence {
+	switch tree := tree.(type) {
+	case *BoolOp:
+		if tree.Op == BoolOpOr {
+			return OR
+		}
+		return AND
+	case *UnaryOp:
+		return NOT
+	case *BinOp:
+		return EQ
+	case *Subscript, *Attribute:
+		return INDEX
+	case *Call:
+		return PARENS
+	default:
+		return PARENS + 1
+	}
+}
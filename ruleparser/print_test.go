@@ -0,0 +1,205 @@
+package ruleparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPrintRoundTrips(t *testing.T) {
+	sources := []string{
+		"is_adult",
+		"can_use(Slingshot) and 'Goron Tunic'",
+		"is_adult and (can_use(Hover_Boots) or has('Goron Tunic', 2))",
+		"(is_adult or is_child) and not at_night",
+		"not (is_adult and at_night)",
+		"a or (b or c)",
+		"a == b and not c != d",
+		"(not a) == b",
+		"a == (b == c)",
+		"'Forest Temple' in dungeon_shortcuts or skipped_trials[Forest]",
+		"(Slingshot, 1) and (Bow, 2.5)",
+		"f(a or b, (c, d))[x]",
+		"damage_multiplier != 'ohko' or can_use(Nayrus_Love)",
+		"True and False or 12",
+		"settings.bridge == 'open' and world.settings.open_forest != 'closed'",
+		"can_live_dmg(0.5, fairy=False)",
+		"damage_multiplier not in ('ohko', 'quadruple')",
+		"a <= b and c >= d and e > f",
+		"(a < b) < c",
+	}
+
+	for _, source := range sources {
+		tree, err := parse(t, source)
+		if err != nil {
+			t.Errorf("could not parse %q: %s", source, err)
+			continue
+		}
+
+		printed := Print(tree)
+		if printed != source {
+			t.Errorf("expected %q to print as itself, printed %q", source, printed)
+		}
+
+		reparsed, err := parse(t, printed)
+		if err != nil {
+			t.Errorf("could not parse printed %q: %s", printed, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(withoutSpans(tree), withoutSpans(reparsed)) {
+			t.Errorf("printed %q parsed to a different tree than %q", printed, source)
+		}
+	}
+}
+
+func TestPrintDropsRedundantParens(t *testing.T) {
+	expected := map[string]string{
+		"((is_adult))":            "is_adult",
+		"(a and b) or (c and d)":  "a and b or c and d",
+		"not (a == b)":            "not a == b",
+		"(a or b) or c":           "a or b or c",
+		"has((Bow), (2))":         "has(Bow, 2)",
+		"(f)(x)":                  "f(x)",
+		"(a and b) and c":         "a and b and c",
+		"(a and (not b)) and (c)": "a and not b and c",
+		"1 < x <= 3":              "1 < x and x <= 3",
+		"x in ['a', 'b',]":        "x in ('a', 'b')",
+	}
+
+	for source, want := range expected {
+		tree, err := parse(t, source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if printed := Print(tree); printed != want {
+			t.Errorf("expected %q to print as %q, printed %q", source, want, printed)
+		}
+	}
+}
+
+func TestPrintWraps(t *testing.T) {
+	source := "can_use(Hover_Boots) or is_adult and (can_use(Hookshot) or has('Goron Tunic') or logic_forest_vines)"
+	tree, err := parse(t, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	printed := Printer{Width: 60, Indent: "  "}.Print(tree)
+	expected := `can_use(Hover_Boots)
+  or is_adult
+    and (can_use(Hookshot)
+        or has('Goron Tunic')
+        or logic_forest_vines)`
+	if printed != expected {
+		t.Fatalf("expected\n%s\nprinted\n%s", expected, printed)
+	}
+
+	reparsed, err := parse(t, printed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(withoutSpans(tree), withoutSpans(reparsed)) {
+		t.Fatal("wrapped source parsed to a different tree")
+	}
+
+	if printed := (Printer{Width: 200}).Print(tree); strings.Contains(printed, "\n") {
+		t.Fatalf("expected short source to stay on one line:\n%s", printed)
+	}
+}
+
+func TestIsIdentifier(t *testing.T) {
+	for name, expected := range map[string]bool{
+		"Goron_Tunic": true,
+		"_private":    true,
+		"has2":        true,
+		"Goron Tunic": false,
+		"2has":        false,
+		"and":         false,
+		"True":        false,
+		"":            false,
+	} {
+		if IsIdentifier(name) != expected {
+			t.Errorf("expected IsIdentifier(%q) to be %t", name, expected)
+		}
+	}
+}
+
+func withoutSpans(tree Tree) Tree {
+	clear := func(span *Span) { *span = Span{} }
+	Visitor{
+		BinOp: func(node *BinOp, visit func(Tree) error) error {
+			clear(&node.At)
+			visit(node.Left)
+			return visit(node.Right)
+		},
+		BoolOp: func(node *BoolOp, visit func(Tree) error) error {
+			clear(&node.At)
+			visit(node.Left)
+			return visit(node.Right)
+		},
+		Call: func(node *Call, visit func(Tree) error) error {
+			clear(&node.At)
+			visit(node.Callee)
+			for _, arg := range node.Args {
+				visit(arg)
+			}
+			return nil
+		},
+		Identifier: func(node *Identifier, visit func(Tree) error) error {
+			clear(&node.At)
+			return nil
+		},
+		Subscript: func(node *Subscript, visit func(Tree) error) error {
+			clear(&node.At)
+			visit(node.Target)
+			return visit(node.Index)
+		},
+		Tuple: func(node *Tuple, visit func(Tree) error) error {
+			clear(&node.At)
+			for _, elem := range node.Elems {
+				visit(elem)
+			}
+			return nil
+		},
+		UnaryOp: func(node *UnaryOp, visit func(Tree) error) error {
+			clear(&node.At)
+			return visit(node.Target)
+		},
+		Literal: func(node *Literal, visit func(Tree) error) error {
+			clear(&node.At)
+			return nil
+		},
+		Attribute: func(node *Attribute, visit func(Tree) error) error {
+			clear(&node.At)
+			return visit(node.Target)
+		},
+		Keyword: func(node *Keyword, visit func(Tree) error) error {
+			clear(&node.At)
+			return visit(node.Value)
+		},
+	}.Visit(tree)
+	return tree
+}
+
+func TestPrintEscapesQuotes(t *testing.T) {
+	for value, printed := range map[string]string{
+		"Ganon's Castle": `'Ganon\'s Castle'`,
+		`back\slash`:     `'back\\slash'`,
+		`trailing\`:      `'trailing\\'`,
+	} {
+		tree := &Literal{Value: value, Kind: LiteralStr}
+		if source := Print(tree); source != printed {
+			t.Errorf("expected %q to print as %s, found %s", value, printed, source)
+		}
+
+		reparsed, err := parse(t, printed)
+		if err != nil {
+			t.Errorf("could not parse printed %s: %s", printed, err)
+			continue
+		}
+		if literal, isLit := reparsed.(*Literal); !isLit || literal.Value != value {
+			t.Errorf("expected %s to parse back to %q, found %#v", printed, value, reparsed)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package ruleparser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/etc-sudonters/substrate/peruse"
+)
+
+// every syntax error found in a single source
+type SyntaxErrors []SpanError
+
+func (this SyntaxErrors) Error() string {
+	msgs := make([]string, len(this))
+	for i := range this {
+		msgs[i] = this[i].Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (this SyntaxErrors) Unwrap() []error {
+	errs := make([]error, len(this))
+	for i := range this {
+		errs[i] = this[i]
+	}
+	return errs
+}
+
+// parses the entirety of source. Syntax errors do not stop parsing, the
+// parser skips ahead to the next ')', ']', 'and', 'or' or ',' and continues
+// leaving an Invalid node in place of what it skipped. The returned tree is
+// only usable when the error is nil.
+func Parse(grammar peruse.Grammar[Tree], source string) (Tree, error) {
+	p := peruse.NewParser(grammar, NewRulesLexer(source))
+	tree := parseAt(p, LOWEST)
+
+	for !p.Next.Is(peruse.EOF) {
+		if p.Next.Is(peruse.ERR) {
+			// lexer errors are not recoverable
+			if !errorAt(tree, p.Next.Pos) {
+				tree = &Invalid{Cause: fmt.Errorf("%s", p.Next.Literal), Partial: []Tree{tree}, At: TokenSpan(p.Next)}
+			}
+			break
+		}
+
+		p.Consume()
+		if p.Cur.Is(TokenAnd) || p.Cur.Is(TokenOr) {
+			op := p.Cur
+			right := parseOperand(p, grammar.Precedence(op.Type))
+			tree = &BoolOp{Left: tree, Op: BoolOpFromTok(op), Right: right, At: tree.Location().Join(right.Location())}
+			continue
+		}
+
+		tree = recoverFrom(p, unexpectedToken(p.Cur), tree)
+	}
+
+	return tree, Errors(tree)
+}
+
+// collects the errors of every Invalid node in tree
+func Errors(tree Tree) error {
+	var errs SyntaxErrors
+	Visitor{
+		Invalid: func(node *Invalid, visit func(Tree) error) error {
+			for _, partial := range node.Partial {
+				visit(partial)
+			}
+			errs = append(errs, SpanError{node.At, node.Cause})
+			return nil
+		},
+	}.Visit(tree)
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// consumes the operator or delimiter in Cur and parses the operand that
+// follows it. A missing operand is reported without consuming the token that
+// took its place so the enclosing construct can still close over it
+func parseOperand(p *peruse.Parser[Tree], precedence peruse.Precedence) Tree {
+	if synchronizes(p.Next) {
+		return &Invalid{
+			Cause: fmt.Errorf("expected expression but found %s", describeToken(p.Next)),
+			At:    TokenSpan(p.Next),
+		}
+	}
+	p.Consume()
+	return parseAt(p, precedence)
+}
+
+func parseAt(p *peruse.Parser[Tree], precedence peruse.Precedence) Tree {
+	tree, err := p.ParseAt(precedence)
+	if err != nil {
+		return recoverFrom(p, err, tree)
+	}
+	return tree
+}
+
+// records err and skips to the next synchronizing token
+func recoverFrom(p *peruse.Parser[Tree], err error, partial ...Tree) *Invalid {
+	invalid := invalidAt(p, err, partial)
+	for !synchronizes(p.Next) {
+		p.Consume()
+	}
+	return invalid
+}
+
+func invalidAt(p *peruse.Parser[Tree], err error, partial []Tree) *Invalid {
+	var unexpected peruse.UnexpectedToken
+	if errors.As(err, &unexpected) {
+		err = unexpectedToken(unexpected.Have)
+	}
+	span, found := SpanOf(err)
+	if !found {
+		span = TokenSpan(p.Cur)
+	}
+
+	invalid := &Invalid{Cause: err, At: span}
+	for _, tree := range partial {
+		if tree != nil {
+			invalid.Partial = append(invalid.Partial, tree)
+		}
+	}
+	return invalid
+}
+
+// records err and skips past the closing token of the construct being
+// parsed, any bracketed constructs along the way are skipped entirely
+func recoverTo(p *peruse.Parser[Tree], closing peruse.TokenType, err error, partial ...Tree) *Invalid {
+	invalid := invalidAt(p, err, partial)
+	depth := 0
+	for !p.Next.Is(peruse.EOF) && !p.Next.Is(peruse.ERR) {
+		if depth == 0 && p.Next.Is(closing) {
+			p.Consume()
+			break
+		}
+		p.Consume()
+		switch p.Cur.Type {
+		case TokenOpenParen, TokenOpenBracket:
+			depth++
+		case TokenCloseParen, TokenCloseBracket:
+			depth--
+		}
+	}
+	return invalid
+}
+
+// how tok reads in a syntax error, peruse.Token's own String is a debug dump
+func describeToken(tok peruse.Token) string {
+	switch tok.Type {
+	case peruse.EOF:
+		return "end of rule"
+	case peruse.ERR:
+		return tok.Literal
+	default:
+		return fmt.Sprintf("%q", tok.Literal)
+	}
+}
+
+func unexpectedToken(tok peruse.Token) error {
+	return fmt.Errorf("unexpected %s", describeToken(tok))
+}
+
+// reports the missing closer of an unfinished construct at the token found
+// in its place
+func expectedToken(closer string, found peruse.Token) error {
+	return SpanError{TokenSpan(found), fmt.Errorf("expected %s but found %s", closer, describeToken(found))}
+}
+
+func synchronizes(tok peruse.Token) bool {
+	switch tok.Type {
+	case TokenCloseParen, TokenCloseBracket, TokenAnd, TokenOr, TokenComma, peruse.EOF, peruse.ERR:
+		return true
+	default:
+		return false
+	}
+}
+
+func errorAt(tree Tree, pos peruse.Pos) bool {
+	found := false
+	Visitor{
+		Invalid: func(node *Invalid, visit func(Tree) error) error {
+			for _, partial := range node.Partial {
+				visit(partial)
+			}
+			found = found || node.At.Start == pos
+			return nil
+		},
+	}.Visit(tree)
+	return found
+}
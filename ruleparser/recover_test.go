@@ -0,0 +1,137 @@
+package ruleparser
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseReportsEverySyntaxError(t *testing.T) {
+	source := "(is_adult and ) or can_use(Hover_Boots,, Slingshot) or has('Goron Tunic' 2)"
+	tree, err := parse(t, source)
+	if err == nil {
+		t.Fatal("expected parse to fail")
+	}
+
+	var syntax SyntaxErrors
+	if !errors.As(err, &syntax) {
+		t.Fatalf("expected SyntaxErrors but got %T", err)
+	}
+
+	expected := []string{")", ",", "2"}
+	if len(syntax) != len(expected) {
+		t.Fatalf("expected %d errors but got %d:\n%s", len(expected), len(syntax), err)
+	}
+
+	for i, text := range expected {
+		at := syntax[i].At
+		if spanned := source[at.Start:at.End]; spanned != text {
+			t.Errorf("expected error %d at %q but found %q", i, text, spanned)
+		}
+	}
+
+	// the valid parts of the rule are still present
+	var calls []string
+	Visitor{
+		Call: func(node *Call, visit func(Tree) error) error {
+			calls = append(calls, MustAssertAs[*Identifier](node.Callee).Value)
+			for _, arg := range node.Args {
+				visit(arg)
+			}
+			return nil
+		},
+	}.Visit(tree)
+	if len(calls) != 2 || calls[0] != "can_use" || calls[1] != "has" {
+		t.Fatalf("expected calls to can_use and has to survive recovery, found %v", calls)
+	}
+}
+
+func TestParseSucceedsWithoutInvalidNodes(t *testing.T) {
+	tree, err := parse(t, "is_adult and (can_use(Hover_Boots) or has('Goron Tunic', 2))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Errors(tree); err != nil {
+		t.Fatalf("expected no invalid nodes: %s", err)
+	}
+}
+
+func TestParseStopsAtLexerError(t *testing.T) {
+	source := "is_adult and $ or is_child"
+	_, err := parse(t, source)
+	if err == nil {
+		t.Fatal("expected parse to fail")
+	}
+
+	var syntax SyntaxErrors
+	if !errors.As(err, &syntax) || len(syntax) != 1 {
+		t.Fatalf("expected exactly one syntax error:\n%s", err)
+	}
+}
+
+func TestParseSkipsToCloser(t *testing.T) {
+	source := "(is_adult has(Hover_Boots) and (can_use(Slingshot))) or is_child"
+	tree, err := parse(t, source)
+	var syntax SyntaxErrors
+	if !errors.As(err, &syntax) || len(syntax) != 1 {
+		t.Fatalf("expected exactly one syntax error:\n%v", err)
+	}
+
+	boolOp := MustAssertAs[*BoolOp](tree)
+	MustAssertAs[*Invalid](boolOp.Left)
+	if ident := MustAssertAs[*Identifier](boolOp.Right); ident.Value != "is_child" {
+		t.Fatalf("expected recovery to resume at is_child, found %q", ident.Value)
+	}
+}
+
+func TestParseChainsComparisons(t *testing.T) {
+	tree, err := parse(t, "0 < x <= 3 not in y")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ops []BinOpKind
+	Visitor{
+		BinOp: func(node *BinOp, visit func(Tree) error) error {
+			ops = append(ops, node.Op)
+			return nil
+		},
+	}.Visit(tree)
+	if len(ops) != 3 || ops[0] != BinOpLt || ops[1] != BinOpLtEq || ops[2] != BinOpNotContains {
+		t.Fatalf("expected chain of <, <= and not in, found %v", ops)
+	}
+}
+
+func TestParseRequiresInAfterNot(t *testing.T) {
+	source := "x not y or is_child"
+	_, err := parse(t, source)
+	var syntax SyntaxErrors
+	if !errors.As(err, &syntax) || len(syntax) != 1 {
+		t.Fatalf("expected exactly one syntax error:\n%v", err)
+	}
+	if at := syntax[0].At; source[at.Start:at.End] != "y" {
+		t.Fatalf("expected error at y, found %q", source[at.Start:at.End])
+	}
+}
+
+func TestParseReportsMissingClosers(t *testing.T) {
+	for source, expected := range map[string]string{
+		"x[":          "expected ']' but found end of rule",
+		"x[1":         "expected ']' but found end of rule",
+		"(c":          "expected ')' but found end of rule",
+		"has(Bow":     "expected ')' but found end of rule",
+		"f(a b)":      `expected ')' but found "b"`,
+		"a and and b": `expected expression but found "and"`,
+		"1 2":         `unexpected "2"`,
+	} {
+		_, err := parse(t, source)
+		var syntax SyntaxErrors
+		if !errors.As(err, &syntax) {
+			t.Errorf("expected %q to fail parsing, found %v", source, err)
+			continue
+		}
+		last := syntax[len(syntax)-1]
+		if last.Error() != expected {
+			t.Errorf("expected %q to report %q, found %q", source, expected, err)
+		}
+	}
+}
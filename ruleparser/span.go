@@ -0,0 +1,102 @@
+package ruleparser
+
+import (
+	"errors"
+
+	"github.com/etc-sudonters/substrate/peruse"
+)
+
+// half open byte range into the parsed source
+type Span struct {
+	Start, End peruse.Pos
+}
+
+func (this Span) IsZero() bool {
+	return this.Start == 0 && this.End == 0
+}
+
+func (this Span) Len() int {
+	return int(this.End - this.Start)
+}
+
+// smallest span covering both
+func (this Span) Join(other Span) Span {
+	joined := this
+	if other.Start < joined.Start {
+		joined.Start = other.Start
+	}
+	if other.End > joined.End {
+		joined.End = other.End
+	}
+	return joined
+}
+
+func TokenSpan(tok peruse.Token) Span {
+	switch tok.Type {
+	case TokenString:
+		// string tokens begin after their opening quote
+		return Span{tok.Pos - 1, tok.Pos + peruse.Pos(len(tok.Literal)) + 1}
+	case peruse.ERR, peruse.EOF:
+		return Span{tok.Pos, tok.Pos + 1}
+	default:
+		return Span{tok.Pos, tok.Pos + peruse.Pos(len(tok.Literal))}
+	}
+}
+
+// an error with a known location in the source
+type SpanError struct {
+	At    Span
+	Cause error
+}
+
+func (this SpanError) Error() string {
+	return this.Cause.Error()
+}
+
+func (this SpanError) Unwrap() error {
+	return this.Cause
+}
+
+func (this SpanError) Location() Span {
+	return this.At
+}
+
+type located interface {
+	Location() Span
+}
+
+// finds the most specific span in the error tree
+func SpanOf(err error) (Span, bool) {
+	var span Span
+	var found bool
+
+	var walk func(error)
+	walk = func(err error) {
+		if err == nil {
+			return
+		}
+
+		if loc, isLocated := err.(located); isLocated {
+			span, found = loc.Location(), true
+		}
+
+		switch tok := err.(type) {
+		case peruse.UnexpectedToken:
+			span, found = TokenSpan(tok.Have), true
+		case peruse.InvalidToken:
+			span, found = TokenSpan(tok.Have), true
+		}
+
+		switch err := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, inner := range err.Unwrap() {
+				walk(inner)
+			}
+		default:
+			walk(errors.Unwrap(err))
+		}
+	}
+
+	walk(err)
+	return span, found
+}
@@ -16,6 +16,9 @@ type Visitor struct {
 	Tuple      VisitFunc[*Tuple]
 	UnaryOp    VisitFunc[*UnaryOp]
 	Literal    VisitFunc[*Literal]
+	Invalid    VisitFunc[*Invalid]
+	Attribute  VisitFunc[*Attribute]
+	Keyword    VisitFunc[*Keyword]
 }
 
 func (v Visitor) Visit(node Tree) error {
@@ -82,7 +85,7 @@ func (v Visitor) Visit(node Tree) error {
 						return elmErr
 					}
 				}
-
+				return nil
 			}
 			return v.Tuple(n, visit)
 		case *UnaryOp:
@@ -98,6 +101,26 @@ func (v Visitor) Visit(node Tree) error {
 				return nil
 			}
 			return v.Literal(n, visit)
+		case *Invalid:
+			if v.Invalid == nil {
+				for _, partial := range n.Partial {
+					if partialErr := visit(partial); partialErr != nil {
+						return partialErr
+					}
+				}
+				return nil
+			}
+			return v.Invalid(n, visit)
+		case *Attribute:
+			if v.Attribute == nil {
+				return visit(n.Target)
+			}
+			return v.Attribute(n, visit)
+		case *Keyword:
+			if v.Keyword == nil {
+				return visit(n.Value)
+			}
+			return v.Keyword(n, visit)
 
 		default:
 			panic(stageleft.AttachExitCode(